@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	stream "github.com/GetStream/stream-chat-go/v5"
+)
+
+const chatChannelType = "messaging"
+
+var errChatMessageNotFound = errors.New("chat message not found")
+
+// chatChannelID returns the channel of an accepted proposal. Each proposal
+// gets its own channel so freelancers on the same project never share one.
+func chatChannelID(proposalID string) string {
+	return "proposal_" + proposalID
+}
+
+// ChatUser is the profile data pushed to the chat provider.
+type ChatUser struct {
+	ID    string
+	Name  string
+	Image string
+	Extra map[string]any
+}
+
+// ChatMessage is a message posted by the backend on behalf of UserID. The
+// fields after Extra are only filled when reading the channel history.
+type ChatMessage struct {
+	ID          string
+	UserID      string
+	Text        string
+	Attachments []ChatAttachment
+	Extra       map[string]any
+
+	Type      string
+	ParentID  string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt time.Time
+}
+
+// ChatAttachment is a file linked from a message; AssetURL must be
+// reachable by the channel members.
+type ChatAttachment struct {
+	Type     string
+	Title    string
+	AssetURL string
+	MimeType string
+	FileSize int64
+}
+
+// ChatBan describes an app-wide ban. A zero Duration bans permanently;
+// shadow banned users can keep posting but nobody else sees their messages.
+type ChatBan struct {
+	BannedBy string
+	Reason   string
+	Duration time.Duration
+	Shadow   bool
+}
+
+// ChatProvider is the subset of chat operations the backend relies on.
+// PocketBase stays the source of truth; implementations only mirror state.
+type ChatProvider interface {
+	UpsertUsers(ctx context.Context, users ...ChatUser) error
+
+	CreateChannel(ctx context.Context, channelID string, createdBy string, memberIDs ...string) error
+	UpdateChannel(ctx context.Context, channelID string, data map[string]any) error
+	FreezeChannel(ctx context.Context, channelID string, frozen bool) error
+	ArchiveChannel(ctx context.Context, channelID string) error
+
+	AddMembers(ctx context.Context, channelID string, userIDs ...string) error
+	RemoveMembers(ctx context.Context, channelID string, userIDs ...string) error
+
+	// SendSystemMessage posts a system message. Sending a message whose ID
+	// already exists in the channel is not an error.
+	SendSystemMessage(ctx context.Context, channelID string, message ChatMessage) error
+	// SendMessage posts a regular message of message.UserID, with the same
+	// duplicate handling as SendSystemMessage.
+	SendMessage(ctx context.Context, channelID string, message ChatMessage) error
+	DeleteMessage(ctx context.Context, messageID string, hard bool) error
+	// ListMessages returns the whole history of a channel, oldest first,
+	// including thread replies and soft-deleted messages.
+	ListMessages(ctx context.Context, channelID string) ([]ChatMessage, error)
+	// GetMessage returns a message together with the channel it was posted
+	// in, or errChatMessageNotFound.
+	GetMessage(ctx context.Context, messageID string) (ChatMessage, string, error)
+
+	BanUser(ctx context.Context, userID string, ban ChatBan) error
+	UnbanUser(ctx context.Context, userID string) error
+	// BanChannelMember keeps the user in the channel but stops them from
+	// posting there; Duration and Shadow are ignored.
+	BanChannelMember(ctx context.Context, channelID string, userID string, ban ChatBan) error
+	UnbanChannelMember(ctx context.Context, channelID string, userID string) error
+
+	// CreateToken mints a user token; a zero expire means no expiry.
+	CreateToken(userID string, expire time.Time, issuedAt time.Time) (string, error)
+	// RevokeUserTokens invalidates every token of the user issued before
+	// the given time.
+	RevokeUserTokens(ctx context.Context, userID string, before time.Time) error
+}
+
+// newChatProvider builds the provider selected by CHAT_PROVIDER
+// ("stream" by default, or "memory" for offline development).
+func newChatProvider() (ChatProvider, error) {
+	provider := strings.ToLower(strings.TrimSpace(os.Getenv("CHAT_PROVIDER")))
+
+	switch provider {
+	case "", "stream":
+		apiKey := os.Getenv("STREAM_API_KEY")
+		apiSecret := os.Getenv("STREAM_API_SECRET")
+		if apiKey == "" || apiSecret == "" {
+			return nil, errors.New("STREAM_API_KEY and STREAM_API_SECRET are required (or set CHAT_PROVIDER=memory)")
+		}
+
+		client, err := stream.NewClient(apiKey, apiSecret)
+		if err != nil {
+			return nil, err
+		}
+
+		return newStreamChatProvider(client), nil
+	case "memory":
+		return newMemoryChatProvider(), nil
+	default:
+		return nil, fmt.Errorf("unsupported CHAT_PROVIDER %q", provider)
+	}
+}
+
+// streamChatProvider implements ChatProvider on top of GetStream Chat.
+type streamChatProvider struct {
+	client *stream.Client
+}
+
+func newStreamChatProvider(client *stream.Client) *streamChatProvider {
+	return &streamChatProvider{client: client}
+}
+
+func (p *streamChatProvider) UpsertUsers(ctx context.Context, users ...ChatUser) error {
+	streamUsers := make([]*stream.User, 0, len(users))
+	for _, user := range users {
+		streamUsers = append(streamUsers, &stream.User{
+			ID:        user.ID,
+			Name:      user.Name,
+			Image:     user.Image,
+			ExtraData: user.Extra,
+		})
+	}
+
+	_, err := p.client.UpsertUsers(ctx, streamUsers...)
+	return err
+}
+
+func (p *streamChatProvider) CreateChannel(ctx context.Context, channelID string, createdBy string, memberIDs ...string) error {
+	_, err := p.client.CreateChannelWithMembers(ctx, chatChannelType, channelID, createdBy, memberIDs...)
+	return err
+}
+
+func (p *streamChatProvider) UpdateChannel(ctx context.Context, channelID string, data map[string]any) error {
+	_, err := p.client.Channel(chatChannelType, channelID).PartialUpdate(ctx, stream.PartialUpdate{Set: data})
+	return err
+}
+
+func (p *streamChatProvider) FreezeChannel(ctx context.Context, channelID string, frozen bool) error {
+	return p.UpdateChannel(ctx, channelID, map[string]any{"frozen": frozen})
+}
+
+// ArchiveChannel disables the channel, which hides it and rejects new messages.
+func (p *streamChatProvider) ArchiveChannel(ctx context.Context, channelID string) error {
+	return p.UpdateChannel(ctx, channelID, map[string]any{"disabled": true})
+}
+
+func (p *streamChatProvider) AddMembers(ctx context.Context, channelID string, userIDs ...string) error {
+	_, err := p.client.Channel(chatChannelType, channelID).AddMembers(ctx, userIDs)
+	return err
+}
+
+func (p *streamChatProvider) RemoveMembers(ctx context.Context, channelID string, userIDs ...string) error {
+	_, err := p.client.Channel(chatChannelType, channelID).RemoveMembers(ctx, userIDs, nil)
+	return err
+}
+
+func (p *streamChatProvider) SendSystemMessage(ctx context.Context, channelID string, message ChatMessage) error {
+	return p.sendMessage(ctx, channelID, stream.MessageTypeSystem, message)
+}
+
+func (p *streamChatProvider) SendMessage(ctx context.Context, channelID string, message ChatMessage) error {
+	return p.sendMessage(ctx, channelID, stream.MessageTypeRegular, message)
+}
+
+func (p *streamChatProvider) sendMessage(ctx context.Context, channelID string, messageType stream.MessageType, message ChatMessage) error {
+	attachments := make([]*stream.Attachment, 0, len(message.Attachments))
+	for _, attachment := range message.Attachments {
+		attachments = append(attachments, &stream.Attachment{
+			Type:     attachment.Type,
+			Title:    attachment.Title,
+			AssetURL: attachment.AssetURL,
+			ExtraData: map[string]any{
+				"mime_type": attachment.MimeType,
+				"file_size": attachment.FileSize,
+			},
+		})
+	}
+
+	_, err := p.client.Channel(chatChannelType, channelID).SendMessage(ctx, &stream.Message{
+		ID:          message.ID,
+		Text:        message.Text,
+		Type:        messageType,
+		Attachments: attachments,
+		ExtraData:   message.Extra,
+	}, message.UserID)
+	if err != nil && message.ID != "" && strings.Contains(err.Error(), "already exists") {
+		// a retried job whose first attempt reached Stream
+		return nil
+	}
+	return err
+}
+
+func (p *streamChatProvider) DeleteMessage(ctx context.Context, messageID string, hard bool) error {
+	var err error
+	if hard {
+		_, err = p.client.HardDeleteMessage(ctx, messageID)
+	} else {
+		_, err = p.client.DeleteMessage(ctx, messageID)
+	}
+	return err
+}
+
+// streamHistoryPageSize is the largest page Stream returns per query.
+const streamHistoryPageSize = 300
+
+func (p *streamChatProvider) ListMessages(ctx context.Context, channelID string) ([]ChatMessage, error) {
+	channel := p.client.Channel(chatChannelType, channelID)
+
+	// pages come newest first, each of them sorted oldest first
+	pages := [][]*stream.Message{}
+	before := ""
+	for {
+		resp, err := channel.Query(ctx, &stream.QueryRequest{
+			Messages: &stream.MessagePaginationParamsRequest{
+				PaginationParamsRequest: stream.PaginationParamsRequest{Limit: streamHistoryPageSize, IDLT: before},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Messages) == 0 {
+			break
+		}
+		pages = append(pages, resp.Messages)
+		if len(resp.Messages) < streamHistoryPageSize {
+			break
+		}
+		before = resp.Messages[0].ID
+	}
+
+	seen := map[string]bool{}
+	messages := []ChatMessage{}
+	add := func(message *stream.Message) {
+		if seen[message.ID] {
+			return
+		}
+		seen[message.ID] = true
+		messages = append(messages, chatMessageFromStream(message))
+	}
+
+	for i := len(pages) - 1; i >= 0; i-- {
+		for _, message := range pages[i] {
+			add(message)
+			if message.ReplyCount == 0 {
+				continue
+			}
+
+			after := ""
+			for {
+				options := map[string][]string{"limit": {strconv.Itoa(streamHistoryPageSize)}}
+				if after != "" {
+					options["id_gt"] = []string{after}
+				}
+				resp, err := channel.GetReplies(ctx, message.ID, options)
+				if err != nil {
+					return nil, err
+				}
+				for _, reply := range resp.Messages {
+					add(reply)
+				}
+				if len(resp.Messages) < streamHistoryPageSize {
+					break
+				}
+				after = resp.Messages[len(resp.Messages)-1].ID
+			}
+		}
+	}
+
+	return messages, nil
+}
+
+func (p *streamChatProvider) GetMessage(ctx context.Context, messageID string) (ChatMessage, string, error) {
+	resp, err := p.client.GetMessage(ctx, messageID)
+	if err != nil {
+		var apiErr stream.Error
+		if errors.As(err, &apiErr) && apiErr.StatusCode == 404 {
+			return ChatMessage{}, "", errChatMessageNotFound
+		}
+		return ChatMessage{}, "", err
+	}
+
+	channelID := strings.TrimPrefix(resp.Message.CID, chatChannelType+":")
+	return chatMessageFromStream(resp.Message), channelID, nil
+}
+
+func chatMessageFromStream(message *stream.Message) ChatMessage {
+	result := ChatMessage{
+		ID:       message.ID,
+		Text:     message.Text,
+		Extra:    message.ExtraData,
+		Type:     string(message.Type),
+		ParentID: message.ParentID,
+	}
+	if message.User != nil {
+		result.UserID = message.User.ID
+	}
+	if message.CreatedAt != nil {
+		result.CreatedAt = *message.CreatedAt
+	}
+	if message.UpdatedAt != nil {
+		result.UpdatedAt = *message.UpdatedAt
+	}
+	if message.DeletedAt != nil {
+		result.DeletedAt = *message.DeletedAt
+	}
+
+	for _, attachment := range message.Attachments {
+		if attachment == nil {
+			continue
+		}
+		converted := ChatAttachment{
+			Type:     attachment.Type,
+			Title:    attachment.Title,
+			AssetURL: attachment.AssetURL,
+		}
+		if converted.AssetURL == "" {
+			converted.AssetURL = attachment.ImageURL
+		}
+		converted.MimeType, _ = attachment.ExtraData["mime_type"].(string)
+		if size, ok := attachment.ExtraData["file_size"].(float64); ok {
+			converted.FileSize = int64(size)
+		}
+		result.Attachments = append(result.Attachments, converted)
+	}
+
+	return result
+}
+
+func (p *streamChatProvider) BanUser(ctx context.Context, userID string, ban ChatBan) error {
+	options := []stream.BanOption{}
+	if ban.Reason != "" {
+		options = append(options, stream.BanWithReason(ban.Reason))
+	}
+	if ban.Duration > 0 {
+		// Stream expects the expiration in minutes
+		options = append(options, stream.BanWithExpiration(int(math.Ceil(ban.Duration.Minutes()))))
+	}
+
+	var err error
+	if ban.Shadow {
+		_, err = p.client.ShadowBan(ctx, userID, ban.BannedBy, options...)
+	} else {
+		_, err = p.client.BanUser(ctx, userID, ban.BannedBy, options...)
+	}
+	return err
+}
+
+func (p *streamChatProvider) UnbanUser(ctx context.Context, userID string) error {
+	_, err := p.client.UnBanUser(ctx, userID)
+	return err
+}
+
+func (p *streamChatProvider) BanChannelMember(ctx context.Context, channelID string, userID string, ban ChatBan) error {
+	options := []stream.BanOption{}
+	if ban.Reason != "" {
+		options = append(options, stream.BanWithReason(ban.Reason))
+	}
+
+	_, err := p.client.Channel(chatChannelType, channelID).BanUser(ctx, userID, ban.BannedBy, options...)
+	return err
+}
+
+func (p *streamChatProvider) UnbanChannelMember(ctx context.Context, channelID string, userID string) error {
+	_, err := p.client.Channel(chatChannelType, channelID).UnBanUser(ctx, userID)
+	return err
+}
+
+func (p *streamChatProvider) CreateToken(userID string, expire time.Time, issuedAt time.Time) (string, error) {
+	return p.client.CreateToken(userID, expire, issuedAt)
+}
+
+func (p *streamChatProvider) RevokeUserTokens(ctx context.Context, userID string, before time.Time) error {
+	_, err := p.client.RevokeUserToken(ctx, userID, &before)
+	return err
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// outboxJobMigrateConversationChannel jobs are queued by the
+// 1769700000_split_project_channels migration.
+const outboxJobMigrateConversationChannel = "migrate_conversation_channel"
+
+// handleMigrateConversationChannel moves a conversation off the legacy shared
+// "project_<id>" channel into its own proposal channel. The legacy channel is
+// frozen so its history stays readable but nobody can keep posting there.
+// Since it mixes the messages of every proposal of the project, only the
+// client, who takes part in all of them, is kept as a member.
+func handleMigrateConversationChannel(app core.App, chat ChatProvider, job *models.Record) error {
+	conversation, err := app.Dao().FindRecordById("conversations", job.GetString("reference_id"))
+	if err != nil {
+		return err
+	}
+
+	legacyChannelID := conversation.GetString("stream_channel_id")
+	if !strings.HasPrefix(legacyChannelID, "project_") {
+		return nil
+	}
+
+	proposal, err := app.Dao().FindRecordById("proposals", conversation.GetString("proposal_id"))
+	if err != nil {
+		return err
+	}
+
+	clientId := proposal.GetString("client_id")
+	memberIds, err := conversationMemberIDs(app.Dao(), conversation)
+	if err != nil {
+		return err
+	}
+
+	users, err := findChatUsers(app, memberIds...)
+	if err != nil {
+		return err
+	}
+	if err := chat.UpsertUsers(context.Background(), users...); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	channelId := chatChannelID(proposal.Id)
+
+	if err := chat.CreateChannel(ctx, channelId, clientId, memberIds...); err != nil {
+		return err
+	}
+
+	switch conversation.GetString("state") {
+	case "frozen":
+		err = chat.FreezeChannel(ctx, channelId, true)
+	case "archived":
+		err = chat.ArchiveChannel(ctx, channelId)
+	}
+	if err != nil {
+		return err
+	}
+
+	outsiders, err := legacyChannelOutsiders(ctx, app, chat, legacyChannelID, proposal)
+	if err != nil {
+		return err
+	}
+	if len(outsiders) > 0 {
+		if err := chat.RemoveMembers(ctx, legacyChannelID, outsiders...); err != nil {
+			return err
+		}
+	}
+
+	if err := chat.FreezeChannel(ctx, legacyChannelID, true); err != nil {
+		return err
+	}
+
+	conversation.Set("legacy_stream_channel_id", legacyChannelID)
+	conversation.Set("stream_channel_id", channelId)
+
+	return app.Dao().SaveRecord(conversation)
+}
+
+// legacyChannelOutsiders returns the users of a legacy project channel other
+// than the client: the freelancers of every proposal of the project and
+// anyone who posted in the channel.
+func legacyChannelOutsiders(ctx context.Context, app core.App, chat ChatProvider, legacyChannelID string, proposal *models.Record) ([]string, error) {
+	clientId := proposal.GetString("client_id")
+	seen := map[string]bool{clientId: true, "": true}
+	outsiders := []string{}
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			outsiders = append(outsiders, id)
+		}
+	}
+
+	proposals, err := app.Dao().FindRecordsByFilter(
+		"proposals",
+		"project_id = {:project}",
+		"created",
+		0,
+		0,
+		dbx.Params{"project": proposal.GetString("project_id")},
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range proposals {
+		add(other.GetString("freelancer_id"))
+	}
+
+	messages, err := chat.ListMessages(ctx, legacyChannelID)
+	if err != nil {
+		return nil, err
+	}
+	for _, message := range messages {
+		if message.Type != "system" {
+			add(message.UserID)
+		}
+	}
+
+	return outsiders, nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	defaultConversationPageSize = 20
+	maxConversationPageSize     = 100
+)
+
+// conversationActivitySQL is the time a conversation is sorted by: its last
+// message, or its creation while it has none. Both are stored in the same
+// text format, so they compare correctly as strings.
+const conversationActivitySQL = "COALESCE(NULLIF(c.last_message_at, ''), c.created)"
+
+// conversationRow is one row of the joined conversation listing query.
+type conversationRow struct {
+	ID                 string         `db:"id"`
+	StreamChannelID    string         `db:"stream_channel_id"`
+	Type               string         `db:"type"`
+	State              string         `db:"state"`
+	ReadOnlyUserID     string         `db:"read_only_user_id"`
+	LastMessageAt      types.DateTime `db:"last_message_at"`
+	LastMessagePreview string         `db:"last_message_preview"`
+	UnreadCounts       types.JsonRaw  `db:"unread_counts"`
+	Activity           string         `db:"activity"`
+	ProposalID         string         `db:"proposal_id"`
+	ProjectID          string         `db:"project_id"`
+	ProjectTitle       string         `db:"project_title"`
+	ProjectStatus      string         `db:"project_status"`
+	CounterpartID      string         `db:"counterpart_id"`
+	CounterpartName    string         `db:"counterpart_name"`
+	CounterpartRole    string         `db:"counterpart_role"`
+}
+
+// conversationCursor points after the last conversation of a page.
+type conversationCursor struct {
+	Activity string `json:"a"`
+	ID       string `json:"id"`
+}
+
+func encodeConversationCursor(cursor conversationCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeConversationCursor(value string) (conversationCursor, error) {
+	var cursor conversationCursor
+
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, err
+	}
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return cursor, err
+	}
+
+	return cursor, nil
+}
+
+// escapeLike escapes the LIKE wildcards of a user supplied search term.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// chatConversationsHandler lists the conversations of the current user, most
+// recent activity first, with a single query per page.
+//
+// Query params: limit (default 20, max 100), cursor (next_cursor of the
+// previous page), project_status, counterpart_role and q (project title or
+// counterpart name).
+func chatConversationsHandler(app core.App) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+		if !ok || record == nil {
+			return apis.NewUnauthorizedError("unauthorized", nil)
+		}
+
+		limit := defaultConversationPageSize
+		if value := c.QueryParam("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 || parsed > maxConversationPageSize {
+				return apis.NewBadRequestError("limit must be between 1 and "+strconv.Itoa(maxConversationPageSize), nil)
+			}
+			limit = parsed
+		}
+
+		where := []string{
+			"c.is_deleted = false",
+			"p.is_deleted = false",
+			"p.status IN ('accepted', 'sent', 'countered')",
+			"(p.client_id = {:uid} OR p.freelancer_id = {:uid})",
+		}
+		params := dbx.Params{"uid": record.Id}
+
+		if status := c.QueryParam("project_status"); status != "" {
+			if status != "open" && status != "in_progress" && status != "closed" {
+				return apis.NewBadRequestError("project_status must be one of open, in_progress, closed", nil)
+			}
+			where = append(where, "pr.status = {:project_status}")
+			params["project_status"] = status
+		}
+
+		if role := c.QueryParam("counterpart_role"); role != "" {
+			if role != "client" && role != "freelancer" {
+				return apis.NewBadRequestError("counterpart_role must be client or freelancer", nil)
+			}
+			where = append(where, "u.role = {:counterpart_role}")
+			params["counterpart_role"] = role
+		}
+
+		if q := strings.TrimSpace(c.QueryParam("q")); q != "" {
+			where = append(where, `(pr.title LIKE {:q} ESCAPE '\' OR u.name LIKE {:q} ESCAPE '\')`)
+			params["q"] = "%" + escapeLike(q) + "%"
+		}
+
+		if value := c.QueryParam("cursor"); value != "" {
+			cursor, err := decodeConversationCursor(value)
+			if err != nil || cursor.ID == "" {
+				return apis.NewBadRequestError("invalid cursor", err)
+			}
+			where = append(where, "("+conversationActivitySQL+" < {:cursor_activity} OR ("+
+				conversationActivitySQL+" = {:cursor_activity} AND c.id < {:cursor_id}))")
+			params["cursor_activity"] = cursor.Activity
+			params["cursor_id"] = cursor.ID
+		}
+
+		// one row more than requested tells whether there is a next page
+		query := `
+			SELECT
+				c.id, c.stream_channel_id, c.type, c.state, c.read_only_user_id,
+				c.last_message_at, c.last_message_preview, c.unread_counts,
+				` + conversationActivitySQL + ` AS activity,
+				p.id AS proposal_id,
+				pr.id AS project_id, pr.title AS project_title, pr.status AS project_status,
+				u.id AS counterpart_id, u.name AS counterpart_name, u.role AS counterpart_role
+			FROM conversations c
+			JOIN proposals p ON p.id = c.proposal_id
+			JOIN projects pr ON pr.id = p.project_id
+			JOIN users u ON u.id = CASE WHEN p.client_id = {:uid} THEN p.freelancer_id ELSE p.client_id END
+			WHERE ` + strings.Join(where, " AND ") + `
+			ORDER BY activity DESC, c.id DESC
+			LIMIT ` + strconv.Itoa(limit+1)
+
+		rows := []conversationRow{}
+		if err := app.Dao().DB().NewQuery(query).Bind(params).All(&rows); err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to load conversations", err)
+		}
+
+		var nextCursor any
+		if len(rows) > limit {
+			rows = rows[:limit]
+			last := rows[len(rows)-1]
+			nextCursor = encodeConversationCursor(conversationCursor{Activity: last.Activity, ID: last.ID})
+		}
+
+		items := make([]map[string]any, 0, len(rows))
+		for _, row := range rows {
+			var lastMessageAt any
+			if !row.LastMessageAt.IsZero() {
+				lastMessageAt = row.LastMessageAt
+			}
+
+			counts := map[string]int{}
+			_ = json.Unmarshal(row.UnreadCounts, &counts)
+
+			items = append(items, map[string]any{
+				"conversation_id":      row.ID,
+				"stream_channel_id":    row.StreamChannelID,
+				"type":                 row.Type,
+				"state":                row.State,
+				"read_only_user_id":    row.ReadOnlyUserID,
+				"last_message_at":      lastMessageAt,
+				"last_message_preview": row.LastMessagePreview,
+				"unread_count":         counts[record.Id],
+				"project": map[string]any{
+					"id":     row.ProjectID,
+					"title":  row.ProjectTitle,
+					"status": row.ProjectStatus,
+				},
+				"counterpart": map[string]any{
+					"id":   row.CounterpartID,
+					"name": row.CounterpartName,
+					"role": row.CounterpartRole,
+				},
+				"proposal_id": row.ProposalID,
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]any{
+			"items":       items,
+			"next_cursor": nextCursor,
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+type conversationListResponse struct {
+	Items []struct {
+		ConversationID     string  `json:"conversation_id"`
+		Type               string  `json:"type"`
+		State              string  `json:"state"`
+		LastMessageAt      *string `json:"last_message_at"`
+		LastMessagePreview string  `json:"last_message_preview"`
+		UnreadCount        int     `json:"unread_count"`
+		ProposalID         string  `json:"proposal_id"`
+		Project            struct {
+			ID     string `json:"id"`
+			Title  string `json:"title"`
+			Status string `json:"status"`
+		} `json:"project"`
+		Counterpart struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+			Role string `json:"role"`
+		} `json:"counterpart"`
+	} `json:"items"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+// createListedConversation creates a project, an accepted proposal and its
+// conversation between the given users.
+func createListedConversation(t *testing.T, app *tests.TestApp, client *models.Record, freelancer *models.Record, title string, lastMessageAt time.Time) *models.Record {
+	t.Helper()
+
+	project := createTestRecord(t, app, "projects", map[string]any{
+		"title":       title,
+		"description": "Description",
+		"type":        "remote",
+		"client_id":   client.Id,
+		"status":      "open",
+		"is_deleted":  false,
+	})
+	proposal := createTestRecord(t, app, "proposals", map[string]any{
+		"project_id":    project.Id,
+		"freelancer_id": freelancer.Id,
+		"client_id":     client.Id,
+		"message":       "Hello",
+		"status":        "accepted",
+		"is_deleted":    false,
+	})
+
+	fields := map[string]any{
+		"project_id":        project.Id,
+		"proposal_id":       proposal.Id,
+		"stream_channel_id": chatChannelID(proposal.Id),
+		"type":              "proposal",
+		"state":             "active",
+		"is_deleted":        false,
+	}
+	if !lastMessageAt.IsZero() {
+		fields["last_message_at"] = lastMessageAt
+		fields["last_message_preview"] = "Message in " + title
+	}
+
+	return createTestRecord(t, app, "conversations", fields)
+}
+
+func listConversations(t *testing.T, app *tests.TestApp, user *models.Record, query url.Values) (int, conversationListResponse) {
+	t.Helper()
+
+	code, rec := callHandler(t, chatConversationsHandler(app), http.MethodGet, "/chat/conversations?"+query.Encode(), nil, nil, user)
+
+	var response conversationListResponse
+	if code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return code, response
+}
+
+func TestChatConversationsResponse(t *testing.T) {
+	app := newTestApp(t)
+
+	freelancer := createTestUser(t, app, "freelancer", nil)
+	alice := createTestUser(t, app, "client", map[string]any{"name": "Alice"})
+	bob := createTestUser(t, app, "client", map[string]any{"name": "Bob"})
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	older := createListedConversation(t, app, alice, freelancer, "Website", now.Add(-time.Hour))
+	newer := createListedConversation(t, app, bob, freelancer, "Mobile app", now.Add(-time.Minute))
+	silent := createListedConversation(t, app, alice, freelancer, "Logo", time.Time{})
+
+	newer.Set("unread_counts", map[string]int{freelancer.Id: 3, bob.Id: 1})
+	if err := app.Dao().SaveRecord(newer); err != nil {
+		t.Fatal(err)
+	}
+
+	// conversations of other users are never listed
+	createListedConversation(t, app, alice, createTestUser(t, app, "freelancer", nil), "Other", now)
+
+	code, response := listConversations(t, app, freelancer, url.Values{})
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+
+	// conversations without messages fall back to their (most recent) creation
+	expected := []string{silent.Id, newer.Id, older.Id}
+	if len(response.Items) != len(expected) {
+		t.Fatalf("expected %d conversations, got %d", len(expected), len(response.Items))
+	}
+	for i, id := range expected {
+		if response.Items[i].ConversationID != id {
+			t.Fatalf("unexpected order at %d: expected %s, got %s", i, id, response.Items[i].ConversationID)
+		}
+	}
+	if response.NextCursor != nil {
+		t.Fatalf("expected no next cursor, got %q", *response.NextCursor)
+	}
+
+	item := response.Items[1]
+	if item.UnreadCount != 3 || item.LastMessageAt == nil || item.LastMessagePreview != "Message in Mobile app" {
+		t.Fatalf("unexpected item %+v", item)
+	}
+	if item.Project.Title != "Mobile app" || item.Project.Status != "open" || item.Type != "proposal" || item.State != "active" {
+		t.Fatalf("unexpected project or state %+v", item)
+	}
+	if item.Counterpart.ID != bob.Id || item.Counterpart.Name != "Bob" || item.Counterpart.Role != "client" {
+		t.Fatalf("unexpected counterpart %+v", item.Counterpart)
+	}
+	if response.Items[0].LastMessageAt != nil {
+		t.Fatalf("expected null last_message_at, got %q", *response.Items[0].LastMessageAt)
+	}
+}
+
+func TestChatConversationsFilters(t *testing.T) {
+	app := newTestApp(t)
+
+	freelancer := createTestUser(t, app, "freelancer", nil)
+	alice := createTestUser(t, app, "client", map[string]any{"name": "Alice"})
+	bob := createTestUser(t, app, "client", map[string]any{"name": "Bob"})
+
+	now := time.Now().UTC()
+	website := createListedConversation(t, app, alice, freelancer, "Website 100%", now)
+	mobile := createListedConversation(t, app, bob, freelancer, "Mobile app", now.Add(-time.Minute))
+
+	project, err := app.Dao().FindRecordById("projects", mobile.GetString("project_id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	project.Set("status", "closed")
+	if err := app.Dao().SaveRecord(project); err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []struct {
+		name     string
+		query    url.Values
+		expected []string
+	}{
+		{"project status", url.Values{"project_status": {"closed"}}, []string{mobile.Id}},
+		{"counterpart role", url.Values{"counterpart_role": {"client"}}, []string{website.Id, mobile.Id}},
+		{"other counterpart role", url.Values{"counterpart_role": {"freelancer"}}, []string{}},
+		{"title search", url.Values{"q": {"website"}}, []string{website.Id}},
+		{"counterpart name search", url.Values{"q": {"bob"}}, []string{mobile.Id}},
+		{"wildcards are literal", url.Values{"q": {"100%"}}, []string{website.Id}},
+		{"unmatched wildcard", url.Values{"q": {"_"}}, []string{}},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			code, response := listConversations(t, app, freelancer, s.query)
+			if code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", code)
+			}
+
+			ids := []string{}
+			for _, item := range response.Items {
+				ids = append(ids, item.ConversationID)
+			}
+			if len(ids) != len(s.expected) {
+				t.Fatalf("expected %v, got %v", s.expected, ids)
+			}
+			for i := range ids {
+				if ids[i] != s.expected[i] {
+					t.Fatalf("expected %v, got %v", s.expected, ids)
+				}
+			}
+		})
+	}
+
+	invalid := []url.Values{
+		{"project_status": {"unknown"}},
+		{"counterpart_role": {"admin"}},
+		{"limit": {"0"}},
+		{"limit": {"101"}},
+		{"cursor": {"not a cursor"}},
+	}
+	for _, query := range invalid {
+		if code, _ := listConversations(t, app, freelancer, query); code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %v, got %d", query, code)
+		}
+	}
+}
+
+func TestChatConversationsPagination(t *testing.T) {
+	app := newTestApp(t)
+
+	freelancer := createTestUser(t, app, "freelancer", nil)
+	client := createTestUser(t, app, "client", nil)
+
+	// two conversations share the same activity to cover the id tiebreaker
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	expected := []string{}
+	for i, offset := range []time.Duration{0, time.Minute, time.Minute, 2 * time.Minute, 3 * time.Minute} {
+		conversation := createListedConversation(t, app, client, freelancer, "Project "+string(rune('A'+i)), now.Add(-offset))
+		expected = append(expected, conversation.Id)
+	}
+	if expected[2] > expected[1] {
+		expected[1], expected[2] = expected[2], expected[1]
+	}
+
+	seen := []string{}
+	query := url.Values{"limit": {"2"}}
+	for page := 0; page < 5; page++ {
+		code, response := listConversations(t, app, freelancer, query)
+		if code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", code)
+		}
+		for _, item := range response.Items {
+			seen = append(seen, item.ConversationID)
+		}
+		if response.NextCursor == nil {
+			break
+		}
+		query.Set("cursor", *response.NextCursor)
+	}
+
+	if len(seen) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, seen)
+	}
+	for i := range seen {
+		if seen[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, seen)
+		}
+	}
+}
+
+func TestChatConversationsQueryCount(t *testing.T) {
+	app := newTestApp(t)
+
+	freelancer := createTestUser(t, app, "freelancer", nil)
+	client := createTestUser(t, app, "client", nil)
+
+	db := app.Dao().DB().(*dbx.DB)
+	var queries int32
+	db.QueryLogFunc = func(ctx context.Context, t time.Duration, sql string, rows *sql.Rows, err error) {
+		atomic.AddInt32(&queries, 1)
+	}
+
+	count := func() int32 {
+		t.Helper()
+		atomic.StoreInt32(&queries, 0)
+		if code, _ := listConversations(t, app, freelancer, url.Values{}); code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", code)
+		}
+		return atomic.LoadInt32(&queries)
+	}
+
+	createListedConversation(t, app, client, freelancer, "First", time.Now())
+	single := count()
+
+	for i := 0; i < 10; i++ {
+		createListedConversation(t, app, client, freelancer, "More", time.Now())
+	}
+	if many := count(); many != single || single > 1 {
+		t.Fatalf("expected a single query regardless of size, got %d and %d", single, many)
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+const (
+	outboxJobScanConversationFile  = "scan_conversation_file"
+	outboxJobShareConversationFile = "share_conversation_file"
+
+	defaultChatFileURLTTL = 15 * time.Minute
+)
+
+// storedFileSuffix matches the random suffix PocketBase appends to
+// uploaded file names ("report_a1b2c3d4e5.pdf").
+var storedFileSuffix = regexp.MustCompile(`_[a-zA-Z0-9]{10}$`)
+
+// chatFileConfig signs the download links of conversation files. Links
+// stay valid for URLTTL; members can ask for a fresh one at any time.
+type chatFileConfig struct {
+	URLSecret []byte
+	URLTTL    time.Duration
+}
+
+// loadChatFileConfig reads the link settings. CHAT_FILE_URL_SECRET is
+// required outside dev mode; in dev mode a random secret is used, so links
+// only work on this instance and until it restarts.
+func loadChatFileConfig(dev bool) (chatFileConfig, error) {
+	cfg := chatFileConfig{URLTTL: defaultChatFileURLTTL}
+
+	if value := strings.TrimSpace(os.Getenv("CHAT_FILE_URL_TTL")); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl <= 0 {
+			return chatFileConfig{}, errors.New("CHAT_FILE_URL_TTL must be a positive duration")
+		}
+		cfg.URLTTL = ttl
+	}
+
+	if secret := os.Getenv("CHAT_FILE_URL_SECRET"); secret != "" {
+		cfg.URLSecret = []byte(secret)
+		return cfg, nil
+	}
+	if !dev {
+		return chatFileConfig{}, errors.New("CHAT_FILE_URL_SECRET is required")
+	}
+
+	log.Printf("CHAT_FILE_URL_SECRET is not set, using a random secret (dev mode)")
+	cfg.URLSecret = make([]byte, 32)
+	if _, err := rand.Read(cfg.URLSecret); err != nil {
+		return chatFileConfig{}, err
+	}
+
+	return cfg, nil
+}
+
+func (cfg chatFileConfig) signature(fileID string, expires int64) string {
+	mac := hmac.New(sha256.New, cfg.URLSecret)
+	mac.Write([]byte(fileID + ":" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signedURL returns the download link of a file, valid until the returned
+// expiry.
+func (cfg chatFileConfig) signedURL(baseURL string, fileID string, now time.Time) (string, time.Time) {
+	expiresAt := now.Add(cfg.URLTTL).Truncate(time.Second)
+	expires := expiresAt.Unix()
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", cfg.signature(fileID, expires))
+
+	return strings.TrimRight(baseURL, "/") + "/chat/files/" + fileID + "/download?" + query.Encode(), expiresAt
+}
+
+func (cfg chatFileConfig) verify(fileID string, expires string, signature string, now time.Time) bool {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || now.Unix() > expiresAt {
+		return false
+	}
+
+	return hmac.Equal([]byte(cfg.signature(fileID, expiresAt)), []byte(signature))
+}
+
+// prepareConversationFile resets the backend managed fields of a new file
+// and queues its virus scan.
+func prepareConversationFile(dao *daos.Dao, file *models.Record) error {
+	file.Set("scan_status", "pending")
+	file.Set("scan_result", "")
+	file.Set("scanned_at", "")
+	file.Set("message_id", "")
+
+	if strings.TrimSpace(file.GetString("name")) == "" {
+		file.Set("name", conversationFileDisplayName(file.GetString("file")))
+	}
+
+	return enqueueOutboxJob(dao, outboxJobScanConversationFile, file.Id, nil)
+}
+
+// conversationFileDisplayName strips the random suffix from a stored file
+// name.
+func conversationFileDisplayName(stored string) string {
+	ext := path.Ext(stored)
+	return storedFileSuffix.ReplaceAllString(strings.TrimSuffix(stored, ext), "") + ext
+}
+
+func conversationFileKey(file *models.Record) string {
+	return file.BaseFilesPath() + "/" + file.GetString("file")
+}
+
+// handleScanConversationFile is the outbox handler that scans a new file.
+// Clean files are queued for sharing in the conversation; infected ones
+// stay visible to the uploader only.
+func handleScanConversationFile(app core.App, scanner FileScanner, job *models.Record) error {
+	file, err := app.Dao().FindRecordById("conversation_files", job.GetString("reference_id"))
+	if err != nil {
+		return err
+	}
+	if file.GetBool("is_deleted") || file.GetString("scan_status") != "pending" {
+		return errOutboxJobCancelled
+	}
+
+	fs, err := app.NewFilesystem()
+	if err != nil {
+		return err
+	}
+	defer fs.Close()
+
+	content, err := fs.GetFile(conversationFileKey(file))
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	result, err := scanner.Scan(context.Background(), content)
+	if err != nil {
+		return err
+	}
+
+	file.Set("scanned_at", time.Now())
+	if !result.Clean {
+		log.Printf("conversation file infected id=%s signature=%s", file.Id, result.Signature)
+		file.Set("scan_status", "infected")
+		file.Set("scan_result", result.Signature)
+		return app.Dao().SaveRecord(file)
+	}
+
+	file.Set("scan_status", "clean")
+
+	return app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		if err := txDao.SaveRecord(file); err != nil {
+			return err
+		}
+		return enqueueOutboxJob(txDao, outboxJobShareConversationFile, file.Id, nil)
+	})
+}
+
+// handleShareConversationFile is the outbox handler that posts a clean file
+// into its conversation as a message of the uploader.
+func handleShareConversationFile(app core.App, chat ChatProvider, cfg chatFileConfig, job *models.Record) error {
+	file, err := app.Dao().FindRecordById("conversation_files", job.GetString("reference_id"))
+	if err != nil {
+		return err
+	}
+	if file.GetBool("is_deleted") || file.GetString("scan_status") != "clean" || file.GetString("message_id") != "" {
+		return errOutboxJobCancelled
+	}
+
+	conversation, err := app.Dao().FindRecordById("conversations", file.GetString("conversation_id"))
+	if err != nil {
+		return err
+	}
+	if conversation.GetBool("is_deleted") || conversation.GetString("state") == "archived" {
+		return errOutboxJobCancelled
+	}
+
+	uploader, err := app.Dao().FindRecordById("users", file.GetString("uploader_id"))
+	if err != nil {
+		return err
+	}
+	if uploader.GetBool("is_deleted") || isChatBanned(uploader, time.Now()) {
+		return errOutboxJobCancelled
+	}
+
+	fs, err := app.NewFilesystem()
+	if err != nil {
+		return err
+	}
+	defer fs.Close()
+
+	attrs, err := fs.Attributes(conversationFileKey(file))
+	if err != nil {
+		return err
+	}
+
+	assetURL, _ := cfg.signedURL(app.Settings().Meta.AppUrl, file.Id, time.Now())
+	messageID := "file_" + file.Id
+
+	if err := chat.SendMessage(context.Background(), conversation.GetString("stream_channel_id"), ChatMessage{
+		// a stable id lets the provider drop duplicates when a job is retried
+		ID:     messageID,
+		UserID: uploader.Id,
+		Attachments: []ChatAttachment{{
+			Type:     chatAttachmentType(attrs.ContentType),
+			Title:    file.GetString("name"),
+			AssetURL: assetURL,
+			MimeType: attrs.ContentType,
+			FileSize: attrs.Size,
+		}},
+		Extra: map[string]any{
+			"conversation_file_id": file.Id,
+		},
+	}); err != nil {
+		return err
+	}
+
+	file.Set("message_id", messageID)
+
+	return app.Dao().SaveRecord(file)
+}
+
+func chatAttachmentType(mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return "image"
+	case strings.HasPrefix(mimeType, "video/"):
+		return "video"
+	default:
+		return "file"
+	}
+}
+
+// chatFileURLHandler returns a fresh signed download link for a clean file
+// of one of the caller's conversations.
+func chatFileURLHandler(app core.App, cfg chatFileConfig) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+		if !ok || record == nil {
+			return apis.NewUnauthorizedError("unauthorized", nil)
+		}
+
+		file, err := app.Dao().FindRecordById("conversation_files", c.PathParam("id"))
+		if err != nil || file.GetBool("is_deleted") {
+			return apis.NewNotFoundError("file not found", err)
+		}
+
+		conversation, err := app.Dao().FindRecordById("conversations", file.GetString("conversation_id"))
+		if err != nil {
+			return apis.NewNotFoundError("file not found", err)
+		}
+
+		members, err := conversationMemberIDs(app.Dao(), conversation)
+		if err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to load conversation members", err)
+		}
+		isMember := false
+		for _, id := range members {
+			if id == record.Id {
+				isMember = true
+				break
+			}
+		}
+		if !isMember {
+			return apis.NewForbiddenError("not a member of this conversation", nil)
+		}
+
+		if file.GetString("scan_status") != "clean" {
+			return apis.NewApiError(http.StatusConflict, "file is not available", nil)
+		}
+
+		signed, expiresAt := cfg.signedURL(app.Settings().Meta.AppUrl, file.Id, time.Now())
+
+		return c.JSON(http.StatusOK, map[string]any{
+			"url":        signed,
+			"expires_at": expiresAt.UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+// chatFileDownloadHandler serves a clean file to anyone holding a valid
+// signed link, which is how Stream clients open attachments.
+func chatFileDownloadHandler(app core.App, cfg chatFileConfig) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		fileID := c.PathParam("id")
+		if !cfg.verify(fileID, c.QueryParam("expires"), c.QueryParam("signature"), time.Now()) {
+			return apis.NewForbiddenError("invalid or expired link", nil)
+		}
+
+		file, err := app.Dao().FindRecordById("conversation_files", fileID)
+		if err != nil || file.GetBool("is_deleted") || file.GetString("scan_status") != "clean" {
+			return apis.NewNotFoundError("file not found", err)
+		}
+
+		fs, err := app.NewFilesystem()
+		if err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to open storage", err)
+		}
+		defer fs.Close()
+
+		c.Response().Header().Set("Cache-Control", "private, no-store")
+
+		if err := fs.Serve(c.Response(), c.Request(), conversationFileKey(file), file.GetString("name")); err != nil {
+			return apis.NewNotFoundError("file not found", err)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/forms"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tests"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+)
+
+type fakeFileScanner struct {
+	result  FileScanResult
+	scanned []string
+}
+
+func (s *fakeFileScanner) Scan(ctx context.Context, content io.Reader) (FileScanResult, error) {
+	raw, err := io.ReadAll(content)
+	if err != nil {
+		return FileScanResult{}, err
+	}
+	s.scanned = append(s.scanned, string(raw))
+	return s.result, nil
+}
+
+type chatFileFixture struct {
+	app          *tests.TestApp
+	chat         *memoryChatProvider
+	scanner      *fakeFileScanner
+	worker       *outboxWorker
+	cfg          chatFileConfig
+	proposal     *models.Record
+	conversation *models.Record
+}
+
+func newChatFileFixture(t *testing.T, result FileScanResult) *chatFileFixture {
+	t.Helper()
+
+	app := newTestApp(t)
+	chat := newMemoryChatProvider()
+	scanner := &fakeFileScanner{result: result}
+	cfg := chatFileConfig{URLSecret: []byte("file_secret"), URLTTL: time.Minute}
+
+	worker := newOutboxWorker(app, map[string]outboxHandler{
+		outboxJobScanConversationFile: func(app core.App, job *models.Record) error {
+			return handleScanConversationFile(app, scanner, job)
+		},
+		outboxJobShareConversationFile: func(app core.App, job *models.Record) error {
+			return handleShareConversationFile(app, chat, cfg, job)
+		},
+	})
+
+	proposal, conversation := createTestConversation(t, app)
+	if err := chat.CreateChannel(context.Background(), conversation.GetString("stream_channel_id"), proposal.GetString("client_id"), proposal.GetString("client_id"), proposal.GetString("freelancer_id")); err != nil {
+		t.Fatal(err)
+	}
+
+	return &chatFileFixture{app: app, chat: chat, scanner: scanner, worker: worker, cfg: cfg, proposal: proposal, conversation: conversation}
+}
+
+func (f *chatFileFixture) upload(t *testing.T, uploaderID string, name string, content string) *models.Record {
+	t.Helper()
+
+	col, err := f.app.Dao().FindCollectionByNameOrId("conversation_files")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := models.NewRecord(col)
+	form := forms.NewRecordUpsert(f.app, record)
+	form.LoadData(map[string]any{
+		"conversation_id": f.conversation.Id,
+		"uploader_id":     uploaderID,
+		// the backend owns the scan fields
+		"scan_status": "clean",
+	})
+
+	file, err := filesystem.NewFileFromBytes([]byte(content), name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := form.AddFiles("file", file); err != nil {
+		t.Fatal(err)
+	}
+	if err := form.Submit(); err != nil {
+		t.Fatal(err)
+	}
+
+	return record
+}
+
+func (f *chatFileFixture) reload(t *testing.T, file *models.Record) *models.Record {
+	t.Helper()
+
+	record, err := f.app.Dao().FindRecordById("conversation_files", file.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
+
+func (f *chatFileFixture) canView(t *testing.T, file *models.Record, userID string) bool {
+	t.Helper()
+
+	user, err := f.app.Dao().FindRecordById("users", userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := f.app.Dao().CanAccessRecord(f.reload(t, file), &models.RequestInfo{AuthRecord: user}, file.Collection().ViewRule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ok
+}
+
+func TestConversationFileIsScannedAndShared(t *testing.T) {
+	f := newChatFileFixture(t, FileScanResult{Clean: true})
+	clientID := f.proposal.GetString("client_id")
+	freelancerID := f.proposal.GetString("freelancer_id")
+	outsider := createTestUser(t, f.app, "freelancer", nil)
+
+	file := f.upload(t, freelancerID, "final report.txt", "deliverable")
+
+	if status := f.reload(t, file).GetString("scan_status"); status != "pending" {
+		t.Fatalf("expected pending file, got %q", status)
+	}
+	if !f.canView(t, file, freelancerID) || f.canView(t, file, clientID) {
+		t.Fatal("pending files must only be visible to the uploader")
+	}
+
+	// scan, then share
+	for i := 0; i < 2; i++ {
+		if err := f.worker.RunOnce(time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(f.scanner.scanned) != 1 || f.scanner.scanned[0] != "deliverable" {
+		t.Fatalf("unexpected scans %v", f.scanner.scanned)
+	}
+
+	shared := f.reload(t, file)
+	if shared.GetString("scan_status") != "clean" || shared.GetString("message_id") != "file_"+file.Id {
+		t.Fatalf("unexpected file state %v", shared.PublicExport())
+	}
+	if shared.GetString("name") != "final_report.txt" {
+		t.Fatalf("expected display name without suffix, got %q", shared.GetString("name"))
+	}
+	if !f.canView(t, file, clientID) || f.canView(t, file, outsider.Id) {
+		t.Fatal("clean files must be visible to both participants only")
+	}
+
+	channel, _ := f.chat.Channel(f.conversation.GetString("stream_channel_id"))
+	if len(channel.Messages) != 1 || len(channel.Messages[0].Attachments) != 1 {
+		t.Fatalf("expected one message with an attachment, got %+v", channel.Messages)
+	}
+	message := channel.Messages[0]
+	if message.UserID != freelancerID || message.Extra["conversation_file_id"] != file.Id {
+		t.Fatalf("unexpected message %+v", message)
+	}
+
+	link, err := url.Parse(message.Attachments[0].AssetURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	download := chatFileDownloadHandler(f.app, f.cfg)
+	idParam := echo.PathParam{Name: "id", Value: file.Id}
+
+	code, rec := callHandler(t, download, http.MethodGet, link.RequestURI(), nil, nil, nil, idParam)
+	if code != http.StatusOK || rec.Body.String() != "deliverable" {
+		t.Fatalf("expected the file, got %d: %s", code, rec.Body.String())
+	}
+
+	tampered := link.Query()
+	tampered.Set("expires", "9999999999")
+	if code, _ := callHandler(t, download, http.MethodGet, link.Path+"?"+tampered.Encode(), nil, nil, nil, idParam); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a tampered link, got %d", code)
+	}
+
+	expired, _ := f.cfg.signedURL("", file.Id, time.Now().Add(-2*time.Minute))
+	if code, _ := callHandler(t, download, http.MethodGet, expired, nil, nil, nil, idParam); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for an expired link, got %d", code)
+	}
+}
+
+func TestConversationFileURLHandler(t *testing.T) {
+	f := newChatFileFixture(t, FileScanResult{Clean: true})
+	clientID := f.proposal.GetString("client_id")
+	outsider := createTestUser(t, f.app, "client", nil)
+
+	file := f.upload(t, clientID, "brief.txt", "brief")
+	handler := chatFileURLHandler(f.app, f.cfg)
+	idParam := echo.PathParam{Name: "id", Value: file.Id}
+
+	client, err := f.app.Dao().FindRecordById("users", clientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code, _ := callHandler(t, handler, http.MethodGet, "/chat/files/"+file.Id+"/url", nil, nil, client, idParam); code != http.StatusConflict {
+		t.Fatalf("expected 409 before the scan, got %d", code)
+	}
+
+	if err := f.worker.RunOnce(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	if code, _ := callHandler(t, handler, http.MethodGet, "/chat/files/"+file.Id+"/url", nil, nil, outsider, idParam); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a non member, got %d", code)
+	}
+
+	code, rec := callHandler(t, handler, http.MethodGet, "/chat/files/"+file.Id+"/url", nil, nil, client, idParam)
+	if code != http.StatusOK || !strings.Contains(rec.Body.String(), "/chat/files/"+file.Id+"/download?") {
+		t.Fatalf("expected a signed url, got %d: %s", code, rec.Body.String())
+	}
+}
+
+func TestInfectedConversationFileIsNotShared(t *testing.T) {
+	f := newChatFileFixture(t, FileScanResult{Signature: "Eicar-Test-Signature"})
+	freelancerID := f.proposal.GetString("freelancer_id")
+
+	file := f.upload(t, freelancerID, "payload.txt", "X5O!P%@AP")
+	if err := f.worker.RunOnce(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	infected := f.reload(t, file)
+	if infected.GetString("scan_status") != "infected" || infected.GetString("scan_result") != "Eicar-Test-Signature" {
+		t.Fatalf("unexpected file state %v", infected.PublicExport())
+	}
+	if f.canView(t, file, f.proposal.GetString("client_id")) {
+		t.Fatal("infected files must not be visible to the other participant")
+	}
+
+	if jobs := findOutboxJobsByType(t, f.app, outboxJobShareConversationFile); len(jobs) != 0 {
+		t.Fatal("infected files must not be shared")
+	}
+
+	if code, _ := callHandler(t, chatFileDownloadHandler(f.app, f.cfg), http.MethodGet, mustSignedPath(f.cfg, file.Id), nil, nil, nil, echo.PathParam{Name: "id", Value: file.Id}); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an infected file, got %d", code)
+	}
+}
+
+func mustSignedPath(cfg chatFileConfig, fileID string) string {
+	signed, _ := cfg.signedURL("", fileID, time.Now())
+	return signed
+}
+
+func TestParseClamdReply(t *testing.T) {
+	scenarios := []struct {
+		reply     string
+		expected  FileScanResult
+		expectErr bool
+	}{
+		{"stream: OK\x00", FileScanResult{Clean: true}, false},
+		{"stream: Eicar-Test-Signature FOUND\x00", FileScanResult{Signature: "Eicar-Test-Signature"}, false},
+		{"INSTREAM size limit exceeded. ERROR\x00", FileScanResult{}, true},
+	}
+
+	for _, s := range scenarios {
+		result, err := parseClamdReply(s.reply)
+		if (err != nil) != s.expectErr {
+			t.Fatalf("%q: unexpected error %v", s.reply, err)
+		}
+		if result != s.expected {
+			t.Fatalf("%q: expected %+v, got %+v", s.reply, s.expected, result)
+		}
+	}
+}
+
+func TestNewFileScanner(t *testing.T) {
+	scenarios := []struct {
+		value     string
+		expectErr bool
+	}{
+		{"", true},
+		{"none", false},
+		{"clamd", false},
+		{"virustotal", true},
+	}
+
+	for _, s := range scenarios {
+		t.Setenv("FILE_SCANNER", s.value)
+		if _, err := newFileScanner(); (err != nil) != s.expectErr {
+			t.Fatalf("FILE_SCANNER=%q: unexpected error %v", s.value, err)
+		}
+	}
+}
+
+func TestLoadChatFileConfigSecret(t *testing.T) {
+	t.Setenv("CHAT_FILE_URL_TTL", "")
+
+	t.Setenv("CHAT_FILE_URL_SECRET", "")
+	if _, err := loadChatFileConfig(false); err == nil {
+		t.Fatal("expected a missing secret to fail outside dev mode")
+	}
+	if cfg, err := loadChatFileConfig(true); err != nil || len(cfg.URLSecret) == 0 {
+		t.Fatalf("expected a random secret in dev mode, got %v", err)
+	}
+
+	t.Setenv("CHAT_FILE_URL_SECRET", "secret")
+	if cfg, err := loadChatFileConfig(false); err != nil || string(cfg.URLSecret) != "secret" {
+		t.Fatalf("expected the configured secret, got %v", err)
+	}
+}
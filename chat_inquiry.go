@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+const (
+	outboxJobSyncInquiryRestriction = "sync_inquiry_restriction"
+
+	defaultChatInquiryCooldown      = 30 * time.Second
+	defaultChatInquiryMaxUnanswered = 3
+)
+
+// chatInquiryConfig controls pre-acceptance conversations. Either member may
+// send MaxUnanswered messages in a row; after that the sender is read-only
+// until the other member replies. Cooldown is the Stream slow mode applied
+// to both members while the inquiry lasts.
+type chatInquiryConfig struct {
+	Enabled       bool
+	Cooldown      time.Duration
+	MaxUnanswered int
+}
+
+func loadChatInquiryConfig() (chatInquiryConfig, error) {
+	cfg := chatInquiryConfig{
+		Enabled:       true,
+		Cooldown:      defaultChatInquiryCooldown,
+		MaxUnanswered: defaultChatInquiryMaxUnanswered,
+	}
+
+	if value := strings.TrimSpace(os.Getenv("CHAT_INQUIRIES_ENABLED")); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return chatInquiryConfig{}, errors.New("CHAT_INQUIRIES_ENABLED must be a boolean")
+		}
+		cfg.Enabled = enabled
+	}
+
+	if value := strings.TrimSpace(os.Getenv("CHAT_INQUIRY_COOLDOWN")); value != "" {
+		cooldown, err := time.ParseDuration(value)
+		if err != nil || cooldown < 0 {
+			return chatInquiryConfig{}, errors.New("CHAT_INQUIRY_COOLDOWN must be a non-negative duration")
+		}
+		cfg.Cooldown = cooldown
+	}
+
+	if value := strings.TrimSpace(os.Getenv("CHAT_INQUIRY_MAX_UNANSWERED")); value != "" {
+		max, err := strconv.Atoi(value)
+		if err != nil || max < 1 {
+			return chatInquiryConfig{}, errors.New("CHAT_INQUIRY_MAX_UNANSWERED must be a positive integer")
+		}
+		cfg.MaxUnanswered = max
+	}
+
+	return cfg, nil
+}
+
+type chatInquiryRequest struct {
+	ProposalID string `json:"proposal_id"`
+}
+
+// chatInquiryHandler opens (or returns the existing) inquiry conversation
+// between a client and the freelancer of an open proposal. The channel uses
+// the proposal channel id, so it simply becomes the regular conversation
+// once the proposal is accepted.
+func chatInquiryHandler(app core.App, chat ChatProvider, cfg chatInquiryConfig) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		if !cfg.Enabled {
+			return apis.NewNotFoundError("inquiries are disabled", nil)
+		}
+
+		record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+		if !ok || record == nil {
+			return apis.NewUnauthorizedError("unauthorized", nil)
+		}
+		if record.GetString("role") != "client" {
+			return apis.NewForbiddenError("only clients can open inquiries", nil)
+		}
+		if isChatBanned(record, time.Now()) {
+			return apis.NewForbiddenError("chat access is suspended", nil)
+		}
+
+		var payload chatInquiryRequest
+		if err := c.Bind(&payload); err != nil {
+			return apis.NewBadRequestError("invalid request body", err)
+		}
+		if payload.ProposalID == "" {
+			return apis.NewBadRequestError("proposal_id is required", nil)
+		}
+
+		proposal, err := app.Dao().FindRecordById("proposals", payload.ProposalID)
+		if err != nil || proposal.GetBool("is_deleted") {
+			return apis.NewNotFoundError("proposal not found", err)
+		}
+		if proposal.GetString("client_id") != record.Id {
+			return apis.NewForbiddenError("not allowed to open an inquiry for this proposal", nil)
+		}
+
+		existing, err := app.Dao().FindFirstRecordByFilter(
+			"conversations",
+			"proposal_id = {:pid} && is_deleted = false",
+			dbx.Params{"pid": proposal.Id},
+		)
+		if err == nil {
+			return c.JSON(http.StatusOK, inquiryResponse(existing))
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to load conversation", err)
+		}
+
+		if !isOpenProposal(proposal) {
+			return apis.NewApiError(http.StatusConflict, "inquiries are only possible for open proposals", nil)
+		}
+
+		clientId := proposal.GetString("client_id")
+		freelancerId := proposal.GetString("freelancer_id")
+		channelId := chatChannelID(proposal.Id)
+		ctx := context.Background()
+
+		users, err := findChatUsers(app, clientId, freelancerId)
+		if err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to load chat users", err)
+		}
+		if err := chat.UpsertUsers(ctx, users...); err != nil {
+			return apis.NewApiError(http.StatusBadGateway, "failed to sync chat users", err)
+		}
+		if err := chat.CreateChannel(ctx, channelId, clientId, clientId, freelancerId); err != nil {
+			return apis.NewApiError(http.StatusBadGateway, "failed to create chat channel", err)
+		}
+		if err := chat.UpdateChannel(ctx, channelId, map[string]any{
+			"cooldown": int(cfg.Cooldown.Seconds()),
+		}); err != nil {
+			return apis.NewApiError(http.StatusBadGateway, "failed to configure chat channel", err)
+		}
+
+		collection, err := app.Dao().FindCollectionByNameOrId("conversations")
+		if err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "conversations collection not found", err)
+		}
+
+		conversation := models.NewRecord(collection)
+		conversation.Set("project_id", proposal.GetString("project_id"))
+		conversation.Set("proposal_id", proposal.Id)
+		conversation.Set("stream_channel_id", channelId)
+		conversation.Set("type", "inquiry")
+		conversation.Set("state", "active")
+		conversation.Set("unanswered_count", 0)
+		conversation.Set("is_deleted", false)
+
+		if err := app.Dao().SaveRecord(conversation); err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to save conversation", err)
+		}
+
+		return c.JSON(http.StatusCreated, inquiryResponse(conversation))
+	}
+}
+
+func inquiryResponse(conversation *models.Record) map[string]any {
+	return map[string]any{
+		"conversation_id":   conversation.Id,
+		"stream_channel_id": conversation.GetString("stream_channel_id"),
+		"type":              conversation.GetString("type"),
+		"read_only_user_id": conversation.GetString("read_only_user_id"),
+	}
+}
+
+// applyInquiryMessage counts the messages one member of an inquiry sends in
+// a row and queues a restriction sync when the read-only member changes: the
+// sender once the count reaches MaxUnanswered, nobody once the other member
+// replies.
+func applyInquiryMessage(dao *daos.Dao, cfg chatInquiryConfig, conversation *models.Record, senderID string, sentAt time.Time) error {
+	if conversation.GetString("type") != "inquiry" || senderID == "" {
+		return nil
+	}
+
+	proposal, err := dao.FindRecordById("proposals", conversation.GetString("proposal_id"))
+	if err != nil {
+		return err
+	}
+	if senderID != proposal.GetString("client_id") && senderID != proposal.GetString("freelancer_id") {
+		return nil
+	}
+
+	if senderID == proposal.GetString("freelancer_id") && conversation.GetDateTime("replied_at").IsZero() {
+		conversation.Set("replied_at", sentAt)
+	}
+
+	readOnly := conversation.GetString("read_only_user_id")
+
+	unanswered := 1
+	if conversation.GetString("unanswered_by") == senderID {
+		unanswered = conversation.GetInt("unanswered_count") + 1
+	}
+	conversation.Set("unanswered_by", senderID)
+	conversation.Set("unanswered_count", unanswered)
+	if unanswered >= cfg.MaxUnanswered {
+		conversation.Set("read_only_user_id", senderID)
+	} else {
+		conversation.Set("read_only_user_id", "")
+	}
+
+	if conversation.GetString("read_only_user_id") == readOnly {
+		return nil
+	}
+
+	return enqueueOutboxJob(dao, outboxJobSyncInquiryRestriction, conversation.Id, nil)
+}
+
+// handleSyncInquiryRestriction is the outbox handler that mirrors
+// read_only_user_id to channel bans: the read-only member is banned, the
+// other one is not. It reads the current state, so a single job covers any
+// number of changes.
+func handleSyncInquiryRestriction(app core.App, chat ChatProvider, systemUserID string, job *models.Record) error {
+	conversation, err := app.Dao().FindRecordById("conversations", job.GetString("reference_id"))
+	if err != nil {
+		return err
+	}
+
+	proposal, err := app.Dao().FindRecordById("proposals", conversation.GetString("proposal_id"))
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	channelId := conversation.GetString("stream_channel_id")
+	readOnly := ""
+	if conversation.GetString("type") == "inquiry" {
+		readOnly = conversation.GetString("read_only_user_id")
+	}
+
+	for _, memberId := range []string{proposal.GetString("client_id"), proposal.GetString("freelancer_id")} {
+		if memberId == readOnly {
+			continue
+		}
+		if err := chat.UnbanChannelMember(ctx, channelId, memberId); err != nil {
+			return err
+		}
+	}
+	if readOnly == "" {
+		return nil
+	}
+
+	if err := chat.UpsertUsers(ctx, ChatUser{ID: systemUserID, Name: "Marketplace"}); err != nil {
+		return err
+	}
+
+	return chat.BanChannelMember(ctx, channelId, readOnly, ChatBan{
+		BannedBy: systemUserID,
+		Reason:   "waiting for the other member to reply",
+	})
+}
+
+// upgradeInquiryConversation turns an inquiry into the regular conversation
+// of its now accepted proposal, lifting the inquiry limits.
+func upgradeInquiryConversation(app core.App, chat ChatProvider, conversation *models.Record) error {
+	ctx := context.Background()
+	channelId := conversation.GetString("stream_channel_id")
+
+	if err := chat.UpdateChannel(ctx, channelId, map[string]any{"cooldown": 0}); err != nil {
+		return err
+	}
+
+	if readOnly := conversation.GetString("read_only_user_id"); readOnly != "" {
+		if err := chat.UnbanChannelMember(ctx, channelId, readOnly); err != nil {
+			return err
+		}
+	}
+
+	conversation.Set("type", "proposal")
+	conversation.Set("read_only_user_id", "")
+
+	return app.Dao().SaveRecord(conversation)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+func TestChatInquiryHandler(t *testing.T) {
+	app := newTestApp(t)
+	chat := newMemoryChatProvider()
+	cfg := chatInquiryConfig{Enabled: true, Cooldown: 30 * time.Second, MaxUnanswered: 2}
+	handler := chatInquiryHandler(app, chat, cfg)
+
+	proposal := createTestProposal(t, app)
+	client, err := app.Dao().FindRecordById("users", proposal.GetString("client_id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	freelancer, err := app.Dao().FindRecordById("users", proposal.GetString("freelancer_id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherClient := createTestUser(t, app, "client", nil)
+
+	body, _ := json.Marshal(map[string]any{"proposal_id": proposal.Id})
+
+	if code, _ := callHandler(t, handler, http.MethodPost, "/chat/inquiries", body, nil, freelancer); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for the freelancer, got %d", code)
+	}
+	if code, _ := callHandler(t, handler, http.MethodPost, "/chat/inquiries", body, nil, otherClient); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for another client, got %d", code)
+	}
+
+	code, rec := callHandler(t, handler, http.MethodPost, "/chat/inquiries", body, nil, client)
+	if code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", code, rec.Body.String())
+	}
+
+	channel, ok := chat.Channel(chatChannelID(proposal.Id))
+	if !ok {
+		t.Fatal("expected inquiry channel")
+	}
+	if channel.Data["cooldown"] != 30 {
+		t.Fatalf("expected cooldown 30, got %v", channel.Data["cooldown"])
+	}
+	if !sameMembers(channel.Members, []string{client.Id, freelancer.Id}) {
+		t.Fatalf("unexpected members %v", channel.Members)
+	}
+
+	if code, _ := callHandler(t, handler, http.MethodPost, "/chat/inquiries", body, nil, client); code != http.StatusOK {
+		t.Fatalf("expected 200 for an existing inquiry, got %d", code)
+	}
+
+	rejected := createTestProposal(t, app)
+	rejected.Set("status", "rejected")
+	if err := app.Dao().SaveRecord(rejected); err != nil {
+		t.Fatal(err)
+	}
+	rejectedClient, err := app.Dao().FindRecordById("users", rejected.GetString("client_id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = json.Marshal(map[string]any{"proposal_id": rejected.Id})
+	if code, _ := callHandler(t, handler, http.MethodPost, "/chat/inquiries", body, nil, rejectedClient); code != http.StatusConflict {
+		t.Fatalf("expected 409 for a rejected proposal, got %d", code)
+	}
+
+	disabled := chatInquiryHandler(app, chat, chatInquiryConfig{})
+	if code, _ := callHandler(t, disabled, http.MethodPost, "/chat/inquiries", body, nil, rejectedClient); code != http.StatusNotFound {
+		t.Fatalf("expected 404 when disabled, got %d", code)
+	}
+}
+
+func TestChatInquiryRestrictionAndUpgrade(t *testing.T) {
+	app := newTestApp(t)
+	chat := newMemoryChatProvider()
+	cfg := chatInquiryConfig{Enabled: true, Cooldown: 30 * time.Second, MaxUnanswered: 2}
+	webhook := streamWebhookHandler(app, testStreamSecret, cfg)
+
+	worker := newOutboxWorker(app, map[string]outboxHandler{
+		outboxJobCreateConversation: func(app core.App, job *models.Record) error {
+			return handleProposalAcceptance(app, chat, job)
+		},
+		outboxJobSyncInquiryRestriction: func(app core.App, job *models.Record) error {
+			return handleSyncInquiryRestriction(app, chat, defaultChatSystemUserID, job)
+		},
+	})
+
+	proposal := createTestProposal(t, app)
+	clientID := proposal.GetString("client_id")
+	freelancerID := proposal.GetString("freelancer_id")
+	client, err := app.Dao().FindRecordById("users", clientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := json.Marshal(map[string]any{"proposal_id": proposal.Id})
+	if code, rec := callHandler(t, chatInquiryHandler(app, chat, cfg), http.MethodPost, "/chat/inquiries", body, nil, client); code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", code, rec.Body.String())
+	}
+
+	channelID := chatChannelID(proposal.Id)
+	now := time.Now().UTC()
+
+	send := func(id string, senderID string, at time.Time) {
+		t.Helper()
+		body, header := signStreamWebhook(t, streamMessageEvent(channelID, id, senderID, "Hello", at))
+		if code, rec := callHandler(t, webhook, http.MethodPost, "/stream/webhook", body, header, nil); code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", code, rec.Body.String())
+		}
+		if err := worker.RunOnce(at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	readOnly := func(want ...string) {
+		t.Helper()
+		channel, _ := chat.Channel(channelID)
+		if !sameMembers(channel.Banned, want) {
+			t.Fatalf("expected banned members %v, got %v", want, channel.Banned)
+		}
+	}
+
+	send("m1", clientID, now)
+	readOnly()
+
+	send("m2", clientID, now.Add(time.Minute))
+	readOnly(clientID)
+
+	send("m3", freelancerID, now.Add(2*time.Minute))
+	readOnly()
+
+	conversation, err := app.Dao().FindFirstRecordByData("conversations", "proposal_id", proposal.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conversation.GetDateTime("replied_at").IsZero() || conversation.GetString("read_only_user_id") != "" {
+		t.Fatalf("unexpected inquiry state %v", conversation.PublicExport())
+	}
+
+	// the limit applies to the freelancer as well, until the client replies
+	send("m4", freelancerID, now.Add(3*time.Minute))
+	readOnly(freelancerID)
+
+	conversation, err = app.Dao().FindRecordById("conversations", conversation.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conversation.GetString("read_only_user_id") != freelancerID {
+		t.Fatalf("expected the freelancer to be read-only, got %q", conversation.GetString("read_only_user_id"))
+	}
+
+	send("m5", clientID, now.Add(4*time.Minute))
+	readOnly()
+
+	send("m6", clientID, now.Add(5*time.Minute))
+	readOnly(clientID)
+
+	proposal, err = app.Dao().FindRecordById("proposals", proposal.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proposal.Set("status", "accepted")
+	if err := app.Dao().SaveRecord(proposal); err != nil {
+		t.Fatal(err)
+	}
+	if err := worker.RunOnce(now.Add(6 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	conversations, err := app.Dao().FindRecordsByExpr("conversations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conversations) != 1 {
+		t.Fatalf("expected the inquiry to be reused, got %d conversations", len(conversations))
+	}
+	if conversations[0].GetString("type") != "proposal" {
+		t.Fatalf("expected upgraded conversation, got type %q", conversations[0].GetString("type"))
+	}
+
+	if conversations[0].GetString("read_only_user_id") != "" {
+		t.Fatalf("expected the restriction to be cleared, got %q", conversations[0].GetString("read_only_user_id"))
+	}
+	readOnly()
+
+	channel, _ := chat.Channel(channelID)
+	if channel.Data["cooldown"] != 0 {
+		t.Fatalf("expected slow mode to be disabled, got %v", channel.Data["cooldown"])
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+const (
+	outboxJobFreezeConversation   = "freeze_conversation"
+	outboxJobUnfreezeConversation = "unfreeze_conversation"
+	outboxJobArchiveConversation  = "archive_conversation"
+	outboxJobRemoveChatMember     = "remove_chat_member"
+
+	defaultChatArchiveAfterDays = 30
+)
+
+type chatLifecycleConfig struct {
+	FreezeOnProjectClose     bool
+	FreezeOnProposalUnaccept bool
+	RemoveDeletedMembers     bool
+	// ArchiveAfterDays archives frozen channels after the given number of
+	// days; 0 disables archiving.
+	ArchiveAfterDays int
+}
+
+func loadChatLifecycleConfig() (chatLifecycleConfig, error) {
+	cfg := chatLifecycleConfig{
+		FreezeOnProjectClose:     true,
+		FreezeOnProposalUnaccept: true,
+		RemoveDeletedMembers:     true,
+		ArchiveAfterDays:         defaultChatArchiveAfterDays,
+	}
+
+	bools := []struct {
+		env    string
+		target *bool
+	}{
+		{"CHAT_FREEZE_ON_PROJECT_CLOSE", &cfg.FreezeOnProjectClose},
+		{"CHAT_FREEZE_ON_PROPOSAL_UNACCEPT", &cfg.FreezeOnProposalUnaccept},
+		{"CHAT_REMOVE_DELETED_MEMBERS", &cfg.RemoveDeletedMembers},
+	}
+	for _, b := range bools {
+		value := strings.TrimSpace(os.Getenv(b.env))
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return chatLifecycleConfig{}, errors.New(b.env + " must be a boolean")
+		}
+		*b.target = parsed
+	}
+
+	if value := strings.TrimSpace(os.Getenv("CHAT_ARCHIVE_AFTER_DAYS")); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 {
+			return chatLifecycleConfig{}, errors.New("CHAT_ARCHIVE_AFTER_DAYS must be a non-negative integer")
+		}
+		cfg.ArchiveAfterDays = days
+	}
+
+	return cfg, nil
+}
+
+// enqueueProjectLifecycle freezes every active conversation of a project
+// that has just been closed, and unfreezes the conversations that closing
+// froze once the project is reopened.
+func enqueueProjectLifecycle(dao *daos.Dao, cfg chatLifecycleConfig, project *models.Record) error {
+	if project.IsNew() {
+		return nil
+	}
+
+	previous := project.OriginalCopy().GetString("status")
+	status := project.GetString("status")
+
+	var (
+		jobType string
+		filter  string
+		payload map[string]any
+	)
+	switch {
+	case status == "closed" && previous != "closed" && cfg.FreezeOnProjectClose:
+		jobType = outboxJobFreezeConversation
+		filter = "state = 'active'"
+		payload = map[string]any{"reason": "project_closed"}
+	case previous == "closed" && status != "closed":
+		// not tied to FreezeOnProjectClose: channels frozen before the
+		// flag was turned off are still restored
+		jobType = outboxJobUnfreezeConversation
+		filter = "state = 'frozen' && state_reason = 'project_closed'"
+	default:
+		return nil
+	}
+
+	conversations, err := dao.FindRecordsByFilter(
+		"conversations",
+		"project_id = {:pid} && is_deleted = false && "+filter,
+		"",
+		0,
+		0,
+		dbx.Params{"pid": project.Id},
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, conversation := range conversations {
+		if err := enqueueOutboxJob(dao, jobType, conversation.Id, payload); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// enqueueProposalLifecycle freezes the conversation of a proposal that is
+// no longer accepted, and the inquiry of an open proposal that was rejected,
+// withdrawn or deleted.
+func enqueueProposalLifecycle(dao *daos.Dao, cfg chatLifecycleConfig, proposal *models.Record) error {
+	if !cfg.FreezeOnProposalUnaccept || proposal.IsNew() {
+		return nil
+	}
+
+	previous := proposal.OriginalCopy().GetString("status")
+	status := proposal.GetString("status")
+	if proposal.GetBool("is_deleted") {
+		status = ""
+	}
+	if previous == status || status == "accepted" {
+		return nil
+	}
+
+	var reason string
+	switch {
+	case previous == "accepted":
+		reason = "proposal_unaccepted"
+	case (previous == "sent" || previous == "countered") && status != "sent" && status != "countered":
+		reason = "proposal_declined"
+	default:
+		return nil
+	}
+
+	conversation, err := dao.FindFirstRecordByFilter(
+		"conversations",
+		"proposal_id = {:pid} && is_deleted = false && state = 'active'",
+		dbx.Params{"pid": proposal.Id},
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	return enqueueOutboxJob(dao, outboxJobFreezeConversation, conversation.Id, map[string]any{
+		"reason": reason,
+	})
+}
+
+// enqueueUserLifecycle removes a soft-deleted user from all their channels.
+func enqueueUserLifecycle(dao *daos.Dao, cfg chatLifecycleConfig, user *models.Record) error {
+	if !cfg.RemoveDeletedMembers || user.IsNew() {
+		return nil
+	}
+	if !user.GetBool("is_deleted") || user.OriginalCopy().GetBool("is_deleted") {
+		return nil
+	}
+
+	conversations, err := dao.FindRecordsByFilter(
+		"conversations",
+		"is_deleted = false && state != 'archived' && (proposal_id.client_id = {:uid} || proposal_id.freelancer_id = {:uid})",
+		"",
+		0,
+		0,
+		dbx.Params{"uid": user.Id},
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, conversation := range conversations {
+		if err := enqueueOutboxJob(dao, outboxJobRemoveChatMember, conversation.Id+":"+user.Id, map[string]any{
+			"conversation_id": conversation.Id,
+			"user_id":         user.Id,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// handleFreezeConversation is the outbox handler that freezes a channel and
+// schedules its archiving.
+func handleFreezeConversation(app core.App, chat ChatProvider, cfg chatLifecycleConfig, job *models.Record) error {
+	conversation, err := app.Dao().FindRecordById("conversations", job.GetString("reference_id"))
+	if err != nil {
+		return err
+	}
+	if conversation.GetString("state") != "active" {
+		return errOutboxJobCancelled
+	}
+
+	if err := chat.FreezeChannel(context.Background(), conversation.GetString("stream_channel_id"), true); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	payload := outboxJobPayload(job)
+
+	conversation.Set("state", "frozen")
+	conversation.Set("state_reason", payload["reason"])
+	conversation.Set("frozen_at", now)
+
+	return app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		if err := txDao.SaveRecord(conversation); err != nil {
+			return err
+		}
+		if cfg.ArchiveAfterDays <= 0 {
+			return nil
+		}
+		return enqueueOutboxJobAt(txDao, outboxJobArchiveConversation, conversation.Id, nil, now.AddDate(0, 0, cfg.ArchiveAfterDays))
+	})
+}
+
+// handleUnfreezeConversation is the outbox handler that reopens a channel
+// frozen by the closing of its project and drops its pending archiving.
+func handleUnfreezeConversation(app core.App, chat ChatProvider, job *models.Record) error {
+	conversation, err := app.Dao().FindRecordById("conversations", job.GetString("reference_id"))
+	if err != nil {
+		return err
+	}
+	if conversation.GetString("state") != "frozen" || conversation.GetString("state_reason") != "project_closed" {
+		return errOutboxJobCancelled
+	}
+
+	if err := chat.FreezeChannel(context.Background(), conversation.GetString("stream_channel_id"), false); err != nil {
+		return err
+	}
+
+	conversation.Set("state", "active")
+	conversation.Set("state_reason", "")
+	conversation.Set("frozen_at", "")
+
+	return app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		if err := txDao.SaveRecord(conversation); err != nil {
+			return err
+		}
+
+		// a later closing schedules its own grace period
+		archiving, err := txDao.FindRecordsByFilter(
+			"outbox_jobs",
+			"type = {:type} && reference_id = {:ref} && status = 'pending' && is_deleted = false",
+			"",
+			0,
+			0,
+			dbx.Params{"type": outboxJobArchiveConversation, "ref": conversation.Id},
+		)
+		if err != nil {
+			return err
+		}
+		for _, job := range archiving {
+			job.Set("status", "cancelled")
+			job.Set("last_error", "conversation unfrozen")
+			job.Set("processed_at", time.Now())
+			if err := txDao.SaveRecord(job); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// handleArchiveConversation is the outbox handler that archives a channel
+// that is still frozen once its grace period has passed.
+func handleArchiveConversation(app core.App, chat ChatProvider, job *models.Record) error {
+	conversation, err := app.Dao().FindRecordById("conversations", job.GetString("reference_id"))
+	if err != nil {
+		return err
+	}
+	if conversation.GetString("state") != "frozen" {
+		return errOutboxJobCancelled
+	}
+
+	if err := chat.ArchiveChannel(context.Background(), conversation.GetString("stream_channel_id")); err != nil {
+		return err
+	}
+
+	conversation.Set("state", "archived")
+	conversation.Set("archived_at", time.Now())
+
+	return app.Dao().SaveRecord(conversation)
+}
+
+// handleRemoveChatMember is the outbox handler that removes a soft-deleted
+// user from a channel.
+func handleRemoveChatMember(app core.App, chat ChatProvider, job *models.Record) error {
+	payload := outboxJobPayload(job)
+	userID, _ := payload["user_id"].(string)
+	conversationID, _ := payload["conversation_id"].(string)
+
+	conversation, err := app.Dao().FindRecordById("conversations", conversationID)
+	if err != nil {
+		return err
+	}
+
+	if err := chat.RemoveMembers(context.Background(), conversation.GetString("stream_channel_id"), userID); err != nil {
+		return err
+	}
+
+	removed := []string{}
+	_ = conversation.UnmarshalJSONField("removed_members", &removed)
+	for _, id := range removed {
+		if id == userID {
+			return nil
+		}
+	}
+	conversation.Set("removed_members", append(removed, userID))
+
+	return app.Dao().SaveRecord(conversation)
+}
+
+func outboxJobPayload(job *models.Record) map[string]any {
+	payload := map[string]any{}
+	_ = job.UnmarshalJSONField("payload", &payload)
+	return payload
+}
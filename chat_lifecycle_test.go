@@ -0,0 +1,222 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+type lifecycleFixture struct {
+	app          *tests.TestApp
+	chat         *memoryChatProvider
+	worker       *outboxWorker
+	proposal     *models.Record
+	conversation *models.Record
+}
+
+func newLifecycleFixture(t *testing.T, cfg chatLifecycleConfig) *lifecycleFixture {
+	t.Helper()
+
+	app := newTestAppWithConfig(t, hooksConfig{Lifecycle: cfg})
+	chat := newMemoryChatProvider()
+
+	worker := newOutboxWorker(app, map[string]outboxHandler{
+		outboxJobCreateConversation: func(app core.App, job *models.Record) error {
+			return handleProposalAcceptance(app, chat, job)
+		},
+		outboxJobFreezeConversation: func(app core.App, job *models.Record) error {
+			return handleFreezeConversation(app, chat, cfg, job)
+		},
+		outboxJobUnfreezeConversation: func(app core.App, job *models.Record) error {
+			return handleUnfreezeConversation(app, chat, job)
+		},
+		outboxJobArchiveConversation: func(app core.App, job *models.Record) error {
+			return handleArchiveConversation(app, chat, job)
+		},
+		outboxJobRemoveChatMember: func(app core.App, job *models.Record) error {
+			return handleRemoveChatMember(app, chat, job)
+		},
+	})
+
+	proposal := createTestProposal(t, app)
+	proposal.Set("status", "accepted")
+	if err := app.Dao().SaveRecord(proposal); err != nil {
+		t.Fatal(err)
+	}
+	if err := worker.RunOnce(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	conversation, err := app.Dao().FindFirstRecordByData("conversations", "proposal_id", proposal.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &lifecycleFixture{app: app, chat: chat, worker: worker, proposal: proposal, conversation: conversation}
+}
+
+func (f *lifecycleFixture) run(t *testing.T, now time.Time) *models.Record {
+	t.Helper()
+
+	if err := f.worker.RunOnce(now); err != nil {
+		t.Fatal(err)
+	}
+
+	conversation, err := f.app.Dao().FindRecordById("conversations", f.conversation.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return conversation
+}
+
+func TestChatLifecycleProjectClosedFreezesThenArchives(t *testing.T) {
+	cfg := chatLifecycleConfig{FreezeOnProjectClose: true, ArchiveAfterDays: 7}
+	f := newLifecycleFixture(t, cfg)
+
+	project, err := f.app.Dao().FindRecordById("projects", f.proposal.GetString("project_id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	project.Set("status", "closed")
+	if err := f.app.Dao().SaveRecord(project); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	conversation := f.run(t, now)
+	if conversation.GetString("state") != "frozen" || conversation.GetString("state_reason") != "project_closed" {
+		t.Fatalf("expected frozen conversation, got %v", conversation.PublicExport())
+	}
+	if channel, _ := f.chat.Channel(conversation.GetString("stream_channel_id")); !channel.Frozen {
+		t.Fatal("expected frozen channel")
+	}
+
+	// archiving waits for the configured grace period
+	if conversation := f.run(t, now.AddDate(0, 0, 6)); conversation.GetString("state") != "frozen" {
+		t.Fatalf("expected conversation to stay frozen, got %q", conversation.GetString("state"))
+	}
+
+	conversation = f.run(t, now.AddDate(0, 0, 7).Add(time.Minute))
+	if conversation.GetString("state") != "archived" || conversation.GetDateTime("archived_at").IsZero() {
+		t.Fatalf("expected archived conversation, got %v", conversation.PublicExport())
+	}
+	if channel, _ := f.chat.Channel(conversation.GetString("stream_channel_id")); !channel.Archived {
+		t.Fatal("expected archived channel")
+	}
+}
+
+func TestChatLifecycleProjectReopenedUnfreezes(t *testing.T) {
+	cfg := chatLifecycleConfig{FreezeOnProjectClose: true, FreezeOnProposalUnaccept: true, ArchiveAfterDays: 7}
+	f := newLifecycleFixture(t, cfg)
+
+	setStatus := func(status string) {
+		t.Helper()
+		project, err := f.app.Dao().FindRecordById("projects", f.proposal.GetString("project_id"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		project.Set("status", status)
+		if err := f.app.Dao().SaveRecord(project); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	setStatus("closed")
+	now := time.Now()
+	if conversation := f.run(t, now); conversation.GetString("state") != "frozen" {
+		t.Fatalf("expected frozen conversation, got %q", conversation.GetString("state"))
+	}
+
+	setStatus("open")
+	conversation := f.run(t, now.Add(time.Minute))
+	if conversation.GetString("state") != "active" || conversation.GetString("state_reason") != "" || !conversation.GetDateTime("frozen_at").IsZero() {
+		t.Fatalf("expected active conversation, got %v", conversation.PublicExport())
+	}
+	if channel, _ := f.chat.Channel(conversation.GetString("stream_channel_id")); channel.Frozen {
+		t.Fatal("expected the channel to be unfrozen")
+	}
+
+	// the archiving of the first closing no longer applies
+	if conversation := f.run(t, now.AddDate(0, 0, 7).Add(time.Minute)); conversation.GetString("state") != "active" {
+		t.Fatalf("expected conversation to stay active, got %q", conversation.GetString("state"))
+	}
+	if channel, _ := f.chat.Channel(conversation.GetString("stream_channel_id")); channel.Archived {
+		t.Fatal("expected the channel not to be archived")
+	}
+
+	// a conversation frozen for another reason stays frozen
+	proposal, err := f.app.Dao().FindRecordById("proposals", f.proposal.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proposal.Set("status", "rejected")
+	if err := f.app.Dao().SaveRecord(proposal); err != nil {
+		t.Fatal(err)
+	}
+	f.run(t, now.Add(2*time.Minute))
+	setStatus("closed")
+	setStatus("open")
+	conversation = f.run(t, now.Add(3*time.Minute))
+	if conversation.GetString("state") != "frozen" || conversation.GetString("state_reason") != "proposal_unaccepted" {
+		t.Fatalf("expected the unaccepted conversation to stay frozen, got %v", conversation.PublicExport())
+	}
+}
+
+func TestChatLifecycleProposalUnaccepted(t *testing.T) {
+	scenarios := []struct {
+		name          string
+		cfg           chatLifecycleConfig
+		expectedState string
+	}{
+		{"enabled", chatLifecycleConfig{FreezeOnProposalUnaccept: true}, "frozen"},
+		{"disabled", chatLifecycleConfig{}, "active"},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			f := newLifecycleFixture(t, s.cfg)
+
+			proposal, err := f.app.Dao().FindRecordById("proposals", f.proposal.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			proposal.Set("status", "rejected")
+			if err := f.app.Dao().SaveRecord(proposal); err != nil {
+				t.Fatal(err)
+			}
+
+			if state := f.run(t, time.Now()).GetString("state"); state != s.expectedState {
+				t.Fatalf("expected state %q, got %q", s.expectedState, state)
+			}
+		})
+	}
+}
+
+func TestChatLifecycleSoftDeletedMemberRemoved(t *testing.T) {
+	f := newLifecycleFixture(t, chatLifecycleConfig{RemoveDeletedMembers: true})
+
+	freelancer := mustFindUser(t, f.app, f.proposal.GetString("freelancer_id"))
+	freelancer.Set("is_deleted", true)
+	if err := f.app.Dao().SaveRecord(freelancer); err != nil {
+		t.Fatal(err)
+	}
+
+	conversation := f.run(t, time.Now())
+
+	removed := []string{}
+	if err := conversation.UnmarshalJSONField("removed_members", &removed); err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0] != freelancer.Id {
+		t.Fatalf("expected removed freelancer, got %v", removed)
+	}
+
+	channel, _ := f.chat.Channel(conversation.GetString("stream_channel_id"))
+	if !sameMembers(channel.Members, []string{f.proposal.GetString("client_id")}) {
+		t.Fatalf("expected only the client to remain, got %v", channel.Members)
+	}
+}
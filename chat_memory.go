@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var errChatChannelNotFound = errors.New("chat channel not found")
+
+// memoryChannel is a snapshot of a channel held by memoryChatProvider.
+type memoryChannel struct {
+	ID        string
+	CreatedBy string
+	Members   []string
+	Data      map[string]any
+	Frozen    bool
+	Archived  bool
+	Messages  []ChatMessage
+	Banned    []string
+}
+
+// memoryChatProvider keeps users and channels in process memory. It is
+// meant for offline development and tests; tokens it mints are not
+// accepted by Stream.
+type memoryChatProvider struct {
+	mu        sync.Mutex
+	users     map[string]ChatUser
+	channels  map[string]*memoryChannel
+	revoked   map[string]time.Time
+	bans      map[string]ChatBan
+	deleted   map[string]bool
+	deletedAt map[string]time.Time
+}
+
+func newMemoryChatProvider() *memoryChatProvider {
+	return &memoryChatProvider{
+		users:     map[string]ChatUser{},
+		channels:  map[string]*memoryChannel{},
+		revoked:   map[string]time.Time{},
+		bans:      map[string]ChatBan{},
+		deleted:   map[string]bool{},
+		deletedAt: map[string]time.Time{},
+	}
+}
+
+func (p *memoryChatProvider) UpsertUsers(ctx context.Context, users ...ChatUser) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, user := range users {
+		if user.ID == "" {
+			return errors.New("user ID is empty")
+		}
+		p.users[user.ID] = user
+	}
+
+	return nil
+}
+
+func (p *memoryChatProvider) CreateChannel(ctx context.Context, channelID string, createdBy string, memberIDs ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	channel, ok := p.channels[channelID]
+	if !ok {
+		channel = &memoryChannel{ID: channelID, CreatedBy: createdBy, Data: map[string]any{}}
+		p.channels[channelID] = channel
+	}
+	channel.Members = mergeMembers(channel.Members, memberIDs...)
+
+	return nil
+}
+
+func (p *memoryChatProvider) UpdateChannel(ctx context.Context, channelID string, data map[string]any) error {
+	return p.withChannel(channelID, func(channel *memoryChannel) {
+		for key, value := range data {
+			channel.Data[key] = value
+		}
+	})
+}
+
+func (p *memoryChatProvider) FreezeChannel(ctx context.Context, channelID string, frozen bool) error {
+	return p.withChannel(channelID, func(channel *memoryChannel) {
+		channel.Frozen = frozen
+	})
+}
+
+func (p *memoryChatProvider) ArchiveChannel(ctx context.Context, channelID string) error {
+	return p.withChannel(channelID, func(channel *memoryChannel) {
+		channel.Archived = true
+	})
+}
+
+func (p *memoryChatProvider) AddMembers(ctx context.Context, channelID string, userIDs ...string) error {
+	return p.withChannel(channelID, func(channel *memoryChannel) {
+		channel.Members = mergeMembers(channel.Members, userIDs...)
+	})
+}
+
+func (p *memoryChatProvider) RemoveMembers(ctx context.Context, channelID string, userIDs ...string) error {
+	return p.withChannel(channelID, func(channel *memoryChannel) {
+		remove := map[string]bool{}
+		for _, id := range userIDs {
+			remove[id] = true
+		}
+
+		members := channel.Members[:0]
+		for _, id := range channel.Members {
+			if !remove[id] {
+				members = append(members, id)
+			}
+		}
+		channel.Members = members
+	})
+}
+
+func (p *memoryChatProvider) SendSystemMessage(ctx context.Context, channelID string, message ChatMessage) error {
+	message.Type = "system"
+	return p.addMessage(channelID, message)
+}
+
+func (p *memoryChatProvider) SendMessage(ctx context.Context, channelID string, message ChatMessage) error {
+	message.Type = "regular"
+	return p.addMessage(channelID, message)
+}
+
+func (p *memoryChatProvider) addMessage(channelID string, message ChatMessage) error {
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = time.Now().UTC()
+	}
+
+	return p.withChannel(channelID, func(channel *memoryChannel) {
+		for _, existing := range channel.Messages {
+			if message.ID != "" && existing.ID == message.ID {
+				return
+			}
+		}
+		channel.Messages = append(channel.Messages, message)
+	})
+}
+
+// ListMessages returns the stored messages; deleted ones are marked but,
+// unlike on Stream, keep their text.
+func (p *memoryChatProvider) ListMessages(ctx context.Context, channelID string) ([]ChatMessage, error) {
+	channel, ok := p.Channel(channelID)
+	if !ok {
+		return nil, errChatChannelNotFound
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	messages := make([]ChatMessage, 0, len(channel.Messages))
+	for _, message := range channel.Messages {
+		hard, deleted := p.deleted[message.ID]
+		if deleted && hard {
+			continue
+		}
+		if deleted {
+			message.Type = "deleted"
+			message.DeletedAt = p.deletedAt[message.ID]
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+func (p *memoryChatProvider) GetMessage(ctx context.Context, messageID string) (ChatMessage, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, channel := range p.channels {
+		for _, message := range channel.Messages {
+			if message.ID != messageID {
+				continue
+			}
+			hard, deleted := p.deleted[message.ID]
+			if deleted && hard {
+				return ChatMessage{}, "", errChatMessageNotFound
+			}
+			if deleted {
+				message.Type = "deleted"
+				message.DeletedAt = p.deletedAt[message.ID]
+			}
+			return message, channel.ID, nil
+		}
+	}
+
+	return ChatMessage{}, "", errChatMessageNotFound
+}
+
+// DeleteMessage records the deletion; the memory provider only stores
+// messages sent by the backend, so any message id is accepted.
+func (p *memoryChatProvider) DeleteMessage(ctx context.Context, messageID string, hard bool) error {
+	if messageID == "" {
+		return errors.New("message ID is empty")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.deleted[messageID] = hard
+	p.deletedAt[messageID] = time.Now().UTC()
+
+	return nil
+}
+
+func (p *memoryChatProvider) BanUser(ctx context.Context, userID string, ban ChatBan) error {
+	if userID == "" {
+		return errors.New("user ID is empty")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.bans[userID] = ban
+
+	return nil
+}
+
+func (p *memoryChatProvider) UnbanUser(ctx context.Context, userID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.bans, userID)
+
+	return nil
+}
+
+// Ban returns the active ban of the user.
+func (p *memoryChatProvider) Ban(userID string) (ChatBan, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ban, ok := p.bans[userID]
+	return ban, ok
+}
+
+// MessageDeleted reports whether the message was deleted and how.
+func (p *memoryChatProvider) MessageDeleted(messageID string) (deleted bool, hard bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	hard, deleted = p.deleted[messageID]
+	return deleted, hard
+}
+
+func (p *memoryChatProvider) BanChannelMember(ctx context.Context, channelID string, userID string, ban ChatBan) error {
+	return p.withChannel(channelID, func(channel *memoryChannel) {
+		channel.Banned = mergeMembers(channel.Banned, userID)
+	})
+}
+
+func (p *memoryChatProvider) UnbanChannelMember(ctx context.Context, channelID string, userID string) error {
+	return p.withChannel(channelID, func(channel *memoryChannel) {
+		banned := channel.Banned[:0]
+		for _, id := range channel.Banned {
+			if id != userID {
+				banned = append(banned, id)
+			}
+		}
+		channel.Banned = banned
+	})
+}
+
+func (p *memoryChatProvider) CreateToken(userID string, expire time.Time, issuedAt time.Time) (string, error) {
+	if userID == "" {
+		return "", errors.New("user ID is empty")
+	}
+
+	return fmt.Sprintf("memory.%s.%d.%d", userID, issuedAt.Unix(), expire.Unix()), nil
+}
+
+func (p *memoryChatProvider) RevokeUserTokens(ctx context.Context, userID string, before time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.revoked[userID] = before
+
+	return nil
+}
+
+// RevokedBefore returns the time before which tokens of the user are revoked.
+func (p *memoryChatProvider) RevokedBefore(userID string) (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	before, ok := p.revoked[userID]
+	return before, ok
+}
+
+// Channel returns a copy of the stored channel.
+func (p *memoryChatProvider) Channel(channelID string) (memoryChannel, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	channel, ok := p.channels[channelID]
+	if !ok {
+		return memoryChannel{}, false
+	}
+
+	clone := *channel
+	clone.Members = append([]string(nil), channel.Members...)
+	clone.Messages = append([]ChatMessage(nil), channel.Messages...)
+	clone.Banned = append([]string(nil), channel.Banned...)
+	clone.Data = make(map[string]any, len(channel.Data))
+	for key, value := range channel.Data {
+		clone.Data[key] = value
+	}
+
+	return clone, true
+}
+
+// User returns the stored user profile.
+func (p *memoryChatProvider) User(userID string) (ChatUser, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	user, ok := p.users[userID]
+	return user, ok
+}
+
+func (p *memoryChatProvider) withChannel(channelID string, fn func(channel *memoryChannel)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	channel, ok := p.channels[channelID]
+	if !ok {
+		return fmt.Errorf("%w: %s", errChatChannelNotFound, channelID)
+	}
+
+	fn(channel)
+
+	return nil
+}
+
+func mergeMembers(members []string, add ...string) []string {
+	seen := map[string]bool{}
+	for _, id := range members {
+		seen[id] = true
+	}
+	for _, id := range add {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			members = append(members, id)
+		}
+	}
+	sort.Strings(members)
+
+	return members
+}
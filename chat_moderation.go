@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+var chatReportReasons = []string{"spam", "harassment", "fraud", "inappropriate", "other"}
+
+type chatReportRequest struct {
+	ChannelID      string `json:"channel_id"`
+	MessageID      string `json:"message_id"`
+	ReportedUserID string `json:"reported_user_id"`
+	Reason         string `json:"reason"`
+	Details        string `json:"details"`
+}
+
+type chatReportResolveRequest struct {
+	Action string `json:"action"`
+	Note   string `json:"note"`
+	// DurationHours limits a ban; 0 bans permanently.
+	DurationHours int `json:"duration_hours"`
+	// HardDelete removes the message instead of marking it deleted.
+	HardDelete bool `json:"hard_delete"`
+}
+
+// isChatBanned reports whether the user has an active (non shadow) ban.
+func isChatBanned(user *models.Record, now time.Time) bool {
+	if user.GetString("chat_ban") != "banned" {
+		return false
+	}
+
+	expiresAt := user.GetDateTime("chat_ban_expires_at")
+	return expiresAt.IsZero() || expiresAt.Time().After(now)
+}
+
+// chatReportHandler lets a conversation member report a message of the
+// other member. The message is looked up on the chat provider so a report
+// always names the real author of a message posted in that channel.
+func chatReportHandler(app core.App, chat ChatProvider) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+		if !ok || record == nil {
+			return apis.NewUnauthorizedError("unauthorized", nil)
+		}
+
+		var payload chatReportRequest
+		if err := c.Bind(&payload); err != nil {
+			return apis.NewBadRequestError("invalid request body", err)
+		}
+		payload.Details = strings.TrimSpace(payload.Details)
+		if payload.ChannelID == "" || payload.MessageID == "" {
+			return apis.NewBadRequestError("channel_id and message_id are required", nil)
+		}
+		if !isChatReportReason(payload.Reason) {
+			return apis.NewBadRequestError("reason must be one of "+strings.Join(chatReportReasons, ", "), nil)
+		}
+
+		conversation, err := app.Dao().FindFirstRecordByFilter(
+			"conversations",
+			"stream_channel_id = {:channel} && is_deleted = false",
+			dbx.Params{"channel": payload.ChannelID},
+		)
+		if err != nil {
+			return apis.NewNotFoundError("conversation not found", err)
+		}
+
+		members, err := conversationMemberIDs(app.Dao(), conversation)
+		if err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to load conversation members", err)
+		}
+		isMember := map[string]bool{}
+		for _, id := range members {
+			isMember[id] = true
+		}
+		if !isMember[record.Id] {
+			return apis.NewForbiddenError("not a member of this conversation", nil)
+		}
+
+		message, err := findChannelMessage(context.Background(), chat, payload.ChannelID, payload.MessageID)
+		if err != nil {
+			return err
+		}
+		if message.Type == "system" {
+			return apis.NewBadRequestError("system messages cannot be reported", nil)
+		}
+		if payload.ReportedUserID != "" && payload.ReportedUserID != message.UserID {
+			return apis.NewBadRequestError("reported_user_id is not the author of the message", nil)
+		}
+		if message.UserID == record.Id {
+			return apis.NewBadRequestError("cannot report your own message", nil)
+		}
+		if !isMember[message.UserID] {
+			return apis.NewBadRequestError("reported user is not a member of this conversation", nil)
+		}
+
+		reportsCol, err := app.Dao().FindCollectionByNameOrId("chat_reports")
+		if err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "chat_reports collection not found", err)
+		}
+
+		report := models.NewRecord(reportsCol)
+		report.Set("reporter_id", record.Id)
+		report.Set("reported_user_id", message.UserID)
+		report.Set("conversation_id", conversation.Id)
+		report.Set("stream_channel_id", payload.ChannelID)
+		report.Set("message_id", payload.MessageID)
+		report.Set("reason", payload.Reason)
+		report.Set("details", payload.Details)
+		report.Set("status", "open")
+		report.Set("is_deleted", false)
+
+		if err := app.Dao().SaveRecord(report); err != nil {
+			if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+				return apis.NewApiError(http.StatusConflict, "message already reported", nil)
+			}
+			return apis.NewApiError(http.StatusInternalServerError, "failed to save report", err)
+		}
+
+		return c.JSON(http.StatusCreated, map[string]any{
+			"report_id": report.Id,
+			"status":    report.GetString("status"),
+		})
+	}
+}
+
+// findChannelMessage loads a message from the chat provider and makes sure
+// it was posted in channelID.
+func findChannelMessage(ctx context.Context, chat ChatProvider, channelID string, messageID string) (ChatMessage, error) {
+	message, messageChannelID, err := chat.GetMessage(ctx, messageID)
+	if errors.Is(err, errChatMessageNotFound) || (err == nil && messageChannelID != channelID) {
+		return ChatMessage{}, apis.NewNotFoundError("message not found in this channel", nil)
+	}
+	if err != nil {
+		return ChatMessage{}, apis.NewApiError(http.StatusBadGateway, "failed to load message", err)
+	}
+
+	return message, nil
+}
+
+func isChatReportReason(reason string) bool {
+	for _, known := range chatReportReasons {
+		if known == reason {
+			return true
+		}
+	}
+	return false
+}
+
+func registerChatModerationRoutes(app core.App, router *echo.Echo, chat ChatProvider, systemUserID string) {
+	group := router.Group("/admin/chat", apis.RequireAdminAuth())
+
+	group.GET("/reports", chatReportListHandler(app))
+	group.POST("/reports/:id/resolve", chatReportResolveHandler(app, chat, systemUserID))
+	group.POST("/users/:userId/unban", chatUnbanHandler(app, chat))
+	group.POST("/conversations/:id/transcript", chatTranscriptStoreHandler(app, chat))
+}
+
+func chatReportListHandler(app core.App) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		status := c.QueryParam("status")
+		if status == "" {
+			status = "open"
+		}
+
+		reports, err := app.Dao().FindRecordsByFilter(
+			"chat_reports",
+			"status = {:status} && is_deleted = false",
+			"created",
+			500,
+			0,
+			dbx.Params{"status": status},
+		)
+		if err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to load chat reports", err)
+		}
+
+		if errs := app.Dao().ExpandRecords(reports, []string{"reporter_id", "reported_user_id"}, nil); len(errs) > 0 {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to load reported users", nil)
+		}
+
+		response := make([]map[string]any, 0, len(reports))
+		for _, report := range reports {
+			item := map[string]any{
+				"id":                report.Id,
+				"conversation_id":   report.GetString("conversation_id"),
+				"stream_channel_id": report.GetString("stream_channel_id"),
+				"message_id":        report.GetString("message_id"),
+				"reason":            report.GetString("reason"),
+				"details":           report.GetString("details"),
+				"status":            report.GetString("status"),
+				"action":            report.GetString("action"),
+				"resolution_note":   report.GetString("resolution_note"),
+				"created":           report.Created,
+			}
+			if reporter := report.ExpandedOne("reporter_id"); reporter != nil {
+				item["reporter"] = map[string]any{
+					"id":   reporter.Id,
+					"name": reporter.GetString("name"),
+				}
+			}
+			if reported := report.ExpandedOne("reported_user_id"); reported != nil {
+				item["reported_user"] = map[string]any{
+					"id":       reported.Id,
+					"name":     reported.GetString("name"),
+					"chat_ban": reported.GetString("chat_ban"),
+				}
+			}
+
+			response = append(response, item)
+		}
+
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
+// chatReportResolveHandler applies the chosen moderation action on the chat
+// provider first and then records it, so a failed provider call leaves the
+// report open for another attempt.
+func chatReportResolveHandler(app core.App, chat ChatProvider, systemUserID string) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		admin, _ := c.Get(apis.ContextAdminKey).(*models.Admin)
+		if admin == nil {
+			return apis.NewUnauthorizedError("unauthorized", nil)
+		}
+
+		var payload chatReportResolveRequest
+		if err := c.Bind(&payload); err != nil {
+			return apis.NewBadRequestError("invalid request body", err)
+		}
+		payload.Note = strings.TrimSpace(payload.Note)
+		if payload.DurationHours < 0 {
+			return apis.NewBadRequestError("duration_hours must not be negative", nil)
+		}
+
+		report, err := app.Dao().FindRecordById("chat_reports", c.PathParam("id"))
+		if err != nil || report.GetBool("is_deleted") {
+			return apis.NewNotFoundError("report not found", err)
+		}
+		if report.GetString("status") != "open" {
+			return apis.NewApiError(http.StatusConflict, "report is already resolved", nil)
+		}
+
+		var banned *models.Record
+		ctx := context.Background()
+		now := time.Now()
+
+		// acting on the message or its author needs the report to still
+		// match what the chat provider has
+		if payload.Action == "delete_message" || payload.Action == "ban" || payload.Action == "shadow_ban" {
+			message, err := findChannelMessage(ctx, chat, report.GetString("stream_channel_id"), report.GetString("message_id"))
+			if err != nil {
+				return err
+			}
+			if message.UserID != report.GetString("reported_user_id") {
+				return apis.NewApiError(http.StatusConflict, "reported user is not the author of the message", nil)
+			}
+		}
+
+		switch payload.Action {
+		case "dismiss":
+		case "delete_message":
+			if err := chat.DeleteMessage(ctx, report.GetString("message_id"), payload.HardDelete); err != nil {
+				return apis.NewApiError(http.StatusBadGateway, "failed to delete message", err)
+			}
+		case "ban", "shadow_ban":
+			banned, err = app.Dao().FindRecordById("users", report.GetString("reported_user_id"))
+			if err != nil {
+				return apis.NewNotFoundError("reported user not found", err)
+			}
+
+			reason := payload.Note
+			if reason == "" {
+				reason = report.GetString("reason")
+			}
+			ban := ChatBan{
+				BannedBy: systemUserID,
+				Reason:   reason,
+				Duration: time.Duration(payload.DurationHours) * time.Hour,
+				Shadow:   payload.Action == "shadow_ban",
+			}
+
+			if err := chat.UpsertUsers(ctx, ChatUser{ID: systemUserID, Name: "Marketplace"}); err != nil {
+				return apis.NewApiError(http.StatusBadGateway, "failed to prepare moderator user", err)
+			}
+			if err := chat.BanUser(ctx, banned.Id, ban); err != nil {
+				return apis.NewApiError(http.StatusBadGateway, "failed to ban user", err)
+			}
+
+			banned.Set("chat_ban", "banned")
+			if ban.Shadow {
+				banned.Set("chat_ban", "shadow_banned")
+			}
+			banned.Set("chat_ban_reason", reason)
+			if ban.Duration > 0 {
+				banned.Set("chat_ban_expires_at", now.Add(ban.Duration))
+			} else {
+				banned.Set("chat_ban_expires_at", "")
+			}
+		default:
+			return apis.NewBadRequestError("action must be one of dismiss, delete_message, ban, shadow_ban", nil)
+		}
+
+		report.Set("status", "actioned")
+		if payload.Action == "dismiss" {
+			report.Set("status", "dismissed")
+		}
+		report.Set("action", payload.Action)
+		report.Set("admin_id", admin.Id)
+		report.Set("resolution_note", payload.Note)
+		report.Set("resolved_at", now)
+
+		err = app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+			if banned != nil {
+				if err := txDao.SaveRecord(banned); err != nil {
+					return err
+				}
+				// shadow banned users must not notice anything
+				if payload.Action == "ban" {
+					if err := enqueueUserChatTokenRevocation(txDao, banned.Id); err != nil {
+						return err
+					}
+				}
+			}
+			return txDao.SaveRecord(report)
+		})
+		if err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to save report", err)
+		}
+
+		return c.JSON(http.StatusOK, map[string]any{
+			"report_id": report.Id,
+			"status":    report.GetString("status"),
+			"action":    report.GetString("action"),
+		})
+	}
+}
+
+func chatUnbanHandler(app core.App, chat ChatProvider) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		user, err := app.Dao().FindRecordById("users", c.PathParam("userId"))
+		if err != nil {
+			return apis.NewNotFoundError("user not found", err)
+		}
+		if user.GetString("chat_ban") == "" {
+			return apis.NewApiError(http.StatusConflict, "user is not banned", nil)
+		}
+
+		if err := chat.UnbanUser(context.Background(), user.Id); err != nil {
+			return apis.NewApiError(http.StatusBadGateway, "failed to unban user", err)
+		}
+
+		user.Set("chat_ban", "")
+		user.Set("chat_ban_reason", "")
+		user.Set("chat_ban_expires_at", "")
+
+		if err := app.Dao().SaveRecord(user); err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to save user", err)
+		}
+
+		return c.JSON(http.StatusOK, map[string]any{
+			"user_id":  user.Id,
+			"chat_ban": "",
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+func reportBody(t *testing.T, fields map[string]any) []byte {
+	t.Helper()
+
+	body, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return body
+}
+
+// newReportedChannel mirrors the conversation channel on the memory
+// provider with a message of each member.
+func newReportedChannel(t *testing.T, proposal *models.Record, conversation *models.Record) *memoryChatProvider {
+	t.Helper()
+
+	ctx := context.Background()
+	chat := newMemoryChatProvider()
+	channelID := conversation.GetString("stream_channel_id")
+	clientID := proposal.GetString("client_id")
+	freelancerID := proposal.GetString("freelancer_id")
+
+	if err := chat.CreateChannel(ctx, channelID, clientID, clientID, freelancerID); err != nil {
+		t.Fatal(err)
+	}
+	if err := chat.SendMessage(ctx, channelID, ChatMessage{ID: "msg_1", UserID: freelancerID, Text: "Pay me outside the platform"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := chat.SendMessage(ctx, channelID, ChatMessage{ID: "msg_client", UserID: clientID, Text: "No thanks"}); err != nil {
+		t.Fatal(err)
+	}
+
+	return chat
+}
+
+func TestChatReportHandler(t *testing.T) {
+	app := newTestApp(t)
+	proposal, conversation := createTestConversation(t, app)
+	chat := newReportedChannel(t, proposal, conversation)
+	handler := chatReportHandler(app, chat)
+
+	client := mustFindUser(t, app, proposal.GetString("client_id"))
+	freelancerID := proposal.GetString("freelancer_id")
+	outsider := createTestUser(t, app, "client", nil)
+
+	ctx := context.Background()
+	channelID := conversation.GetString("stream_channel_id")
+	if err := chat.SendMessage(ctx, channelID, ChatMessage{ID: "msg_outsider", UserID: outsider.Id, Text: "Hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := chat.SendSystemMessage(ctx, channelID, ChatMessage{ID: "msg_system", UserID: defaultChatSystemUserID, Text: "Proposal accepted"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := chat.CreateChannel(ctx, "proposal_other", freelancerID, freelancerID); err != nil {
+		t.Fatal(err)
+	}
+	if err := chat.SendMessage(ctx, "proposal_other", ChatMessage{ID: "msg_other", UserID: freelancerID, Text: "Elsewhere"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// reported_user_id is optional: the author comes from the message
+	valid := map[string]any{
+		"channel_id": channelID,
+		"message_id": "msg_1",
+		"reason":     "harassment",
+	}
+
+	scenarios := []struct {
+		name         string
+		auth         *models.Record
+		override     map[string]any
+		expectedCode int
+	}{
+		{"invalid reason", client, map[string]any{"reason": "boring"}, http.StatusBadRequest},
+		{"own message", client, map[string]any{"message_id": "msg_client"}, http.StatusBadRequest},
+		{"unknown channel", client, map[string]any{"channel_id": "proposal_missing"}, http.StatusNotFound},
+		{"not a member", outsider, nil, http.StatusForbidden},
+		{"unknown message", client, map[string]any{"message_id": "msg_missing"}, http.StatusNotFound},
+		{"message of another channel", client, map[string]any{"message_id": "msg_other"}, http.StatusNotFound},
+		{"system message", client, map[string]any{"message_id": "msg_system"}, http.StatusBadRequest},
+		{"reported user is not the author", client, map[string]any{"reported_user_id": client.Id}, http.StatusBadRequest},
+		{"author outside the conversation", client, map[string]any{"message_id": "msg_outsider"}, http.StatusBadRequest},
+		{"valid", client, nil, http.StatusCreated},
+		{"duplicate", client, nil, http.StatusConflict},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			fields := map[string]any{}
+			for key, value := range valid {
+				fields[key] = value
+			}
+			for key, value := range s.override {
+				fields[key] = value
+			}
+
+			code, rec := callHandler(t, handler, http.MethodPost, "/chat/report", reportBody(t, fields), nil, s.auth)
+			if code != s.expectedCode {
+				t.Fatalf("expected %d, got %d: %s", s.expectedCode, code, rec.Body.String())
+			}
+		})
+	}
+
+	report, err := app.Dao().FindFirstRecordByData("chat_reports", "message_id", "msg_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.GetString("status") != "open" || report.GetString("reported_user_id") != freelancerID {
+		t.Fatalf("unexpected report %v", report.PublicExport())
+	}
+}
+
+func TestChatReportResolveBan(t *testing.T) {
+	app := newTestApp(t)
+	proposal, conversation := createTestConversation(t, app)
+	chat := newReportedChannel(t, proposal, conversation)
+	freelancerID := proposal.GetString("freelancer_id")
+
+	report := createTestRecord(t, app, "chat_reports", map[string]any{
+		"reporter_id":       proposal.GetString("client_id"),
+		"reported_user_id":  freelancerID,
+		"conversation_id":   conversation.Id,
+		"stream_channel_id": conversation.GetString("stream_channel_id"),
+		"message_id":        "msg_1",
+		"reason":            "fraud",
+		"status":            "open",
+		"is_deleted":        false,
+	})
+
+	handler := asAdmin(chatReportResolveHandler(app, chat, defaultChatSystemUserID))
+	resolve := func(action map[string]any) int {
+		t.Helper()
+		code, _ := callHandler(t, handler, http.MethodPost, "/admin/chat/reports/"+report.Id+"/resolve", reportBody(t, action), nil, nil,
+			echo.PathParam{Name: "id", Value: report.Id})
+		return code
+	}
+
+	if code := resolve(map[string]any{"action": "mute"}); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown action, got %d", code)
+	}
+	if code := resolve(map[string]any{"action": "ban", "note": "Asked for off-platform payment", "duration_hours": 48}); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if code := resolve(map[string]any{"action": "dismiss"}); code != http.StatusConflict {
+		t.Fatalf("expected 409 for a resolved report, got %d", code)
+	}
+
+	ban, ok := chat.Ban(freelancerID)
+	if !ok || ban.Shadow || ban.Duration != 48*time.Hour || ban.BannedBy != defaultChatSystemUserID {
+		t.Fatalf("unexpected provider ban %+v", ban)
+	}
+
+	freelancer := mustFindUser(t, app, freelancerID)
+	if freelancer.GetString("chat_ban") != "banned" || freelancer.GetDateTime("chat_ban_expires_at").IsZero() {
+		t.Fatalf("expected banned user, got %v", freelancer.PublicExport())
+	}
+
+	jobs := findOutboxJobsByType(t, app, outboxJobRevokeChatTokens)
+	if len(jobs) != 1 || jobs[0].GetString("reference_id") != freelancerID {
+		t.Fatalf("expected a token revocation job, got %d jobs", len(jobs))
+	}
+
+	tokenHandler := chatTokenHandler(app, chat, time.Hour)
+	if code, _ := callHandler(t, tokenHandler, http.MethodPost, "/chat/token", nil, nil, freelancer); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a banned user, got %d", code)
+	}
+
+	unban := asAdmin(chatUnbanHandler(app, chat))
+	if code, _ := callHandler(t, unban, http.MethodPost, "/admin/chat/users/"+freelancerID+"/unban", nil, nil, nil,
+		echo.PathParam{Name: "userId", Value: freelancerID}); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if _, ok := chat.Ban(freelancerID); ok {
+		t.Fatal("expected provider ban to be lifted")
+	}
+	if code, _ := callHandler(t, tokenHandler, http.MethodPost, "/chat/token", nil, nil, mustFindUser(t, app, freelancerID)); code != http.StatusOK {
+		t.Fatalf("expected 200 after unban, got %d", code)
+	}
+}
+
+func TestChatReportResolveChecksMessage(t *testing.T) {
+	app := newTestApp(t)
+	proposal, conversation := createTestConversation(t, app)
+	chat := newReportedChannel(t, proposal, conversation)
+	clientID := proposal.GetString("client_id")
+	freelancerID := proposal.GetString("freelancer_id")
+
+	err := chat.SendMessage(context.Background(), conversation.GetString("stream_channel_id"),
+		ChatMessage{ID: "msg_2", UserID: freelancerID, Text: "Last chance"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []struct {
+		name         string
+		channelID    string
+		messageID    string
+		reportedID   string
+		action       string
+		expectedCode int
+	}{
+		{"message of another channel", "proposal_other", "msg_1", freelancerID, "delete_message", http.StatusNotFound},
+		{"missing message", conversation.GetString("stream_channel_id"), "msg_missing", freelancerID, "ban", http.StatusNotFound},
+		{"reported user is not the author", conversation.GetString("stream_channel_id"), "msg_client", freelancerID, "ban", http.StatusConflict},
+		{"dismiss skips the check", "proposal_other", "msg_gone", freelancerID, "dismiss", http.StatusOK},
+		{"matching report", conversation.GetString("stream_channel_id"), "msg_2", freelancerID, "delete_message", http.StatusOK},
+	}
+
+	handler := asAdmin(chatReportResolveHandler(app, chat, defaultChatSystemUserID))
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			report := createTestRecord(t, app, "chat_reports", map[string]any{
+				"reporter_id":       clientID,
+				"reported_user_id":  s.reportedID,
+				"conversation_id":   conversation.Id,
+				"stream_channel_id": s.channelID,
+				"message_id":        s.messageID,
+				"reason":            "spam",
+				"status":            "open",
+				"is_deleted":        false,
+			})
+
+			code, rec := callHandler(t, handler, http.MethodPost, "/admin/chat/reports/"+report.Id+"/resolve",
+				reportBody(t, map[string]any{"action": s.action}), nil, nil, echo.PathParam{Name: "id", Value: report.Id})
+			if code != s.expectedCode {
+				t.Fatalf("expected %d, got %d: %s", s.expectedCode, code, rec.Body.String())
+			}
+		})
+	}
+
+	if _, ok := chat.Ban(clientID); ok {
+		t.Fatal("expected no ban for a mismatched report")
+	}
+	if deleted, _ := chat.MessageDeleted("msg_2"); !deleted {
+		t.Fatal("expected the reported message to be deleted")
+	}
+}
+
+func TestIsChatBanned(t *testing.T) {
+	app := newTestApp(t)
+	now := time.Now()
+
+	scenarios := []struct {
+		name     string
+		fields   map[string]any
+		expected bool
+	}{
+		{"not banned", nil, false},
+		{"permanent ban", map[string]any{"chat_ban": "banned"}, true},
+		{"active ban", map[string]any{"chat_ban": "banned", "chat_ban_expires_at": now.Add(time.Hour)}, true},
+		{"expired ban", map[string]any{"chat_ban": "banned", "chat_ban_expires_at": now.Add(-time.Hour)}, false},
+		// shadow banned users keep their access so they do not notice
+		{"shadow ban", map[string]any{"chat_ban": "shadow_banned"}, false},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			user := createTestUser(t, app, "freelancer", s.fields)
+			if banned := isChatBanned(user, now); banned != s.expected {
+				t.Fatalf("expected %v, got %v", s.expected, banned)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/spf13/cobra"
+)
+
+const (
+	outboxJobSyncChatUser = "sync_chat_user"
+
+	// Stream accepts at most 100 users per upsert request.
+	chatSyncBatchSize = 100
+)
+
+// chatProfileFields are the users fields mirrored to the chat provider.
+var chatProfileFields = []string{"name", "avatar", "role", "verification_status", "is_deleted"}
+
+// chatUserFromRecord maps a users record to the profile shown in chat.
+// The marketplace role is stored as "marketplace_role" because "role"
+// is reserved by Stream for its own permission roles.
+func chatUserFromRecord(app core.App, user *models.Record) ChatUser {
+	return ChatUser{
+		ID:    user.Id,
+		Name:  user.GetString("name"),
+		Image: userAvatarURL(app, user),
+		Extra: map[string]any{
+			"marketplace_role": user.GetString("role"),
+			"verified":         user.GetString("verification_status") == "approved",
+		},
+	}
+}
+
+func userAvatarURL(app core.App, user *models.Record) string {
+	avatar := user.GetString("avatar")
+	if avatar == "" {
+		return ""
+	}
+
+	baseURL := strings.TrimRight(app.Settings().Meta.AppUrl, "/")
+
+	return fmt.Sprintf("%s/api/files/%s/%s/%s",
+		baseURL,
+		url.PathEscape(user.Collection().Id),
+		url.PathEscape(user.Id),
+		url.PathEscape(avatar),
+	)
+}
+
+// enqueueChatUserSync schedules a profile sync when a user is created or
+// when one of the mirrored fields changed.
+func enqueueChatUserSync(dao *daos.Dao, user *models.Record) error {
+	if !user.IsNew() {
+		original := user.OriginalCopy()
+
+		changed := false
+		for _, field := range chatProfileFields {
+			if original.GetString(field) != user.GetString(field) {
+				changed = true
+				break
+			}
+		}
+		if !changed {
+			return nil
+		}
+	}
+
+	return enqueueOutboxJob(dao, outboxJobSyncChatUser, user.Id, map[string]any{
+		"user_id": user.Id,
+	})
+}
+
+// handleChatUserSync is the outbox handler that pushes a single profile.
+func handleChatUserSync(app core.App, chat ChatProvider, job *models.Record) error {
+	user, err := app.Dao().FindRecordById("users", job.GetString("reference_id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errOutboxJobCancelled
+		}
+		return err
+	}
+
+	return chat.UpsertUsers(context.Background(), chatUserFromRecord(app, user))
+}
+
+// syncAllChatUsers pushes every user profile to the chat provider in batches
+// and returns the number of synced users.
+func syncAllChatUsers(app core.App, chat ChatProvider) (int, error) {
+	collection, err := app.Dao().FindCollectionByNameOrId("users")
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for offset := 0; ; offset += chatSyncBatchSize {
+		users := []*models.Record{}
+		err := app.Dao().RecordQuery(collection).
+			OrderBy("created ASC", "id ASC").
+			Limit(chatSyncBatchSize).
+			Offset(int64(offset)).
+			All(&users)
+		if err != nil {
+			return total, err
+		}
+		if len(users) == 0 {
+			return total, nil
+		}
+
+		batch := make([]ChatUser, 0, len(users))
+		for _, user := range users {
+			batch = append(batch, chatUserFromRecord(app, user))
+		}
+
+		if err := chat.UpsertUsers(context.Background(), batch...); err != nil {
+			return total, err
+		}
+		total += len(batch)
+	}
+}
+
+func newChatCommand(app core.App, chat ChatProvider) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "chat",
+		Short: "Chat provider maintenance commands",
+	}
+
+	command.AddCommand(&cobra.Command{
+		Use:   "sync-users",
+		Short: "Push name, avatar, role and verification badge of all users to the chat provider",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			total, err := syncAllChatUsers(app, chat)
+			if err != nil {
+				return fmt.Errorf("synced %d users before failing: %w", total, err)
+			}
+
+			log.Printf("synced %d users to chat", total)
+
+			return nil
+		},
+	})
+
+	return command
+}
+
+// findChatUsers loads the chat profiles for the given user ids.
+func findChatUsers(app core.App, ids ...string) ([]ChatUser, error) {
+	records, err := app.Dao().FindRecordsByIds("users", ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) != len(ids) {
+		return nil, fmt.Errorf("expected %d users, found %d", len(ids), len(records))
+	}
+
+	users := make([]ChatUser, 0, len(records))
+	for _, record := range records {
+		users = append(users, chatUserFromRecord(app, record))
+	}
+
+	return users, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import "testing"
+
+func TestChatUserFromRecord(t *testing.T) {
+	app := newTestApp(t)
+	app.Settings().Meta.AppUrl = "https://api.example.com/"
+
+	user := createTestUser(t, app, "freelancer", map[string]any{
+		"name":                "Jane",
+		"avatar":              "jane_abc.png",
+		"verification_status": "approved",
+	})
+
+	chatUser := chatUserFromRecord(app, user)
+
+	expectedImage := "https://api.example.com/api/files/" + user.Collection().Id + "/" + user.Id + "/jane_abc.png"
+	if chatUser.ID != user.Id || chatUser.Name != "Jane" || chatUser.Image != expectedImage {
+		t.Fatalf("unexpected chat user %+v", chatUser)
+	}
+	if chatUser.Extra["marketplace_role"] != "freelancer" || chatUser.Extra["verified"] != true {
+		t.Fatalf("unexpected extra data %v", chatUser.Extra)
+	}
+}
+
+func TestEnqueueChatUserSyncOnProfileChanges(t *testing.T) {
+	app := newTestApp(t)
+
+	user := createTestUser(t, app, "client", nil)
+	if jobs := findOutboxJobs(t, app); len(jobs) != 1 || jobs[0].GetString("type") != outboxJobSyncChatUser {
+		t.Fatalf("expected a sync job on create, got %d jobs", len(jobs))
+	}
+
+	// mark the first job as done so new changes create a new one
+	job := findOutboxJobs(t, app)[0]
+	job.Set("status", "done")
+	if err := app.Dao().SaveRecord(job); err != nil {
+		t.Fatal(err)
+	}
+
+	user = mustFindUser(t, app, user.Id)
+	user.Set("emailVisibility", true)
+	if err := app.Dao().SaveRecord(user); err != nil {
+		t.Fatal(err)
+	}
+	if jobs := findOutboxJobs(t, app); len(jobs) != 1 {
+		t.Fatalf("expected no sync job for unrelated fields, got %d jobs", len(jobs))
+	}
+
+	user.Set("name", "Renamed")
+	if err := app.Dao().SaveRecord(user); err != nil {
+		t.Fatal(err)
+	}
+	if jobs := findOutboxJobs(t, app); len(jobs) != 2 {
+		t.Fatalf("expected a sync job after rename, got %d jobs", len(jobs))
+	}
+}
+
+func TestSyncAllChatUsers(t *testing.T) {
+	app := newTestApp(t)
+	chat := newMemoryChatProvider()
+
+	ids := []string{}
+	for i := 0; i < chatSyncBatchSize+5; i++ {
+		ids = append(ids, createTestUser(t, app, "client", nil).Id)
+	}
+
+	total, err := syncAllChatUsers(app, chat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != len(ids) {
+		t.Fatalf("expected %d synced users, got %d", len(ids), total)
+	}
+
+	for _, id := range ids {
+		user, ok := chat.User(id)
+		if !ok || user.Name == "" {
+			t.Fatalf("expected user %s to be synced, got %+v", id, user)
+		}
+	}
+}
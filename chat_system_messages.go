@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+const (
+	outboxJobSendSystemMessage = "send_system_message"
+
+	chatEventPaymentPaid     = "payment.paid"
+	chatEventPaymentFailed   = "payment.failed"
+	chatEventPaymentRefunded = "payment.refunded"
+	chatEventProjectClosed   = "project.closed"
+
+	defaultChatSystemUserID = "marketplace"
+	defaultChatLocale       = "en"
+)
+
+var chatSystemEvents = []string{
+	chatEventPaymentPaid,
+	chatEventPaymentFailed,
+	chatEventPaymentRefunded,
+	chatEventProjectClosed,
+}
+
+// defaultChatSystemTemplates are text/template strings keyed by locale and
+// event. CHAT_SYSTEM_MESSAGE_TEMPLATES can point to a JSON file of the same
+// shape to override them or add locales.
+var defaultChatSystemTemplates = map[string]map[string]string{
+	"en": {
+		chatEventPaymentPaid:     "Payment of {{.amount}} {{.currency}} was received.",
+		chatEventPaymentFailed:   "Payment of {{.amount}} {{.currency}} failed.",
+		chatEventPaymentRefunded: "Payment of {{.amount}} {{.currency}} was refunded.",
+		chatEventProjectClosed:   "Project \"{{.project_title}}\" was closed.",
+	},
+	"es": {
+		chatEventPaymentPaid:     "Se recibió el pago de {{.amount}} {{.currency}}.",
+		chatEventPaymentFailed:   "El pago de {{.amount}} {{.currency}} falló.",
+		chatEventPaymentRefunded: "Se reembolsó el pago de {{.amount}} {{.currency}}.",
+		chatEventProjectClosed:   "El proyecto \"{{.project_title}}\" se cerró.",
+	},
+}
+
+// zeroDecimalCurrencies are the Stripe currencies whose amounts are not
+// expressed in cents.
+var zeroDecimalCurrencies = map[string]bool{
+	"bif": true, "clp": true, "djf": true, "gnf": true, "jpy": true, "kmf": true,
+	"krw": true, "mga": true, "pyg": true, "rwf": true, "ugx": true, "vnd": true,
+	"vuv": true, "xaf": true, "xof": true, "xpf": true,
+}
+
+type chatSystemMessageConfig struct {
+	Events    map[string]bool
+	Locale    string
+	UserID    string
+	Templates map[string]map[string]*template.Template
+}
+
+func loadChatSystemMessageConfig() (chatSystemMessageConfig, error) {
+	cfg := chatSystemMessageConfig{
+		Events:    map[string]bool{},
+		Locale:    defaultChatLocale,
+		UserID:    defaultChatSystemUserID,
+		Templates: map[string]map[string]*template.Template{},
+	}
+
+	// CHAT_SYSTEM_MESSAGE_EVENTS is a comma separated list of events; empty
+	// enables all of them and "none" disables system messages.
+	events := strings.TrimSpace(os.Getenv("CHAT_SYSTEM_MESSAGE_EVENTS"))
+	switch events {
+	case "":
+		for _, event := range chatSystemEvents {
+			cfg.Events[event] = true
+		}
+	case "none":
+	default:
+		for _, event := range strings.Split(events, ",") {
+			event = strings.TrimSpace(event)
+			if !isChatSystemEvent(event) {
+				return chatSystemMessageConfig{}, fmt.Errorf("CHAT_SYSTEM_MESSAGE_EVENTS: unknown event %q", event)
+			}
+			cfg.Events[event] = true
+		}
+	}
+
+	if value := strings.TrimSpace(os.Getenv("CHAT_SYSTEM_MESSAGE_LOCALE")); value != "" {
+		cfg.Locale = value
+	}
+	if value := strings.TrimSpace(os.Getenv("CHAT_SYSTEM_USER_ID")); value != "" {
+		cfg.UserID = value
+	}
+
+	sources := map[string]map[string]string{}
+	for locale, templates := range defaultChatSystemTemplates {
+		sources[locale] = map[string]string{}
+		for event, text := range templates {
+			sources[locale][event] = text
+		}
+	}
+
+	if path := strings.TrimSpace(os.Getenv("CHAT_SYSTEM_MESSAGE_TEMPLATES")); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return chatSystemMessageConfig{}, fmt.Errorf("CHAT_SYSTEM_MESSAGE_TEMPLATES: %w", err)
+		}
+
+		overrides := map[string]map[string]string{}
+		if err := json.Unmarshal(raw, &overrides); err != nil {
+			return chatSystemMessageConfig{}, fmt.Errorf("CHAT_SYSTEM_MESSAGE_TEMPLATES: %w", err)
+		}
+		for locale, templates := range overrides {
+			if sources[locale] == nil {
+				sources[locale] = map[string]string{}
+			}
+			for event, text := range templates {
+				sources[locale][event] = text
+			}
+		}
+	}
+
+	for locale, templates := range sources {
+		cfg.Templates[locale] = map[string]*template.Template{}
+		for event, text := range templates {
+			parsed, err := template.New(locale + "/" + event).Option("missingkey=zero").Parse(text)
+			if err != nil {
+				return chatSystemMessageConfig{}, fmt.Errorf("invalid %s template for %s: %w", locale, event, err)
+			}
+			cfg.Templates[locale][event] = parsed
+		}
+	}
+
+	if cfg.Templates[cfg.Locale] == nil {
+		return chatSystemMessageConfig{}, fmt.Errorf("CHAT_SYSTEM_MESSAGE_LOCALE: no templates for %q", cfg.Locale)
+	}
+
+	return cfg, nil
+}
+
+func isChatSystemEvent(event string) bool {
+	for _, known := range chatSystemEvents {
+		if known == event {
+			return true
+		}
+	}
+	return false
+}
+
+// render returns the message text for the configured locale, falling back
+// to English for events the locale does not translate.
+func (cfg chatSystemMessageConfig) render(event string, params map[string]any) (string, error) {
+	tmpl := cfg.Templates[cfg.Locale][event]
+	if tmpl == nil {
+		tmpl = cfg.Templates[defaultChatLocale][event]
+	}
+	if tmpl == nil {
+		return "", fmt.Errorf("no template for event %q", event)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, params); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// enqueuePaymentSystemMessage announces a payment status change in the
+// conversation between the payment's client and freelancer.
+func enqueuePaymentSystemMessage(dao *daos.Dao, cfg chatSystemMessageConfig, payment *models.Record) error {
+	if payment.IsNew() {
+		return nil
+	}
+
+	status := payment.GetString("status")
+	if status == payment.OriginalCopy().GetString("status") {
+		return nil
+	}
+
+	event := "payment." + status
+	if !cfg.Events[event] {
+		return nil
+	}
+
+	conversation, err := findPaymentConversation(dao, payment)
+	if err != nil || conversation == nil {
+		return err
+	}
+
+	currency := payment.GetString("currency")
+
+	return enqueueOutboxJob(dao, outboxJobSendSystemMessage, payment.Id+":"+status, map[string]any{
+		"conversation_id": conversation.Id,
+		"event":           event,
+		"params": map[string]any{
+			"payment_id": payment.Id,
+			"amount":     formatMinorUnits(int64(payment.GetInt("amount")), currency),
+			"currency":   strings.ToUpper(currency),
+		},
+	})
+}
+
+// enqueueProjectSystemMessages announces that a project was closed in each of
+// its conversations.
+func enqueueProjectSystemMessages(dao *daos.Dao, cfg chatSystemMessageConfig, project *models.Record) error {
+	if !cfg.Events[chatEventProjectClosed] || project.IsNew() {
+		return nil
+	}
+	if project.GetString("status") != "closed" || project.OriginalCopy().GetString("status") == "closed" {
+		return nil
+	}
+
+	conversations, err := dao.FindRecordsByFilter(
+		"conversations",
+		"project_id = {:pid} && is_deleted = false && state != 'archived'",
+		"",
+		0,
+		0,
+		dbx.Params{"pid": project.Id},
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, conversation := range conversations {
+		if err := enqueueOutboxJob(dao, outboxJobSendSystemMessage, conversation.Id+":"+chatEventProjectClosed, map[string]any{
+			"conversation_id": conversation.Id,
+			"event":           chatEventProjectClosed,
+			"params": map[string]any{
+				"project_id":    project.Id,
+				"project_title": project.GetString("title"),
+			},
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// findPaymentConversation returns the latest conversation between the
+// payment's client and freelancer, limited to the payment's project when it
+// is known. It returns nil when there is none.
+func findPaymentConversation(dao *daos.Dao, payment *models.Record) (*models.Record, error) {
+	filter := "proposal_id.client_id = {:cid} && proposal_id.freelancer_id = {:fid} && is_deleted = false"
+	params := dbx.Params{
+		"cid": payment.GetString("client_id"),
+		"fid": payment.GetString("freelancer_id"),
+	}
+	if projectID := payment.GetString("project_id"); projectID != "" {
+		filter += " && project_id = {:pid}"
+		params["pid"] = projectID
+	}
+
+	conversations, err := dao.FindRecordsByFilter("conversations", filter, "-created", 1, 0, params)
+	if err != nil || len(conversations) == 0 {
+		return nil, err
+	}
+
+	return conversations[0], nil
+}
+
+// handleSendSystemMessage is the outbox handler that posts a system message.
+// The event and its params are sent along so clients can render the message
+// in the reader's own language.
+func handleSendSystemMessage(app core.App, chat ChatProvider, cfg chatSystemMessageConfig, job *models.Record) error {
+	payload := outboxJobPayload(job)
+	event, _ := payload["event"].(string)
+	params, _ := payload["params"].(map[string]any)
+	conversationID, _ := payload["conversation_id"].(string)
+
+	conversation, err := app.Dao().FindRecordById("conversations", conversationID)
+	if err != nil {
+		return err
+	}
+	if conversation.GetBool("is_deleted") || conversation.GetString("state") == "archived" {
+		return errOutboxJobCancelled
+	}
+
+	text, err := cfg.render(event, params)
+	if err != nil {
+		return err
+	}
+
+	if conversation.GetString("stream_channel_id") == "" {
+		return errors.New("conversation has no chat channel")
+	}
+
+	ctx := context.Background()
+	if err := chat.UpsertUsers(ctx, ChatUser{ID: cfg.UserID, Name: "Marketplace"}); err != nil {
+		return err
+	}
+
+	return chat.SendSystemMessage(ctx, conversation.GetString("stream_channel_id"), ChatMessage{
+		// a stable id lets the provider drop duplicates when a job is retried
+		ID:     "system_" + job.Id,
+		UserID: cfg.UserID,
+		Text:   text,
+		Extra: map[string]any{
+			"system_event":  event,
+			"system_params": params,
+		},
+	})
+}
+
+// formatMinorUnits formats a Stripe amount (in the currency's smallest unit).
+func formatMinorUnits(amount int64, currency string) string {
+	if zeroDecimalCurrencies[strings.ToLower(currency)] {
+		return fmt.Sprintf("%d", amount)
+	}
+
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100)
+}
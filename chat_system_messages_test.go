@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+func TestLoadChatSystemMessageConfig(t *testing.T) {
+	overrides := filepath.Join(t.TempDir(), "templates.json")
+	if err := os.WriteFile(overrides, []byte(`{"fr": {"payment.paid": "Paiement de {{.amount}} {{.currency}} reçu."}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("CHAT_SYSTEM_MESSAGE_EVENTS", "payment.paid, project.closed")
+	t.Setenv("CHAT_SYSTEM_MESSAGE_LOCALE", "fr")
+	t.Setenv("CHAT_SYSTEM_MESSAGE_TEMPLATES", overrides)
+
+	cfg, err := loadChatSystemMessageConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Events[chatEventPaymentPaid] || !cfg.Events[chatEventProjectClosed] || cfg.Events[chatEventPaymentFailed] {
+		t.Fatalf("unexpected events %v", cfg.Events)
+	}
+
+	params := map[string]any{"amount": "12.50", "currency": "EUR", "project_title": "Logo"}
+	scenarios := []struct {
+		event    string
+		expected string
+	}{
+		{chatEventPaymentPaid, "Paiement de 12.50 EUR reçu."},
+		// events without a French template fall back to English
+		{chatEventProjectClosed, `Project "Logo" was closed.`},
+	}
+	for _, s := range scenarios {
+		text, err := cfg.render(s.event, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text != s.expected {
+			t.Fatalf("expected %q, got %q", s.expected, text)
+		}
+	}
+
+	t.Setenv("CHAT_SYSTEM_MESSAGE_EVENTS", "payment.lost")
+	if _, err := loadChatSystemMessageConfig(); err == nil {
+		t.Fatal("expected error for an unknown event")
+	}
+
+	t.Setenv("CHAT_SYSTEM_MESSAGE_EVENTS", "")
+	t.Setenv("CHAT_SYSTEM_MESSAGE_TEMPLATES", "")
+	t.Setenv("CHAT_SYSTEM_MESSAGE_LOCALE", "de")
+	if _, err := loadChatSystemMessageConfig(); err == nil {
+		t.Fatal("expected error for a locale without templates")
+	}
+}
+
+func TestPaymentStatusChangePostsSystemMessage(t *testing.T) {
+	t.Setenv("CHAT_SYSTEM_MESSAGE_EVENTS", "")
+	t.Setenv("CHAT_SYSTEM_MESSAGE_LOCALE", "")
+	t.Setenv("CHAT_SYSTEM_MESSAGE_TEMPLATES", "")
+
+	cfg, err := loadChatSystemMessageConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := newTestApp(t)
+	chat := newMemoryChatProvider()
+
+	worker := newOutboxWorker(app, map[string]outboxHandler{
+		outboxJobSendSystemMessage: func(app core.App, job *models.Record) error {
+			return handleSendSystemMessage(app, chat, cfg, job)
+		},
+	})
+
+	proposal, conversation := createTestConversation(t, app)
+	channelID := conversation.GetString("stream_channel_id")
+	if err := chat.CreateChannel(context.Background(), channelID, proposal.GetString("client_id")); err != nil {
+		t.Fatal(err)
+	}
+
+	payment := createTestRecord(t, app, "payments", map[string]any{
+		"client_id":     proposal.GetString("client_id"),
+		"freelancer_id": proposal.GetString("freelancer_id"),
+		"project_id":    proposal.GetString("project_id"),
+		"amount":        25000,
+		"currency":      "usd",
+		"status":        "created",
+		"is_deleted":    false,
+		"created_at":    time.Now(),
+	})
+
+	payment, err = app.Dao().FindRecordById("payments", payment.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment.Set("status", "paid")
+	if err := app.Dao().SaveRecord(payment); err != nil {
+		t.Fatal(err)
+	}
+
+	// a retried run must not post the message twice
+	for i := 0; i < 2; i++ {
+		if err := worker.RunOnce(time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	channel, _ := chat.Channel(channelID)
+	if len(channel.Messages) != 1 {
+		t.Fatalf("expected one system message, got %+v", channel.Messages)
+	}
+
+	message := channel.Messages[0]
+	if message.Text != "Payment of 250.00 USD was received." || message.UserID != defaultChatSystemUserID {
+		t.Fatalf("unexpected message %+v", message)
+	}
+	if message.Extra["system_event"] != chatEventPaymentPaid {
+		t.Fatalf("expected system_event in extra data, got %v", message.Extra)
+	}
+}
+
+func TestFormatMinorUnits(t *testing.T) {
+	scenarios := []struct {
+		amount   int64
+		currency string
+		expected string
+	}{
+		{25000, "usd", "250.00"},
+		{5, "eur", "0.05"},
+		{-1999, "usd", "-19.99"},
+		{1500, "JPY", "1500"},
+	}
+
+	for _, s := range scenarios {
+		if formatted := formatMinorUnits(s.amount, s.currency); formatted != s.expected {
+			t.Fatalf("%d %s: expected %q, got %q", s.amount, s.currency, s.expected, formatted)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+func TestHandleProposalAcceptanceCreatesConversation(t *testing.T) {
+	app := newTestApp(t)
+	chat := newMemoryChatProvider()
+
+	worker := newOutboxWorker(app, map[string]outboxHandler{
+		outboxJobCreateConversation: func(app core.App, job *models.Record) error {
+			return handleProposalAcceptance(app, chat, job)
+		},
+	})
+
+	proposal := createTestProposal(t, app)
+	proposal.Set("status", "accepted")
+	if err := app.Dao().SaveRecord(proposal); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := worker.RunOnce(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	conversation, err := app.Dao().FindFirstRecordByData("conversations", "proposal_id", proposal.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	channel, ok := chat.Channel(conversation.GetString("stream_channel_id"))
+	if !ok {
+		t.Fatalf("expected channel %q to exist", conversation.GetString("stream_channel_id"))
+	}
+
+	expectedMembers := []string{proposal.GetString("client_id"), proposal.GetString("freelancer_id")}
+	if !sameMembers(channel.Members, expectedMembers) {
+		t.Fatalf("expected members %v, got %v", expectedMembers, channel.Members)
+	}
+
+	if status := findOutboxJobsByType(t, app, outboxJobCreateConversation)[0].GetString("status"); status != "done" {
+		t.Fatalf("expected done job, got %q", status)
+	}
+}
+
+func TestHandleProposalAcceptanceSeparatesFreelancers(t *testing.T) {
+	app := newTestApp(t)
+	chat := newMemoryChatProvider()
+
+	first := createTestProposal(t, app)
+	second := createTestRecord(t, app, "proposals", map[string]any{
+		"project_id":    first.GetString("project_id"),
+		"freelancer_id": createTestUser(t, app, "freelancer", nil).Id,
+		"client_id":     first.GetString("client_id"),
+		"message":       "Hello too",
+		"status":        "accepted",
+		"is_deleted":    false,
+	})
+	first.Set("status", "accepted")
+	if err := app.Dao().SaveRecord(first); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, proposal := range []*models.Record{first, second} {
+		if err := enqueueProposalAcceptance(app.Dao(), proposal); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	worker := newOutboxWorker(app, map[string]outboxHandler{
+		outboxJobCreateConversation: func(app core.App, job *models.Record) error {
+			return handleProposalAcceptance(app, chat, job)
+		},
+	})
+	if err := worker.RunOnce(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	channelIds := map[string]bool{}
+	for _, proposal := range []*models.Record{first, second} {
+		conversation, err := app.Dao().FindFirstRecordByData("conversations", "proposal_id", proposal.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		channelId := conversation.GetString("stream_channel_id")
+		if channelId != chatChannelID(proposal.Id) {
+			t.Fatalf("expected channel %q, got %q", chatChannelID(proposal.Id), channelId)
+		}
+		channelIds[channelId] = true
+
+		channel, _ := chat.Channel(channelId)
+		expectedMembers := []string{proposal.GetString("client_id"), proposal.GetString("freelancer_id")}
+		if !sameMembers(channel.Members, expectedMembers) {
+			t.Fatalf("expected members %v, got %v", expectedMembers, channel.Members)
+		}
+	}
+
+	if len(channelIds) != 2 {
+		t.Fatalf("expected two distinct channels, got %v", channelIds)
+	}
+}
+
+func TestHandleMigrateConversationChannel(t *testing.T) {
+	ctx := context.Background()
+	app := newTestApp(t)
+	chat := newMemoryChatProvider()
+
+	proposal := createTestProposal(t, app)
+	otherFreelancer := createTestUser(t, app, "freelancer", nil)
+	legacyChannelId := "project_" + proposal.GetString("project_id")
+
+	clientId := proposal.GetString("client_id")
+	freelancerId := proposal.GetString("freelancer_id")
+	if err := chat.CreateChannel(ctx, legacyChannelId, clientId, clientId, freelancerId, otherFreelancer.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	conversation := createTestRecord(t, app, "conversations", map[string]any{
+		"project_id":        proposal.GetString("project_id"),
+		"proposal_id":       proposal.Id,
+		"stream_channel_id": legacyChannelId,
+		"state":             "active",
+		"is_deleted":        false,
+	})
+	if err := enqueueOutboxJob(app.Dao(), outboxJobMigrateConversationChannel, conversation.Id, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	job := findOutboxJobsByType(t, app, outboxJobMigrateConversationChannel)[0]
+	// the second run must be a no-op once the conversation was moved
+	for i := 0; i < 2; i++ {
+		if err := handleMigrateConversationChannel(app, chat, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conversation, err := app.Dao().FindRecordById("conversations", conversation.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conversation.GetString("stream_channel_id") != chatChannelID(proposal.Id) ||
+		conversation.GetString("legacy_stream_channel_id") != legacyChannelId {
+		t.Fatalf("unexpected channel ids %v", conversation.PublicExport())
+	}
+
+	channel, _ := chat.Channel(chatChannelID(proposal.Id))
+	if !sameMembers(channel.Members, []string{clientId, freelancerId}) {
+		t.Fatalf("expected only client and freelancer, got %v", channel.Members)
+	}
+
+	if legacy, _ := chat.Channel(legacyChannelId); !legacy.Frozen {
+		t.Fatal("expected legacy channel to be frozen")
+	}
+}
+
+func TestHandleMigrateConversationChannelSeparatesFreelancers(t *testing.T) {
+	ctx := context.Background()
+	app := newTestApp(t)
+	chat := newMemoryChatProvider()
+
+	first := createTestProposal(t, app)
+	clientId := first.GetString("client_id")
+	second := createTestRecord(t, app, "proposals", map[string]any{
+		"project_id":    first.GetString("project_id"),
+		"freelancer_id": createTestUser(t, app, "freelancer", nil).Id,
+		"client_id":     clientId,
+		"message":       "Hello too",
+		"status":        "sent",
+		"is_deleted":    false,
+	})
+	// posted in the shared channel without a proposal of their own
+	bystander := createTestUser(t, app, "freelancer", nil)
+
+	legacyChannelId := "project_" + first.GetString("project_id")
+	members := []string{clientId, first.GetString("freelancer_id"), second.GetString("freelancer_id"), bystander.Id}
+	if err := chat.CreateChannel(ctx, legacyChannelId, clientId, members...); err != nil {
+		t.Fatal(err)
+	}
+	for _, userId := range members {
+		if err := chat.SendMessage(ctx, legacyChannelId, ChatMessage{ID: "msg_" + userId, UserID: userId, Text: "Hi"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, proposal := range []*models.Record{first, second} {
+		conversation := createTestRecord(t, app, "conversations", map[string]any{
+			"project_id":        proposal.GetString("project_id"),
+			"proposal_id":       proposal.Id,
+			"stream_channel_id": legacyChannelId,
+			"state":             "active",
+			"is_deleted":        false,
+		})
+		if err := enqueueOutboxJob(app.Dao(), outboxJobMigrateConversationChannel, conversation.Id, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, job := range findOutboxJobsByType(t, app, outboxJobMigrateConversationChannel) {
+		if err := handleMigrateConversationChannel(app, chat, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, proposal := range []*models.Record{first, second} {
+		channel, _ := chat.Channel(chatChannelID(proposal.Id))
+		if !sameMembers(channel.Members, []string{clientId, proposal.GetString("freelancer_id")}) {
+			t.Fatalf("expected only client and freelancer, got %v", channel.Members)
+		}
+	}
+
+	// no freelancer can read the other proposals in the shared history
+	legacy, _ := chat.Channel(legacyChannelId)
+	if !legacy.Frozen || !sameMembers(legacy.Members, []string{clientId}) {
+		t.Fatalf("expected a frozen legacy channel with the client only, got %v", legacy.Members)
+	}
+}
+
+func TestHandleProposalAcceptanceCancelsStaleJob(t *testing.T) {
+	app := newTestApp(t)
+	chat := newMemoryChatProvider()
+
+	proposal := createTestProposal(t, app)
+	if err := enqueueOutboxJob(app.Dao(), outboxJobCreateConversation, proposal.Id, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	err := handleProposalAcceptance(app, chat, findOutboxJobsByType(t, app, outboxJobCreateConversation)[0])
+	if !errors.Is(err, errOutboxJobCancelled) {
+		t.Fatalf("expected cancelled job for a sent proposal, got %v", err)
+	}
+}
+
+func TestMemoryChatProvider(t *testing.T) {
+	ctx := context.Background()
+	chat := newMemoryChatProvider()
+
+	if err := chat.FreezeChannel(ctx, "missing", true); !errors.Is(err, errChatChannelNotFound) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+
+	if err := chat.CreateChannel(ctx, "ch_1", "a", "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := chat.AddMembers(ctx, "ch_1", "c", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := chat.RemoveMembers(ctx, "ch_1", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := chat.FreezeChannel(ctx, "ch_1", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := chat.UpdateChannel(ctx, "ch_1", map[string]any{"name": "Project"}); err != nil {
+		t.Fatal(err)
+	}
+
+	channel, _ := chat.Channel("ch_1")
+	expected := memoryChannel{
+		ID:        "ch_1",
+		CreatedBy: "a",
+		Members:   []string{"b", "c"},
+		Data:      map[string]any{"name": "Project"},
+		Frozen:    true,
+	}
+	if !reflect.DeepEqual(channel, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, channel)
+	}
+
+	if _, err := chat.CreateToken("", time.Time{}, time.Time{}); err == nil {
+		t.Fatal("expected error for empty user id")
+	}
+}
+
+func sameMembers(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	seen := map[string]int{}
+	for _, id := range a {
+		seen[id]++
+	}
+	for _, id := range b {
+		seen[id]--
+	}
+	for _, count := range seen {
+		if count != 0 {
+			return false
+		}
+	}
+
+	return true
+}
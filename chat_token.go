@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+const (
+	outboxJobRevokeChatTokens = "revoke_chat_tokens"
+
+	defaultChatTokenTTL = time.Hour
+)
+
+// loadChatTokenTTL reads CHAT_TOKEN_TTL (a Go duration, default 1h).
+func loadChatTokenTTL() (time.Duration, error) {
+	value := strings.TrimSpace(os.Getenv("CHAT_TOKEN_TTL"))
+	if value == "" {
+		return defaultChatTokenTTL, nil
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		return 0, errors.New("CHAT_TOKEN_TTL must be a positive duration")
+	}
+
+	return ttl, nil
+}
+
+// chatTokenHandler issues a short-lived chat token. The frontend should
+// request a new one before expires_at (Stream's tokenProvider does this).
+func chatTokenHandler(app core.App, chat ChatProvider, ttl time.Duration) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+		if !ok || record == nil {
+			return apis.NewUnauthorizedError("unauthorized", nil)
+		}
+		if record.GetBool("is_deleted") {
+			return apis.NewForbiddenError("account is deleted", nil)
+		}
+		if isChatBanned(record, time.Now()) {
+			return apis.NewForbiddenError("chat access is suspended", nil)
+		}
+
+		// Stream compares iat with the revocation time at second precision.
+		issuedAt := time.Now().Truncate(time.Second)
+		expiresAt := issuedAt.Add(ttl)
+
+		token, err := chat.CreateToken(record.Id, expiresAt, issuedAt)
+		if err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to generate token", err)
+		}
+
+		return c.JSON(http.StatusOK, map[string]any{
+			"user_id":    record.Id,
+			"token":      token,
+			"expires_at": expiresAt.UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+// enqueueChatTokenRevocation revokes the chat tokens of a user that has just
+// been soft-deleted.
+func enqueueChatTokenRevocation(dao *daos.Dao, user *models.Record) error {
+	if user.IsNew() || !user.GetBool("is_deleted") || user.OriginalCopy().GetBool("is_deleted") {
+		return nil
+	}
+
+	return enqueueUserChatTokenRevocation(dao, user.Id)
+}
+
+// enqueueUserChatTokenRevocation schedules revoking every chat token the
+// user holds right now.
+func enqueueUserChatTokenRevocation(dao *daos.Dao, userID string) error {
+	return enqueueOutboxJob(dao, outboxJobRevokeChatTokens, userID, map[string]any{
+		"revoked_at": time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// handleRevokeChatTokens is the outbox handler that revokes a user's tokens.
+func handleRevokeChatTokens(app core.App, chat ChatProvider, job *models.Record) error {
+	payload := outboxJobPayload(job)
+
+	revokedAt := time.Now()
+	if value, _ := payload["revoked_at"].(string); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return err
+		}
+		revokedAt = parsed
+	}
+
+	// tokens minted in the same second as the revocation must not survive
+	return chat.RevokeUserTokens(context.Background(), job.GetString("reference_id"), revokedAt.Add(time.Second))
+}
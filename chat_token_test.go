@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+func TestLoadChatTokenTTL(t *testing.T) {
+	scenarios := []struct {
+		value       string
+		expected    time.Duration
+		expectError bool
+	}{
+		{"", defaultChatTokenTTL, false},
+		{"15m", 15 * time.Minute, false},
+		{"0s", 0, true},
+		{"forever", 0, true},
+	}
+
+	for _, s := range scenarios {
+		t.Setenv("CHAT_TOKEN_TTL", s.value)
+
+		ttl, err := loadChatTokenTTL()
+		if (err != nil) != s.expectError {
+			t.Fatalf("%q: expected error %v, got %v", s.value, s.expectError, err)
+		}
+		if ttl != s.expected {
+			t.Fatalf("%q: expected %v, got %v", s.value, s.expected, ttl)
+		}
+	}
+}
+
+func TestChatTokenHandler(t *testing.T) {
+	app := newTestApp(t)
+	chat := newMemoryChatProvider()
+	handler := chatTokenHandler(app, chat, 30*time.Minute)
+
+	user := createTestUser(t, app, "client", nil)
+
+	before := time.Now().Truncate(time.Second)
+	code, rec := callHandler(t, handler, http.MethodPost, "/chat/token", nil, nil, user)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+
+	var response struct {
+		UserID    string    `json:"user_id"`
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.UserID != user.Id || response.Token == "" {
+		t.Fatalf("unexpected response %s", rec.Body.String())
+	}
+	if response.ExpiresAt.Before(before.Add(30*time.Minute)) || response.ExpiresAt.After(time.Now().Add(30*time.Minute)) {
+		t.Fatalf("unexpected expires_at %v", response.ExpiresAt)
+	}
+
+	deleted := createTestUser(t, app, "client", map[string]any{"is_deleted": true})
+	if code, _ := callHandler(t, handler, http.MethodPost, "/chat/token", nil, nil, deleted); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a deleted user, got %d", code)
+	}
+}
+
+func TestChatTokensRevokedOnSoftDelete(t *testing.T) {
+	app := newTestApp(t)
+	chat := newMemoryChatProvider()
+
+	worker := newOutboxWorker(app, map[string]outboxHandler{
+		outboxJobRevokeChatTokens: func(app core.App, job *models.Record) error {
+			return handleRevokeChatTokens(app, chat, job)
+		},
+	})
+
+	user := createTestUser(t, app, "freelancer", nil)
+	user = mustFindUser(t, app, user.Id)
+	user.Set("is_deleted", true)
+	if err := app.Dao().SaveRecord(user); err != nil {
+		t.Fatal(err)
+	}
+
+	deletedAt := time.Now()
+	if err := worker.RunOnce(deletedAt); err != nil {
+		t.Fatal(err)
+	}
+
+	before, ok := chat.RevokedBefore(user.Id)
+	if !ok {
+		t.Fatal("expected tokens to be revoked")
+	}
+	if !before.After(deletedAt.Truncate(time.Second)) {
+		t.Fatalf("expected tokens issued up to the deletion to be revoked, got %v", before)
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/forms"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+)
+
+type chatTranscriptParticipant struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+type chatTranscriptAttachment struct {
+	Type     string `json:"type"`
+	Title    string `json:"title,omitempty"`
+	URL      string `json:"url,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+	FileSize int64  `json:"file_size,omitempty"`
+}
+
+type chatTranscriptMessage struct {
+	ID          string                     `json:"id"`
+	UserID      string                     `json:"user_id"`
+	Type        string                     `json:"type"`
+	Text        string                     `json:"text"`
+	ParentID    string                     `json:"parent_id,omitempty"`
+	CreatedAt   string                     `json:"created_at"`
+	UpdatedAt   string                     `json:"updated_at,omitempty"`
+	DeletedAt   string                     `json:"deleted_at,omitempty"`
+	Attachments []chatTranscriptAttachment `json:"attachments"`
+	Extra       map[string]any             `json:"extra,omitempty"`
+}
+
+// chatTranscript is the hashed part of an export.
+type chatTranscript struct {
+	ConversationID  string                      `json:"conversation_id"`
+	StreamChannelID string                      `json:"stream_channel_id"`
+	ProposalID      string                      `json:"proposal_id"`
+	ProjectID       string                      `json:"project_id"`
+	ProjectTitle    string                      `json:"project_title"`
+	Participants    []chatTranscriptParticipant `json:"participants"`
+	ExportedAt      string                      `json:"exported_at"`
+	MessageCount    int                         `json:"message_count"`
+	Messages        []chatTranscriptMessage     `json:"messages"`
+}
+
+// chatTranscriptExport holds both renderings of a transcript. SHA256 is the
+// hex digest of the "transcript" value exactly as it appears in JSON.
+type chatTranscriptExport struct {
+	Transcript chatTranscript
+	JSON       []byte
+	PDF        []byte
+	SHA256     string
+}
+
+func formatTranscriptTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// buildChatTranscript reads the full channel history from the chat provider
+// and renders it as JSON and PDF.
+func buildChatTranscript(app core.App, chat ChatProvider, conversation *models.Record, now time.Time) (chatTranscriptExport, error) {
+	proposal, err := app.Dao().FindRecordById("proposals", conversation.GetString("proposal_id"))
+	if err != nil {
+		return chatTranscriptExport{}, err
+	}
+	project, err := app.Dao().FindRecordById("projects", conversation.GetString("project_id"))
+	if err != nil {
+		return chatTranscriptExport{}, err
+	}
+
+	transcript := chatTranscript{
+		ConversationID:  conversation.Id,
+		StreamChannelID: conversation.GetString("stream_channel_id"),
+		ProposalID:      proposal.Id,
+		ProjectID:       project.Id,
+		ProjectTitle:    project.GetString("title"),
+		Participants:    []chatTranscriptParticipant{},
+		ExportedAt:      formatTranscriptTime(now),
+		Messages:        []chatTranscriptMessage{},
+	}
+
+	names := map[string]string{}
+	for _, id := range []string{proposal.GetString("client_id"), proposal.GetString("freelancer_id")} {
+		user, err := app.Dao().FindRecordById("users", id)
+		if err != nil {
+			return chatTranscriptExport{}, err
+		}
+		names[user.Id] = user.GetString("name")
+		transcript.Participants = append(transcript.Participants, chatTranscriptParticipant{
+			ID:   user.Id,
+			Name: user.GetString("name"),
+			Role: user.GetString("role"),
+		})
+	}
+
+	messages, err := chat.ListMessages(context.Background(), transcript.StreamChannelID)
+	if err != nil {
+		return chatTranscriptExport{}, err
+	}
+
+	for _, message := range messages {
+		item := chatTranscriptMessage{
+			ID:          message.ID,
+			UserID:      message.UserID,
+			Type:        message.Type,
+			Text:        message.Text,
+			ParentID:    message.ParentID,
+			CreatedAt:   formatTranscriptTime(message.CreatedAt),
+			UpdatedAt:   formatTranscriptTime(message.UpdatedAt),
+			DeletedAt:   formatTranscriptTime(message.DeletedAt),
+			Attachments: []chatTranscriptAttachment{},
+			Extra:       message.Extra,
+		}
+		for _, attachment := range message.Attachments {
+			item.Attachments = append(item.Attachments, chatTranscriptAttachment{
+				Type:     attachment.Type,
+				Title:    attachment.Title,
+				URL:      attachment.AssetURL,
+				MimeType: attachment.MimeType,
+				FileSize: attachment.FileSize,
+			})
+		}
+		transcript.Messages = append(transcript.Messages, item)
+	}
+	transcript.MessageCount = len(transcript.Messages)
+
+	raw, err := json.Marshal(transcript)
+	if err != nil {
+		return chatTranscriptExport{}, err
+	}
+	sum := sha256.Sum256(raw)
+	hash := hex.EncodeToString(sum[:])
+
+	document, err := json.Marshal(map[string]any{
+		"transcript": json.RawMessage(raw),
+		"integrity": map[string]string{
+			"algorithm": "sha256",
+			"hash":      hash,
+		},
+	})
+	if err != nil {
+		return chatTranscriptExport{}, err
+	}
+
+	return chatTranscriptExport{
+		Transcript: transcript,
+		JSON:       document,
+		PDF:        renderChatTranscriptPDF(transcript, names, hash),
+		SHA256:     hash,
+	}, nil
+}
+
+func renderChatTranscriptPDF(transcript chatTranscript, names map[string]string, hash string) []byte {
+	lines := []string{
+		"Conversation transcript",
+		"",
+		"Conversation: " + transcript.ConversationID + " (" + transcript.StreamChannelID + ")",
+		"Project:      " + transcript.ProjectTitle + " (" + transcript.ProjectID + ")",
+		"Proposal:     " + transcript.ProposalID,
+	}
+	for _, participant := range transcript.Participants {
+		lines = append(lines, fmt.Sprintf("Participant:  %s, %s (%s)", participant.Name, participant.Role, participant.ID))
+	}
+	lines = append(lines,
+		"Exported at:  "+transcript.ExportedAt,
+		fmt.Sprintf("Messages:     %d", transcript.MessageCount),
+		"SHA-256:      "+hash,
+		"",
+		strings.Repeat("-", textPDFLineWidth),
+	)
+
+	for _, message := range transcript.Messages {
+		author := names[message.UserID]
+		if author == "" {
+			author = message.UserID
+		}
+		if message.Type == "system" {
+			author += " (system)"
+		}
+
+		header := "[" + message.CreatedAt + "] " + author
+		if message.ParentID != "" {
+			header += " in reply to " + message.ParentID
+		}
+		lines = append(lines, "", header)
+
+		if message.DeletedAt != "" {
+			lines = append(lines, "  (deleted at "+message.DeletedAt+")")
+		}
+		if message.Text != "" {
+			for _, line := range strings.Split(message.Text, "\n") {
+				lines = append(lines, "  "+line)
+			}
+		}
+		for _, attachment := range message.Attachments {
+			lines = append(lines, fmt.Sprintf("  Attachment: %s (%s, %s, %d bytes)", attachment.Title, attachment.Type, attachment.MimeType, attachment.FileSize))
+		}
+		if message.UpdatedAt != "" && message.UpdatedAt != message.CreatedAt && message.DeletedAt == "" {
+			lines = append(lines, "  (edited at "+message.UpdatedAt+")")
+		}
+	}
+
+	return renderTextPDF(lines, "Transcript "+transcript.ConversationID+" sha256 "+hash[:16])
+}
+
+// chatTranscriptHandler lets admins and the proposal's participants download
+// a fresh transcript as JSON (default) or PDF.
+func chatTranscriptHandler(app core.App, chat ChatProvider) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		admin, _ := c.Get(apis.ContextAdminKey).(*models.Admin)
+		record, _ := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+		if admin == nil && record == nil {
+			return apis.NewUnauthorizedError("unauthorized", nil)
+		}
+
+		format := c.QueryParam("format")
+		if format == "" {
+			format = "json"
+		}
+		if format != "json" && format != "pdf" {
+			return apis.NewBadRequestError("format must be json or pdf", nil)
+		}
+
+		conversation, err := app.Dao().FindRecordById("conversations", c.PathParam("id"))
+		if err != nil || conversation.GetBool("is_deleted") {
+			return apis.NewNotFoundError("conversation not found", err)
+		}
+
+		if admin == nil {
+			proposal, err := app.Dao().FindRecordById("proposals", conversation.GetString("proposal_id"))
+			if err != nil {
+				return apis.NewNotFoundError("conversation not found", err)
+			}
+			if record.Id != proposal.GetString("client_id") && record.Id != proposal.GetString("freelancer_id") {
+				return apis.NewForbiddenError("not a participant of this conversation", nil)
+			}
+		}
+
+		export, err := buildChatTranscript(app, chat, conversation, time.Now())
+		if err != nil {
+			return apis.NewApiError(http.StatusBadGateway, "failed to export transcript", err)
+		}
+
+		name := "transcript_" + conversation.Id + "." + format
+		c.Response().Header().Set("Content-Disposition", "attachment; filename="+name)
+		c.Response().Header().Set("X-Transcript-SHA256", export.SHA256)
+
+		if format == "pdf" {
+			return c.Blob(http.StatusOK, "application/pdf", export.PDF)
+		}
+		return c.Blob(http.StatusOK, "application/json", export.JSON)
+	}
+}
+
+// chatTranscriptStoreHandler exports a transcript and stores both files on
+// the conversation record, replacing the previous export.
+func chatTranscriptStoreHandler(app core.App, chat ChatProvider) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		conversation, err := app.Dao().FindRecordById("conversations", c.PathParam("id"))
+		if err != nil || conversation.GetBool("is_deleted") {
+			return apis.NewNotFoundError("conversation not found", err)
+		}
+
+		now := time.Now()
+		export, err := buildChatTranscript(app, chat, conversation, now)
+		if err != nil {
+			return apis.NewApiError(http.StatusBadGateway, "failed to export transcript", err)
+		}
+
+		jsonFile, err := filesystem.NewFileFromBytes(export.JSON, "transcript_"+conversation.Id+".json")
+		if err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to prepare transcript", err)
+		}
+		pdfFile, err := filesystem.NewFileFromBytes(export.PDF, "transcript_"+conversation.Id+".pdf")
+		if err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to prepare transcript", err)
+		}
+
+		form := forms.NewRecordUpsert(app, conversation)
+		form.SetFullManageAccess(true)
+		if err := form.LoadData(map[string]any{
+			"transcript_sha256":      export.SHA256,
+			"transcript_exported_at": now,
+		}); err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to prepare transcript", err)
+		}
+		if err := form.AddFiles("transcript_json", jsonFile); err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to prepare transcript", err)
+		}
+		if err := form.AddFiles("transcript_pdf", pdfFile); err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to prepare transcript", err)
+		}
+		if err := form.Submit(); err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to store transcript", err)
+		}
+
+		return c.JSON(http.StatusOK, map[string]any{
+			"conversation_id": conversation.Id,
+			"transcript_json": conversation.GetString("transcript_json"),
+			"transcript_pdf":  conversation.GetString("transcript_pdf"),
+			"sha256":          export.SHA256,
+			"message_count":   export.Transcript.MessageCount,
+			"exported_at":     export.Transcript.ExportedAt,
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+)
+
+func TestChatTranscriptExport(t *testing.T) {
+	app := newTestApp(t)
+	chat := newMemoryChatProvider()
+	proposal, conversation := createTestConversation(t, app)
+
+	clientID := proposal.GetString("client_id")
+	freelancerID := proposal.GetString("freelancer_id")
+	channelID := conversation.GetString("stream_channel_id")
+	ctx := context.Background()
+
+	if err := chat.CreateChannel(ctx, channelID, clientID, clientID, freelancerID); err != nil {
+		t.Fatal(err)
+	}
+	messages := []ChatMessage{
+		{ID: "m1", UserID: clientID, Text: "Can you send the (final) draft?"},
+		{ID: "m2", UserID: freelancerID, Attachments: []ChatAttachment{{Type: "file", Title: "draft.pdf", MimeType: "application/pdf", FileSize: 1234}}},
+		{ID: "m3", UserID: clientID, Text: "Oops"},
+	}
+	for _, message := range messages {
+		if err := chat.SendMessage(ctx, channelID, message); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := chat.SendSystemMessage(ctx, channelID, ChatMessage{ID: "system_1", UserID: defaultChatSystemUserID, Text: "Payment received."}); err != nil {
+		t.Fatal(err)
+	}
+	if err := chat.DeleteMessage(ctx, "m3", false); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := app.Dao().FindRecordById("users", clientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outsider := createTestUser(t, app, "client", nil)
+
+	handler := chatTranscriptHandler(app, chat)
+	idParam := echo.PathParam{Name: "id", Value: conversation.Id}
+	target := "/chat/conversations/" + conversation.Id + "/transcript"
+
+	if code, _ := callHandler(t, handler, http.MethodGet, target, nil, nil, outsider, idParam); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a non participant, got %d", code)
+	}
+
+	code, rec := callHandler(t, handler, http.MethodGet, target, nil, nil, client, idParam)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", code, rec.Body.String())
+	}
+
+	var document struct {
+		Transcript json.RawMessage   `json:"transcript"`
+		Integrity  map[string]string `json:"integrity"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &document); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(document.Transcript)
+	if hash := hex.EncodeToString(sum[:]); hash != document.Integrity["hash"] || hash != rec.Header().Get("X-Transcript-SHA256") {
+		t.Fatalf("integrity hash mismatch: computed %s, document %s, header %s", hash, document.Integrity["hash"], rec.Header().Get("X-Transcript-SHA256"))
+	}
+
+	var transcript chatTranscript
+	if err := json.Unmarshal(document.Transcript, &transcript); err != nil {
+		t.Fatal(err)
+	}
+	if transcript.MessageCount != 4 || len(transcript.Messages) != 4 || len(transcript.Participants) != 2 {
+		t.Fatalf("unexpected transcript %+v", transcript)
+	}
+	if attachment := transcript.Messages[1].Attachments; len(attachment) != 1 || attachment[0].Title != "draft.pdf" || attachment[0].FileSize != 1234 {
+		t.Fatalf("unexpected attachments %+v", attachment)
+	}
+	if deleted := transcript.Messages[2]; deleted.Type != "deleted" || deleted.DeletedAt == "" {
+		t.Fatalf("expected a deleted message, got %+v", deleted)
+	}
+	if transcript.Messages[3].Type != "system" {
+		t.Fatalf("expected a system message, got %+v", transcript.Messages[3])
+	}
+
+	code, rec = callHandler(t, handler, http.MethodGet, target+"?format=pdf", nil, nil, client, idParam)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	pdf := rec.Body.Bytes()
+	// every export is hashed with its own exported_at
+	if !bytes.HasPrefix(pdf, []byte("%PDF-")) || !bytes.Contains(pdf, []byte(rec.Header().Get("X-Transcript-SHA256"))) {
+		t.Fatal("expected a PDF that carries the integrity hash")
+	}
+	if !bytes.Contains(pdf, []byte(`\(final\) draft`)) {
+		t.Fatal("expected escaped message text in the PDF")
+	}
+}
+
+func TestChatTranscriptStore(t *testing.T) {
+	app := newTestApp(t)
+	chat := newMemoryChatProvider()
+	proposal, conversation := createTestConversation(t, app)
+	channelID := conversation.GetString("stream_channel_id")
+
+	if err := chat.CreateChannel(context.Background(), channelID, proposal.GetString("client_id"), proposal.GetString("client_id"), proposal.GetString("freelancer_id")); err != nil {
+		t.Fatal(err)
+	}
+	if err := chat.SendMessage(context.Background(), channelID, ChatMessage{ID: "m1", UserID: proposal.GetString("client_id"), Text: "Hello"}); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := asAdmin(chatTranscriptStoreHandler(app, chat))
+	code, rec := callHandler(t, handler, http.MethodPost, "/admin/chat/conversations/"+conversation.Id+"/transcript", nil, nil, nil, echo.PathParam{Name: "id", Value: conversation.Id})
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", code, rec.Body.String())
+	}
+
+	stored, err := app.Dao().FindRecordById("conversations", conversation.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.GetString("transcript_sha256") == "" || stored.GetDateTime("transcript_exported_at").IsZero() {
+		t.Fatalf("expected transcript metadata, got %v", stored.PublicExport())
+	}
+
+	fs, err := app.NewFilesystem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	for _, field := range []string{"transcript_json", "transcript_pdf"} {
+		name := stored.GetString(field)
+		if name == "" {
+			t.Fatalf("expected %s to be stored", field)
+		}
+		reader, err := fs.GetFile(stored.BaseFilesPath() + "/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if field == "transcript_json" && !strings.Contains(string(content), stored.GetString("transcript_sha256")) {
+			t.Fatal("stored JSON transcript does not carry the stored hash")
+		}
+	}
+}
+
+func TestWrapTextPDFLine(t *testing.T) {
+	scenarios := []struct {
+		line     string
+		width    int
+		expected []string
+	}{
+		{"short", 10, []string{"short"}},
+		{"hello brave new world", 11, []string{"hello brave", "new world"}},
+		{"abcdefghijklmnop", 5, []string{"abcde", "fghij", "klmno", "p"}},
+	}
+
+	for _, s := range scenarios {
+		lines := wrapTextPDFLine(s.line, s.width)
+		if strings.Join(lines, "|") != strings.Join(s.expected, "|") {
+			t.Fatalf("%q: expected %q, got %q", s.line, s.expected, lines)
+		}
+	}
+}
@@ -6,35 +6,49 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"crypto/subtle"
+	"database/sql"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/labstack/echo/v5"
-	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
 	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/types"
 )
 
 const (
-	defaultDiditBaseURL = "https://verification.didit.me"
-	diditWebhookPath    = "/didit/webhook"
+	defaultDiditBaseURL           = "https://verification.didit.me"
+	diditWebhookPath              = "/didit/webhook"
+	defaultDiditSessionTTL        = 24 * time.Hour
+	defaultDiditMaxAttemptsPerDay = 3
+	diditAttemptsWindow           = 24 * time.Hour
+	// diditWebhookMaxSkew is how far the signed webhook timestamp may be
+	// from the current time.
+	diditWebhookMaxSkew = 5 * time.Minute
 )
 
 type diditConfig struct {
-	APIKey          string
-	WorkflowID      string
-	WebhookSecret   string
-	BaseURL         string
-	CallbackBaseURL string
+	APIKey            string
+	WorkflowID        string
+	WebhookSecrets    []string
+	BaseURL           string
+	CallbackBaseURL   string
+	SessionTTL        time.Duration
+	MaxAttemptsPerDay int
 }
 
 type DiditClient struct {
@@ -55,6 +69,13 @@ type DiditCreateSessionResponse struct {
 	// TODO: add missing response fields once Didit API schema is confirmed
 }
 
+type DiditSessionDecision struct {
+	SessionID  string `json:"session_id"`
+	Status     string `json:"status"`
+	VendorData string `json:"vendor_data"`
+	// TODO: add missing decision fields once Didit API schema is confirmed
+}
+
 type DiditErrorResponse struct {
 	Message string `json:"message"`
 	Code    string `json:"code"`
@@ -75,11 +96,11 @@ type DiditWebhookPayload struct {
 	Reason      string                      `json:"reason"`
 }
 
-func loadDiditConfig(app *pocketbase.PocketBase) (diditConfig, error) {
+func loadDiditConfig(app core.App) (diditConfig, error) {
 	cfg := diditConfig{
 		APIKey:          strings.TrimSpace(os.Getenv("DIDIT_API_KEY")),
 		WorkflowID:      strings.TrimSpace(os.Getenv("DIDIT_WORKFLOW_ID")),
-		WebhookSecret:   strings.TrimSpace(os.Getenv("DIDIT_WEBHOOK_SECRET")),
+		WebhookSecrets:  parseDiditWebhookSecrets(os.Getenv("DIDIT_WEBHOOK_SECRET")),
 		BaseURL:         strings.TrimSpace(os.Getenv("DIDIT_API_BASE_URL")),
 		CallbackBaseURL: strings.TrimSpace(os.Getenv("DIDIT_CALLBACK_BASE_URL")),
 	}
@@ -91,7 +112,25 @@ func loadDiditConfig(app *pocketbase.PocketBase) (diditConfig, error) {
 		cfg.BaseURL = defaultDiditBaseURL
 	}
 
-	if cfg.APIKey == "" || cfg.WorkflowID == "" || cfg.WebhookSecret == "" {
+	cfg.SessionTTL = defaultDiditSessionTTL
+	if ttlStr := strings.TrimSpace(os.Getenv("DIDIT_SESSION_TTL")); ttlStr != "" {
+		ttl, err := time.ParseDuration(ttlStr)
+		if err != nil || ttl <= 0 {
+			return diditConfig{}, errors.New("DIDIT_SESSION_TTL must be a positive duration (e.g. 24h)")
+		}
+		cfg.SessionTTL = ttl
+	}
+
+	cfg.MaxAttemptsPerDay = defaultDiditMaxAttemptsPerDay
+	if maxStr := strings.TrimSpace(os.Getenv("DIDIT_MAX_ATTEMPTS_PER_DAY")); maxStr != "" {
+		maxAttempts, err := strconv.Atoi(maxStr)
+		if err != nil || maxAttempts <= 0 {
+			return diditConfig{}, errors.New("DIDIT_MAX_ATTEMPTS_PER_DAY must be a positive integer")
+		}
+		cfg.MaxAttemptsPerDay = maxAttempts
+	}
+
+	if cfg.APIKey == "" || cfg.WorkflowID == "" || len(cfg.WebhookSecrets) == 0 {
 		return diditConfig{}, errors.New("DIDIT_API_KEY, DIDIT_WORKFLOW_ID, DIDIT_WEBHOOK_SECRET are required")
 	}
 	if cfg.CallbackBaseURL == "" {
@@ -101,6 +140,19 @@ func loadDiditConfig(app *pocketbase.PocketBase) (diditConfig, error) {
 	return cfg, nil
 }
 
+// parseDiditWebhookSecrets splits a comma separated list of webhook secrets.
+// During rotation the new secret is listed first, followed by the old ones.
+func parseDiditWebhookSecrets(value string) []string {
+	secrets := []string{}
+	for _, secret := range strings.Split(value, ",") {
+		secret = strings.TrimSpace(secret)
+		if secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
+}
+
 func NewDiditClient(cfg diditConfig) *DiditClient {
 	return &DiditClient{
 		HTTPClient: &http.Client{Timeout: 5 * time.Second},
@@ -153,13 +205,82 @@ func (c *DiditClient) CreateVerificationSession(ctx context.Context, req DiditCr
 	return result, nil
 }
 
-func diditStartVerificationHandler(app *pocketbase.PocketBase, client *DiditClient, cfg diditConfig) func(c echo.Context) error {
+func (c *DiditClient) GetSessionDecision(ctx context.Context, sessionID string) (DiditSessionDecision, error) {
+	endpoint := c.BaseURL + "/v2/session/" + url.PathEscape(sessionID) + "/decision/"
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return DiditSessionDecision{}, err
+	}
+	httpReq.Header.Set("x-api-key", c.APIKey)
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return DiditSessionDecision{}, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return DiditSessionDecision{}, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr DiditErrorResponse
+		_ = json.Unmarshal(respBody, &apiErr)
+		return DiditSessionDecision{}, fmt.Errorf("didit api error: status=%d message=%s body=%s", resp.StatusCode, apiErr.Message, strings.TrimSpace(string(respBody)))
+	}
+
+	var result DiditSessionDecision
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return DiditSessionDecision{}, err
+	}
+
+	if result.SessionID == "" || result.Status == "" {
+		return DiditSessionDecision{}, errors.New("didit response missing session_id or status")
+	}
+
+	return result, nil
+}
+
+func diditStartVerificationHandler(app core.App, client *DiditClient, cfg diditConfig) func(c echo.Context) error {
 	return func(c echo.Context) error {
 		record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
 		if !ok || record == nil {
 			return apis.NewUnauthorizedError("unauthorized", nil)
 		}
 
+		if record.GetString("verification_status") == "approved" {
+			return apis.NewApiError(http.StatusConflict, "user is already verified", nil)
+		}
+
+		active, err := findActiveDiditSession(app, record)
+		if err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to load didit sessions", err)
+		}
+		if active != nil {
+			return c.JSON(http.StatusOK, map[string]any{
+				"verification_url": active.GetString("verification_url"),
+				"session_id":       active.GetString("session_id"),
+				"expires_at":       active.GetDateTime("expires_at"),
+				"reused":           true,
+			})
+		}
+
+		retryAt, err := diditAttemptsCooldown(app, record.Id, cfg.MaxAttemptsPerDay, time.Now())
+		if err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to load didit sessions", err)
+		}
+		if !retryAt.IsZero() {
+			c.Response().Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(time.Until(retryAt).Seconds())), 10))
+			return c.JSON(http.StatusTooManyRequests, map[string]any{
+				"code":    http.StatusTooManyRequests,
+				"message": fmt.Sprintf("Too many verification attempts, max %d per day.", cfg.MaxAttemptsPerDay),
+				"data": map[string]any{
+					"retry_at": retryAt.UTC().Format(time.RFC3339),
+				},
+			})
+		}
+
 		callbackURL := strings.TrimRight(cfg.CallbackBaseURL, "/") + diditWebhookPath
 
 		ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
@@ -174,22 +295,96 @@ func diditStartVerificationHandler(app *pocketbase.PocketBase, client *DiditClie
 			return apis.NewApiError(http.StatusBadGateway, "failed to create didit verification session", err)
 		}
 
+		sessionsCol, err := app.Dao().FindCollectionByNameOrId("didit_sessions")
+		if err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "didit_sessions collection not found", err)
+		}
+
+		expiresAt := time.Now().Add(cfg.SessionTTL)
+
+		sessionRecord := models.NewRecord(sessionsCol)
+		sessionRecord.Set("user_id", record.Id)
+		sessionRecord.Set("session_id", session.SessionID)
+		sessionRecord.Set("verification_url", session.VerificationURL)
+		sessionRecord.Set("expires_at", expiresAt)
+		sessionRecord.Set("is_deleted", false)
+
 		record.Set("didit_session_id", session.SessionID)
 		record.Set("verification_status", "pending")
 		record.Set("verification_reason", "")
 
-		if err := app.Dao().SaveRecord(record); err != nil {
+		err = app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+			if err := txDao.SaveRecord(sessionRecord); err != nil {
+				return err
+			}
+			return txDao.SaveRecord(record)
+		})
+		if err != nil {
 			return apis.NewApiError(http.StatusInternalServerError, "failed to save didit verification status", err)
 		}
 
 		return c.JSON(http.StatusOK, map[string]any{
 			"verification_url": session.VerificationURL,
 			"session_id":       session.SessionID,
+			"expires_at":       sessionRecord.GetDateTime("expires_at"),
+			"reused":           false,
 		})
 	}
 }
 
-func diditWebhookHandler(app *pocketbase.PocketBase, cfg diditConfig) func(c echo.Context) error {
+// findActiveDiditSession returns the user's current Didit session when it is
+// still pending and has not expired yet, or nil otherwise.
+func findActiveDiditSession(app core.App, user *models.Record) (*models.Record, error) {
+	sessionID := user.GetString("didit_session_id")
+	if sessionID == "" || user.GetString("verification_status") != "pending" {
+		return nil, nil
+	}
+
+	session, err := app.Dao().FindFirstRecordByFilter(
+		"didit_sessions",
+		"session_id = {:sid} && user_id = {:uid} && is_deleted = false && expires_at > {:now} && status != 'expired' && status != 'abandoned'",
+		dbx.Params{"sid": sessionID, "uid": user.Id, "now": types.NowDateTime().String()},
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// diditAttemptsCooldown returns the time when the user may start a new
+// verification session, or a zero time if the daily limit is not reached.
+func diditAttemptsCooldown(app core.App, userID string, maxAttempts int, now time.Time) (time.Time, error) {
+	since, err := types.ParseDateTime(now.Add(-diditAttemptsWindow))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	sessions, err := app.Dao().FindRecordsByFilter(
+		"didit_sessions",
+		"user_id = {:uid} && created >= {:since}",
+		"created",
+		0,
+		0,
+		dbx.Params{"uid": userID, "since": since.String()},
+	)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(sessions) < maxAttempts {
+		return time.Time{}, nil
+	}
+
+	// the window frees up once the oldest counted attempt falls out of it
+	oldest := sessions[len(sessions)-maxAttempts].GetDateTime("created").Time()
+
+	return oldest.Add(diditAttemptsWindow), nil
+}
+
+func diditWebhookHandler(app core.App, cfg diditConfig) func(c echo.Context) error {
 	return func(c echo.Context) error {
 		body, err := io.ReadAll(c.Request().Body)
 		if err != nil {
@@ -201,45 +396,108 @@ func diditWebhookHandler(app *pocketbase.PocketBase, cfg diditConfig) func(c ech
 		if err != nil {
 			return apis.NewApiError(http.StatusUnauthorized, "invalid timestamp header", err)
 		}
-		if !isTimestampValid(timestamp, time.Now().Unix(), 300) {
-			return apis.NewApiError(http.StatusUnauthorized, "session expired", nil)
-		}
 
-		var verifiedBy string
-		signatureV2 := c.Request().Header.Get("X-Signature-V2")
+		var payload DiditWebhookPayload
+		payloadErr := json.Unmarshal(body, &payload)
 
-		ok, err := verifyDiditSignatureV2(cfg.WebhookSecret, signatureV2, body)
-		if err == nil && ok {
-			verifiedBy = "v2"
-		}
+		verifiedBy, secretIndex := verifyDiditWebhookSignature(cfg.WebhookSecrets, c.Request().Header, body, payload)
 		if verifiedBy == "" {
 			return apis.NewApiError(http.StatusUnauthorized, "invalid signature", nil)
 		}
 
-		var payload DiditWebhookPayload
-		if err := json.Unmarshal(body, &payload); err != nil {
-			return apis.NewApiError(http.StatusUnauthorized, "invalid payload", err)
+		// the X-Timestamp header is not signed, freshness is checked against
+		// the timestamp in the signed payload
+		if payloadErr == nil {
+			if payload.Timestamp != timestamp {
+				return apis.NewApiError(http.StatusUnauthorized, "timestamp header does not match the signed timestamp", nil)
+			}
+			if !isTimestampValid(payload.Timestamp, time.Now().Unix(), int64(diditWebhookMaxSkew/time.Second)) {
+				return apis.NewApiError(http.StatusUnauthorized, "session expired", nil)
+			}
 		}
 
-		if payload.SessionID == "" || payload.Status == "" || payload.WebhookType == "" {
-			log.Printf("didit webhook processed session=%s type=%s status=%s verified_by=%s", payload.SessionID, payload.WebhookType, payload.Status, verifiedBy)
+		// the simple scheme only signs a few fields, so everything else in the
+		// body is dropped and deliveries are told apart by the signed fields
+		signed := body
+		decision := diditWebhookDecision(body)
+		if verifiedBy == "simple" {
+			signed = []byte(diditSimpleSignatureMessage(payload))
+			decision = nil
+			payload.Reason = ""
+		}
+
+		delivery, err := beginWebhookDelivery(app, webhookDelivery{
+			Provider:        "didit",
+			Body:            signed,
+			EventType:       payload.WebhookType,
+			ReferenceID:     payload.SessionID,
+			SignatureScheme: verifiedBy,
+			SecretIndex:     secretIndex,
+			ReplayableUntil: time.Unix(payload.Timestamp, 0).Add(diditWebhookMaxSkew),
+		})
+		if err != nil {
+			if errors.Is(err, errDuplicateWebhookDelivery) {
+				// Didit retries anything but a 2xx, so duplicates are acknowledged.
+				log.Printf("didit webhook duplicate session=%s type=%s status=%s verified_by=%s secret_index=%d", payload.SessionID, payload.WebhookType, payload.Status, verifiedBy, secretIndex)
+				return c.JSON(http.StatusOK, map[string]string{"message": "Duplicate webhook ignored"})
+			}
+			return apis.NewApiError(http.StatusInternalServerError, "failed to store webhook delivery", err)
+		}
+
+		processed := func(outcome string, cause error) error {
+			if err := finishWebhookDelivery(app, delivery, outcome, cause); err != nil {
+				log.Printf("didit webhook failed to store outcome session=%s err=%v", payload.SessionID, err)
+			}
+			log.Printf("didit webhook processed session=%s type=%s status=%s verified_by=%s secret_index=%d outcome=%s", payload.SessionID, payload.WebhookType, payload.Status, verifiedBy, secretIndex, outcome)
 			return c.JSON(http.StatusOK, map[string]string{"message": "Webhook processed"})
 		}
+		// Didit retries on a 5xx, and the retry is not treated as a
+		// duplicate since the delivery is marked as failed
+		failed := func(message string, cause error) error {
+			if err := finishWebhookDelivery(app, delivery, webhookOutcomeSaveFailed, cause); err != nil {
+				log.Printf("didit webhook failed to store outcome session=%s err=%v", payload.SessionID, err)
+			}
+			log.Printf("didit webhook failed session=%s type=%s status=%s err=%v", payload.SessionID, payload.WebhookType, payload.Status, cause)
+			return apis.NewApiError(http.StatusInternalServerError, message, cause)
+		}
+
+		if payloadErr != nil {
+			_ = finishWebhookDelivery(app, delivery, webhookOutcomeInvalidPayload, payloadErr)
+			return apis.NewApiError(http.StatusUnauthorized, "invalid payload", payloadErr)
+		}
+
+		if payload.SessionID == "" || payload.Status == "" || payload.WebhookType == "" {
+			return processed(webhookOutcomeIgnored, nil)
+		}
+
+		if err := saveDiditSessionDecision(app, payload.SessionID, payload.Status, decision, verifiedBy, secretIndex); err != nil {
+			log.Printf("didit webhook failed to store decision session=%s err=%v", payload.SessionID, err)
+		}
 
 		user, err := app.Dao().FindFirstRecordByData("users", "didit_session_id", payload.SessionID)
 		if err != nil {
-			log.Printf("didit webhook processed session=%s type=%s status=%s verified_by=%s", payload.SessionID, payload.WebhookType, payload.Status, verifiedBy)
-			return c.JSON(http.StatusOK, map[string]string{"message": "Webhook processed"})
+			return processed(webhookOutcomeUserNotFound, nil)
+		}
+
+		// an admin decision on this session wins over later Didit updates
+		reviewed, err := hasVerificationReview(app, payload.SessionID)
+		if err != nil {
+			return failed("failed to load verification reviews", err)
+		}
+		if reviewed {
+			return processed(webhookOutcomeAdminReviewed, nil)
 		}
 
 		currentStatus := user.GetString("verification_status")
 		currentReason := user.GetString("verification_reason")
 		currentSessionID := user.GetString("didit_session_id")
 
-		status := strings.ToLower(payload.Status)
+		status := mapDiditStatus(payload.Status)
+		if status == "" {
+			return processed(webhookOutcomeUnknownStatus, nil)
+		}
 		if currentStatus == status && currentReason == payload.Reason && currentSessionID == payload.SessionID {
-			log.Printf("didit webhook processed session=%s type=%s status=%s verified_by=%s", payload.SessionID, payload.WebhookType, payload.Status, verifiedBy)
-			return c.JSON(http.StatusOK, map[string]string{"message": "Webhook processed"})
+			return processed(webhookOutcomeSameStatus, nil)
 		}
 		user.Set("verification_status", status)
 		if payload.Reason != "" {
@@ -247,14 +505,66 @@ func diditWebhookHandler(app *pocketbase.PocketBase, cfg diditConfig) func(c ech
 		}
 
 		if err := app.Dao().SaveRecord(user); err != nil {
-			log.Printf("didit webhook processed session=%s type=%s status=%s verified_by=%s", payload.SessionID, payload.WebhookType, payload.Status, verifiedBy)
-			return c.JSON(http.StatusOK, map[string]string{"message": "Webhook processed"})
+			return failed("failed to update verification status", err)
 		}
 
-		log.Printf("didit webhook processed session=%s type=%s status=%s verified_by=%s", payload.SessionID, payload.WebhookType, payload.Status, verifiedBy)
+		return processed(webhookOutcomeProcessed, nil)
+	}
+}
+
+// mapDiditStatus converts a Didit session status (e.g. "In Review") into
+// a users.verification_status value. Statuses without a local counterpart
+// (e.g. "Expired", "Abandoned") return an empty string.
+func mapDiditStatus(status string) string {
+	switch strings.ToLower(strings.TrimSpace(status)) {
+	case "not started", "in progress", "pending":
+		return "pending"
+	case "in review", "in_review", "review":
+		return "in_review"
+	case "approved":
+		return "approved"
+	case "declined", "rejected":
+		return "rejected"
+	default:
+		return ""
+	}
+}
+
+// diditWebhookDecision returns the raw decision object of a webhook body,
+// or nil when there is none.
+func diditWebhookDecision(body []byte) json.RawMessage {
+	var raw struct {
+		Decision json.RawMessage `json:"decision"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil
+	}
+	if len(raw.Decision) == 0 || string(raw.Decision) == "null" {
+		return nil
+	}
+	return raw.Decision
+}
+
+// saveDiditSessionDecision stores the latest Didit status and decision data
+// on the matching didit_sessions record so admins can review it later. A nil
+// decision keeps the stored one.
+func saveDiditSessionDecision(app core.App, sessionID string, status string, decision json.RawMessage, scheme string, secretIndex int) error {
+	session, err := app.Dao().FindFirstRecordByData("didit_sessions", "session_id", sessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
 
-		return c.JSON(http.StatusOK, map[string]string{"message": "Webhook processed"})
+	session.Set("status", strings.ToLower(strings.TrimSpace(status)))
+	session.Set("webhook_scheme", scheme)
+	session.Set("webhook_secret_index", secretIndex)
+	if decision != nil {
+		session.Set("decision", decision)
 	}
+
+	return app.Dao().SaveRecord(session)
 }
 
 func parseDiditTimestamp(value string) (int64, error) {
@@ -269,6 +579,80 @@ func isTimestampValid(ts int64, now int64, maxSkew int64) bool {
 	return diff <= maxSkew && diff >= -maxSkew
 }
 
+// verifyDiditWebhookSignature checks the request against every supported
+// signature scheme and active secret. It returns the matched scheme
+// ("v2", "original" or "simple") and the index of the secret that
+// verified it, or an empty scheme and -1 when nothing matches.
+func verifyDiditWebhookSignature(secrets []string, header http.Header, body []byte, payload DiditWebhookPayload) (string, int) {
+	signatureV2 := header.Get("X-Signature-V2")
+	signature := header.Get("X-Signature")
+	signatureSimple := header.Get("X-Signature-Simple")
+
+	if signatureV2 != "" {
+		for i, secret := range secrets {
+			if ok, err := verifyDiditSignatureV2(secret, signatureV2, body); err == nil && ok {
+				return "v2", i
+			}
+		}
+	}
+
+	if signature != "" {
+		for i, secret := range secrets {
+			if ok, err := verifyDiditSignature(secret, signature, body); err == nil && ok {
+				return "original", i
+			}
+		}
+	}
+
+	if signatureSimple != "" {
+		for i, secret := range secrets {
+			if ok, err := verifyDiditSignatureSimple(secret, signatureSimple, payload); err == nil && ok {
+				return "simple", i
+			}
+		}
+	}
+
+	return "", -1
+}
+
+// verifyDiditSignature checks the original X-Signature header,
+// an HMAC-SHA256 of the raw request body.
+func verifyDiditSignature(secret string, signature string, body []byte) (bool, error) {
+	if !compareDiditHMAC(secret, body, signature) {
+		return false, errors.New("invalid signature")
+	}
+
+	return true, nil
+}
+
+// verifyDiditSignatureSimple checks the X-Signature-Simple header, an
+// HMAC-SHA256 of "timestamp:session_id:status:webhook_type".
+func verifyDiditSignatureSimple(secret string, signature string, payload DiditWebhookPayload) (bool, error) {
+	if payload.SessionID == "" {
+		return false, errors.New("missing session_id")
+	}
+
+	if !compareDiditHMAC(secret, []byte(diditSimpleSignatureMessage(payload)), signature) {
+		return false, errors.New("invalid signature")
+	}
+
+	return true, nil
+}
+
+// diditSimpleSignatureMessage returns the fields covered by the
+// X-Signature-Simple header. Nothing else in the body is authenticated.
+func diditSimpleSignatureMessage(payload DiditWebhookPayload) string {
+	return fmt.Sprintf("%d:%s:%s:%s", payload.Timestamp, payload.SessionID, payload.Status, payload.WebhookType)
+}
+
+func compareDiditHMAC(secret string, message []byte, signature string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(message)
+	calculatedSignature := hex.EncodeToString(mac.Sum(nil))
+
+	return subtle.ConstantTimeCompare([]byte(calculatedSignature), []byte(signature)) == 1
+}
+
 func verifyDiditSignatureV2(secret string, signature string, body []byte) (bool, error) {
 	// 1. Calculate the expected signature (HMAC-SHA256)
 	mac := hmac.New(sha256.New, []byte(secret))
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testDiditAPIKey        = "test-api-key"
+	testDiditWebhookSecret = "test-webhook-secret"
+)
+
+// fakeDiditServer is a minimal in-process implementation of the Didit API
+// covering session creation and decision retrieval.
+type fakeDiditServer struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	sessions    map[string]*DiditSessionDecision
+	createCalls int
+	failCreate  bool
+}
+
+func newFakeDiditServer(t *testing.T) *fakeDiditServer {
+	t.Helper()
+
+	fake := &fakeDiditServer{sessions: map[string]*DiditSessionDecision{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/session/", fake.handle)
+
+	fake.Server = httptest.NewServer(mux)
+	t.Cleanup(fake.Close)
+
+	return fake
+}
+
+func (f *fakeDiditServer) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("x-api-key") != testDiditAPIKey {
+		writeFakeDiditJSON(w, http.StatusForbidden, DiditErrorResponse{Message: "invalid api key", Code: "forbidden"})
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v2/session/"), "/")
+
+	switch {
+	case r.Method == http.MethodPost && path == "":
+		f.createCalls++
+		if f.failCreate {
+			writeFakeDiditJSON(w, http.StatusInternalServerError, DiditErrorResponse{Message: "unavailable", Code: "internal"})
+			return
+		}
+
+		var req DiditCreateSessionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.WorkflowID == "" || req.VendorData == "" {
+			writeFakeDiditJSON(w, http.StatusBadRequest, DiditErrorResponse{Message: "invalid request", Code: "bad_request"})
+			return
+		}
+
+		id := fmt.Sprintf("sess_%d", len(f.sessions)+1)
+		f.sessions[id] = &DiditSessionDecision{SessionID: id, Status: "Not Started", VendorData: req.VendorData}
+
+		writeFakeDiditJSON(w, http.StatusCreated, DiditCreateSessionResponse{
+			SessionID:       id,
+			VerificationURL: "https://verify.didit.test/session/" + id,
+		})
+	case r.Method == http.MethodGet && strings.HasSuffix(path, "/decision"):
+		id := strings.TrimSuffix(path, "/decision")
+		session, ok := f.sessions[id]
+		if !ok {
+			writeFakeDiditJSON(w, http.StatusNotFound, DiditErrorResponse{Message: "session not found", Code: "not_found"})
+			return
+		}
+
+		writeFakeDiditJSON(w, http.StatusOK, session)
+	default:
+		writeFakeDiditJSON(w, http.StatusNotFound, DiditErrorResponse{Message: "not found", Code: "not_found"})
+	}
+}
+
+func (f *fakeDiditServer) setStatus(sessionID string, status string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if session, ok := f.sessions[sessionID]; ok {
+		session.Status = status
+	}
+}
+
+func (f *fakeDiditServer) creates() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.createCalls
+}
+
+func (f *fakeDiditServer) config() diditConfig {
+	return diditConfig{
+		APIKey:            testDiditAPIKey,
+		WorkflowID:        "test-workflow",
+		WebhookSecrets:    []string{testDiditWebhookSecret},
+		BaseURL:           f.URL,
+		CallbackBaseURL:   "http://localhost:8090",
+		SessionTTL:        defaultDiditSessionTTL,
+		MaxAttemptsPerDay: defaultDiditMaxAttemptsPerDay,
+	}
+}
+
+func writeFakeDiditJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// signDiditWebhook marshals the payload and returns the body together with
+// the headers Didit would send, signed the same way verifyDiditSignatureV2
+// checks them.
+func signDiditWebhook(t *testing.T, secret string, payload DiditWebhookPayload, ts time.Time) ([]byte, http.Header) {
+	t.Helper()
+
+	if payload.Timestamp == 0 {
+		payload.Timestamp = ts.Unix()
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("X-Timestamp", strconv.FormatInt(ts.Unix(), 10))
+	header.Set("X-Signature-V2", signDiditHMAC(secret, body))
+
+	return body, header
+}
+
+func signDiditHMAC(secret string, message []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(message)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	defaultVerificationReviewPageSize = 50
+	maxVerificationReviewPageSize     = 200
+)
+
+type verificationReviewRequest struct {
+	Note string `json:"note"`
+}
+
+func registerVerificationReviewRoutes(app core.App, router *echo.Echo, client *DiditClient) {
+	group := router.Group("/admin/verifications", apis.RequireAdminAuth())
+
+	group.GET("/review", verificationReviewListHandler(app))
+	group.GET("/:userId/reviews", verificationReviewHistoryHandler(app))
+	group.POST("/:userId/sync", verificationSyncHandler(app, client))
+	group.POST("/:userId/approve", verificationReviewDecisionHandler(app, "approve"))
+	group.POST("/:userId/reject", verificationReviewDecisionHandler(app, "reject"))
+}
+
+// verificationReviewRow is one row of the joined review queue query.
+type verificationReviewRow struct {
+	ID                 string         `db:"id"`
+	Name               string         `db:"name"`
+	Email              string         `db:"email"`
+	Role               string         `db:"role"`
+	VerificationStatus string         `db:"verification_status"`
+	VerificationReason string         `db:"verification_reason"`
+	DiditSessionID     string         `db:"didit_session_id"`
+	Updated            types.DateTime `db:"updated"`
+	DiditStatus        string         `db:"didit_status"`
+	Decision           types.JsonRaw  `db:"decision"`
+}
+
+// verificationReviewCursor points after the last user of a review page.
+type verificationReviewCursor struct {
+	Updated string `json:"u"`
+	ID      string `json:"id"`
+}
+
+func encodeVerificationReviewCursor(cursor verificationReviewCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeVerificationReviewCursor(value string) (verificationReviewCursor, error) {
+	var cursor verificationReviewCursor
+
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, err
+	}
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return cursor, err
+	}
+
+	return cursor, nil
+}
+
+// verificationReviewListHandler lists the users awaiting a manual review,
+// longest waiting first, together with their Didit session in a single
+// query per page.
+//
+// Query params: limit (default 50, max 200) and cursor (next_cursor of the
+// previous page).
+func verificationReviewListHandler(app core.App) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		limit := defaultVerificationReviewPageSize
+		if value := c.QueryParam("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 || parsed > maxVerificationReviewPageSize {
+				return apis.NewBadRequestError("limit must be between 1 and "+strconv.Itoa(maxVerificationReviewPageSize), nil)
+			}
+			limit = parsed
+		}
+
+		where := []string{
+			"u.verification_status = 'in_review'",
+			"u.is_deleted = false",
+		}
+		params := dbx.Params{}
+
+		if value := c.QueryParam("cursor"); value != "" {
+			cursor, err := decodeVerificationReviewCursor(value)
+			if err != nil || cursor.ID == "" {
+				return apis.NewBadRequestError("invalid cursor", err)
+			}
+			where = append(where, "(u.updated > {:cursor_updated} OR (u.updated = {:cursor_updated} AND u.id > {:cursor_id}))")
+			params["cursor_updated"] = cursor.Updated
+			params["cursor_id"] = cursor.ID
+		}
+
+		// one row more than requested tells whether there is a next page
+		query := `
+			SELECT
+				u.id, u.name, u.email, u.role,
+				u.verification_status, u.verification_reason, u.didit_session_id, u.updated,
+				COALESCE(s.status, '') AS didit_status, s.decision AS decision
+			FROM users u
+			LEFT JOIN didit_sessions s ON u.didit_session_id != '' AND s.session_id = u.didit_session_id
+			WHERE ` + strings.Join(where, " AND ") + `
+			ORDER BY u.updated, u.id
+			LIMIT ` + strconv.Itoa(limit+1)
+
+		rows := []verificationReviewRow{}
+		if err := app.Dao().DB().NewQuery(query).Bind(params).All(&rows); err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to load users awaiting review", err)
+		}
+
+		var nextCursor any
+		if len(rows) > limit {
+			rows = rows[:limit]
+			last := rows[len(rows)-1]
+			nextCursor = encodeVerificationReviewCursor(verificationReviewCursor{Updated: last.Updated.String(), ID: last.ID})
+		}
+
+		items := make([]map[string]any, 0, len(rows))
+		for _, row := range rows {
+			var decision any
+			if len(row.Decision) > 0 && string(row.Decision) != "null" {
+				decision = row.Decision
+			}
+
+			items = append(items, map[string]any{
+				"user_id":             row.ID,
+				"name":                row.Name,
+				"email":               row.Email,
+				"role":                row.Role,
+				"verification_status": row.VerificationStatus,
+				"verification_reason": row.VerificationReason,
+				"didit_session_id":    row.DiditSessionID,
+				"didit_status":        row.DiditStatus,
+				"decision":            decision,
+				"updated":             row.Updated,
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]any{
+			"items":       items,
+			"next_cursor": nextCursor,
+		})
+	}
+}
+
+func verificationReviewDecisionHandler(app core.App, action string) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		admin, _ := c.Get(apis.ContextAdminKey).(*models.Admin)
+		if admin == nil {
+			return apis.NewUnauthorizedError("unauthorized", nil)
+		}
+
+		var payload verificationReviewRequest
+		if err := c.Bind(&payload); err != nil {
+			return apis.NewBadRequestError("invalid request body", err)
+		}
+		payload.Note = strings.TrimSpace(payload.Note)
+		if action == "reject" && payload.Note == "" {
+			return apis.NewBadRequestError("note is required when rejecting", nil)
+		}
+
+		user, err := app.Dao().FindRecordById("users", c.PathParam("userId"))
+		if err != nil {
+			return apis.NewNotFoundError("user not found", err)
+		}
+
+		previousStatus := user.GetString("verification_status")
+		if previousStatus != "in_review" {
+			return apis.NewApiError(http.StatusConflict, "user is not awaiting review", nil)
+		}
+
+		reviewsCol, err := app.Dao().FindCollectionByNameOrId("verification_reviews")
+		if err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "verification_reviews collection not found", err)
+		}
+
+		newStatus := "approved"
+		if action == "reject" {
+			newStatus = "rejected"
+		}
+
+		user.Set("verification_status", newStatus)
+		user.Set("verification_reason", payload.Note)
+
+		review := models.NewRecord(reviewsCol)
+		review.Set("user_id", user.Id)
+		review.Set("admin_id", admin.Id)
+		review.Set("action", action)
+		review.Set("note", payload.Note)
+		review.Set("previous_status", previousStatus)
+		review.Set("didit_session_id", user.GetString("didit_session_id"))
+		review.Set("is_deleted", false)
+
+		err = app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+			if err := txDao.SaveRecord(user); err != nil {
+				return err
+			}
+			return txDao.SaveRecord(review)
+		})
+		if err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to save verification review", err)
+		}
+
+		return c.JSON(http.StatusOK, map[string]any{
+			"user_id":             user.Id,
+			"verification_status": newStatus,
+			"review_id":           review.Id,
+		})
+	}
+}
+
+// verificationSyncHandler fetches the decision of the user's current Didit
+// session and applies it, for sessions whose webhook never arrived (e.g. a
+// review resolved on the Didit side). Statuses set by an admin are kept.
+func verificationSyncHandler(app core.App, client *DiditClient) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		user, err := app.Dao().FindRecordById("users", c.PathParam("userId"))
+		if err != nil {
+			return apis.NewNotFoundError("user not found", err)
+		}
+
+		sessionID := user.GetString("didit_session_id")
+		previousStatus := user.GetString("verification_status")
+		if sessionID == "" || (previousStatus != "pending" && previousStatus != "in_review") {
+			return apis.NewApiError(http.StatusConflict, "user has no verification in progress", nil)
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+		defer cancel()
+
+		decision, err := client.GetSessionDecision(ctx, sessionID)
+		if err != nil {
+			return apis.NewApiError(http.StatusBadGateway, "failed to load didit decision", err)
+		}
+
+		err = app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+			session, err := txDao.FindFirstRecordByData("didit_sessions", "session_id", sessionID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			if session != nil {
+				session.Set("status", strings.ToLower(strings.TrimSpace(decision.Status)))
+				if err := txDao.SaveRecord(session); err != nil {
+					return err
+				}
+			}
+
+			if status := mapDiditStatus(decision.Status); status != "" && status != previousStatus {
+				user.Set("verification_status", status)
+				return txDao.SaveRecord(user)
+			}
+			return nil
+		})
+		if err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to save didit decision", err)
+		}
+
+		return c.JSON(http.StatusOK, map[string]any{
+			"user_id":             user.Id,
+			"verification_status": user.GetString("verification_status"),
+			"didit_status":        decision.Status,
+		})
+	}
+}
+
+func verificationReviewHistoryHandler(app core.App) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		reviews, err := findVerificationReviews(app, c.PathParam("userId"))
+		if err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to load verification reviews", err)
+		}
+
+		return c.JSON(http.StatusOK, reviews)
+	}
+}
+
+// hasVerificationReview reports whether an admin approved or rejected the
+// given Didit session.
+func hasVerificationReview(app core.App, sessionID string) (bool, error) {
+	_, err := app.Dao().FindFirstRecordByFilter(
+		"verification_reviews",
+		"didit_session_id = {:sid} && is_deleted = false",
+		dbx.Params{"sid": sessionID},
+	)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return false, err
+}
+
+// findVerificationReviews returns the audit trail of manual reviews for a user.
+func findVerificationReviews(app core.App, userID string) ([]*models.Record, error) {
+	return app.Dao().FindRecordsByFilter(
+		"verification_reviews",
+		"user_id = {:uid} && is_deleted = false",
+		"-created",
+		0,
+		0,
+		dbx.Params{"uid": userID},
+	)
+}
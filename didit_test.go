@@ -0,0 +1,708 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+func TestDiditClientGetSessionDecision(t *testing.T) {
+	fake := newFakeDiditServer(t)
+	client := NewDiditClient(fake.config())
+
+	session, err := client.CreateVerificationSession(context.Background(), DiditCreateSessionRequest{
+		WorkflowID: "test-workflow",
+		VendorData: "user_1",
+		Callback:   "http://localhost:8090" + diditWebhookPath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake.setStatus(session.SessionID, "Approved")
+
+	decision, err := client.GetSessionDecision(context.Background(), session.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.Status != "Approved" || decision.VendorData != "user_1" {
+		t.Fatalf("unexpected decision %+v", decision)
+	}
+
+	if _, err := client.GetSessionDecision(context.Background(), "missing"); err == nil {
+		t.Fatal("expected error for unknown session")
+	}
+}
+
+func TestVerificationSync(t *testing.T) {
+	scenarios := []struct {
+		name           string
+		currentStatus  string
+		diditStatus    string
+		expectedCode   int
+		expectedStatus string
+	}{
+		{"review resolved on didit", "in_review", "Approved", http.StatusOK, "approved"},
+		{"declined", "pending", "Declined", http.StatusOK, "rejected"},
+		{"still in review", "in_review", "In Review", http.StatusOK, "in_review"},
+		{"admin decision is kept", "rejected", "Approved", http.StatusConflict, "rejected"},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			app := newTestApp(t)
+			fake := newFakeDiditServer(t)
+			client := NewDiditClient(fake.config())
+
+			session, err := client.CreateVerificationSession(context.Background(), DiditCreateSessionRequest{
+				WorkflowID: "test-workflow",
+				VendorData: "user_1",
+				Callback:   "http://localhost:8090" + diditWebhookPath,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			fake.setStatus(session.SessionID, s.diditStatus)
+
+			user := createTestUser(t, app, "freelancer", map[string]any{
+				"didit_session_id":    session.SessionID,
+				"verification_status": s.currentStatus,
+			})
+
+			handler := asAdmin(verificationSyncHandler(app, client))
+			code, rec := callHandler(t, handler, http.MethodPost, "/admin/verifications/"+user.Id+"/sync", nil, nil, nil, echo.PathParam{Name: "userId", Value: user.Id})
+			if code != s.expectedCode {
+				t.Fatalf("expected %d, got %d (%s)", s.expectedCode, code, rec.Body.String())
+			}
+			if got := mustFindUser(t, app, user.Id).GetString("verification_status"); got != s.expectedStatus {
+				t.Fatalf("expected status %q, got %q", s.expectedStatus, got)
+			}
+		})
+	}
+}
+
+func TestDiditStartVerification(t *testing.T) {
+	scenarios := []struct {
+		name           string
+		userFields     map[string]any
+		priorSessions  int
+		failCreate     bool
+		expectedStatus int
+		expectedCreate int
+		expectReused   bool
+	}{
+		{
+			name:           "new session",
+			expectedStatus: http.StatusOK,
+			expectedCreate: 1,
+		},
+		{
+			name:           "already approved",
+			userFields:     map[string]any{"verification_status": "approved"},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "daily limit reached",
+			priorSessions:  defaultDiditMaxAttemptsPerDay,
+			expectedStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:           "didit api failure",
+			failCreate:     true,
+			expectedStatus: http.StatusBadGateway,
+			expectedCreate: 1,
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			app := newTestApp(t)
+			fake := newFakeDiditServer(t)
+			fake.failCreate = s.failCreate
+			cfg := fake.config()
+
+			user := createTestUser(t, app, "freelancer", s.userFields)
+			for i := 0; i < s.priorSessions; i++ {
+				createTestRecord(t, app, "didit_sessions", map[string]any{
+					"user_id":          user.Id,
+					"session_id":       "old_" + string(rune('a'+i)),
+					"verification_url": "https://verify.didit.test/old",
+					"expires_at":       time.Now().Add(-time.Minute),
+				})
+			}
+
+			status, rec := callHandler(t, diditStartVerificationHandler(app, NewDiditClient(cfg), cfg), http.MethodPost, "/didit/verify", nil, nil, user)
+			if status != s.expectedStatus {
+				t.Fatalf("expected status %d, got %d (%s)", s.expectedStatus, status, rec.Body.String())
+			}
+			if fake.creates() != s.expectedCreate {
+				t.Fatalf("expected %d didit create calls, got %d", s.expectedCreate, fake.creates())
+			}
+
+			if status == http.StatusTooManyRequests {
+				var body struct {
+					Data map[string]string `json:"data"`
+				}
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+					t.Fatal(err)
+				}
+				if body.Data["retry_at"] == "" || rec.Header().Get("Retry-After") == "" {
+					t.Fatalf("expected cooldown timestamp, got %s", rec.Body.String())
+				}
+			}
+
+			if status == http.StatusOK {
+				updated, err := app.Dao().FindRecordById("users", user.Id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if updated.GetString("verification_status") != "pending" || updated.GetString("didit_session_id") == "" {
+					t.Fatalf("expected pending user with session, got %v", updated.PublicExport())
+				}
+			}
+		})
+	}
+}
+
+func TestDiditStartVerificationReusesActiveSession(t *testing.T) {
+	app := newTestApp(t)
+	fake := newFakeDiditServer(t)
+	cfg := fake.config()
+	handler := diditStartVerificationHandler(app, NewDiditClient(cfg), cfg)
+
+	user := createTestUser(t, app, "client", nil)
+
+	var first, second map[string]any
+	for _, out := range []*map[string]any{&first, &second} {
+		status, rec := callHandler(t, handler, http.MethodPost, "/didit/verify", nil, nil, user)
+		if status != http.StatusOK {
+			t.Fatalf("expected 200, got %d (%s)", status, rec.Body.String())
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatal(err)
+		}
+
+		var err error
+		user, err = app.Dao().FindRecordById("users", user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if fake.creates() != 1 {
+		t.Fatalf("expected a single didit session, got %d", fake.creates())
+	}
+	if second["reused"] != true || second["verification_url"] != first["verification_url"] {
+		t.Fatalf("expected reused session, got %v", second)
+	}
+}
+
+func TestDiditWebhookStatusTransitions(t *testing.T) {
+	scenarios := []struct {
+		name           string
+		currentStatus  string
+		diditStatus    string
+		reason         string
+		expectedStatus string
+		expectedReason string
+	}{
+		{"approved", "pending", "Approved", "", "approved", ""},
+		{"declined", "pending", "Declined", "document expired", "rejected", "document expired"},
+		{"in review", "pending", "In Review", "", "in_review", ""},
+		{"in progress", "pending", "In Progress", "", "pending", ""},
+		{"expired is ignored", "pending", "Expired", "", "pending", ""},
+		{"review resolved", "in_review", "Approved", "", "approved", ""},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			app := newTestApp(t)
+			cfg := newFakeDiditServer(t).config()
+
+			user := createTestUser(t, app, "freelancer", map[string]any{
+				"didit_session_id":    "sess_1",
+				"verification_status": s.currentStatus,
+			})
+
+			body, header := signDiditWebhook(t, testDiditWebhookSecret, DiditWebhookPayload{
+				SessionID:   "sess_1",
+				Status:      s.diditStatus,
+				WebhookType: "status.updated",
+				Reason:      s.reason,
+			}, time.Now())
+
+			status, rec := callHandler(t, diditWebhookHandler(app, cfg), http.MethodPost, diditWebhookPath, body, header, nil)
+			if status != http.StatusOK {
+				t.Fatalf("expected 200, got %d (%s)", status, rec.Body.String())
+			}
+
+			updated := mustFindUser(t, app, user.Id)
+			if updated.GetString("verification_status") != s.expectedStatus {
+				t.Fatalf("expected status %q, got %q", s.expectedStatus, updated.GetString("verification_status"))
+			}
+			if updated.GetString("verification_reason") != s.expectedReason {
+				t.Fatalf("expected reason %q, got %q", s.expectedReason, updated.GetString("verification_reason"))
+			}
+		})
+	}
+}
+
+func TestDiditWebhookSignatureSchemes(t *testing.T) {
+	payload := DiditWebhookPayload{
+		SessionID:   "sess_1",
+		Status:      "Approved",
+		WebhookType: "status.updated",
+	}
+
+	scenarios := []struct {
+		name           string
+		secrets        []string
+		sign           func(body []byte, header http.Header, payload DiditWebhookPayload)
+		expectedStatus int
+	}{
+		{
+			name:           "v2",
+			secrets:        []string{testDiditWebhookSecret},
+			sign:           func(body []byte, header http.Header, payload DiditWebhookPayload) {},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:    "original",
+			secrets: []string{testDiditWebhookSecret},
+			sign: func(body []byte, header http.Header, payload DiditWebhookPayload) {
+				header.Del("X-Signature-V2")
+				header.Set("X-Signature", signDiditHMAC(testDiditWebhookSecret, body))
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:    "simple",
+			secrets: []string{testDiditWebhookSecret},
+			sign: func(body []byte, header http.Header, payload DiditWebhookPayload) {
+				header.Del("X-Signature-V2")
+				message := header.Get("X-Timestamp") + ":" + payload.SessionID + ":" + payload.Status + ":" + payload.WebhookType
+				header.Set("X-Signature-Simple", signDiditHMAC(testDiditWebhookSecret, []byte(message)))
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "rotated secret",
+			secrets:        []string{"new-secret", testDiditWebhookSecret},
+			sign:           func(body []byte, header http.Header, payload DiditWebhookPayload) {},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "unknown secret",
+			secrets:        []string{"new-secret"},
+			sign:           func(body []byte, header http.Header, payload DiditWebhookPayload) {},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:    "missing signature",
+			secrets: []string{testDiditWebhookSecret},
+			sign: func(body []byte, header http.Header, payload DiditWebhookPayload) {
+				header.Del("X-Signature-V2")
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			app := newTestApp(t)
+			cfg := newFakeDiditServer(t).config()
+			cfg.WebhookSecrets = s.secrets
+
+			createTestUser(t, app, "freelancer", map[string]any{"didit_session_id": "sess_1", "verification_status": "pending"})
+
+			body, header := signDiditWebhook(t, testDiditWebhookSecret, payload, time.Now())
+			s.sign(body, header, payload)
+
+			status, rec := callHandler(t, diditWebhookHandler(app, cfg), http.MethodPost, diditWebhookPath, body, header, nil)
+			if status != s.expectedStatus {
+				t.Fatalf("expected status %d, got %d (%s)", s.expectedStatus, status, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestDiditWebhookSimpleSchemeUsesSignedFields(t *testing.T) {
+	app := newTestApp(t)
+	cfg := newFakeDiditServer(t).config()
+	handler := diditWebhookHandler(app, cfg)
+
+	user := createTestUser(t, app, "freelancer", map[string]any{"didit_session_id": "sess_1", "verification_status": "pending"})
+	createTestRecord(t, app, "didit_sessions", map[string]any{
+		"user_id":          user.Id,
+		"session_id":       "sess_1",
+		"verification_url": "https://verify.didit.test/sess_1",
+		"expires_at":       time.Now().Add(time.Hour),
+	})
+
+	send := func(reason string) (int, string) {
+		payload := DiditWebhookPayload{
+			SessionID:   "sess_1",
+			Status:      "Declined",
+			WebhookType: "status.updated",
+			Timestamp:   time.Now().Unix(),
+			Reason:      reason,
+			Decision:    DiditWebhookPayloadDecision{SessionID: "sess_1", Status: "Declined"},
+		}
+		body, header := signDiditWebhook(t, testDiditWebhookSecret, payload, time.Now())
+		header.Del("X-Signature-V2")
+		header.Set("X-Signature-Simple", signDiditHMAC(testDiditWebhookSecret, []byte(diditSimpleSignatureMessage(payload))))
+
+		status, rec := callHandler(t, handler, http.MethodPost, diditWebhookPath, body, header, nil)
+		return status, rec.Body.String()
+	}
+
+	if status, body := send("forged reason"); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d (%s)", status, body)
+	}
+
+	updated := mustFindUser(t, app, user.Id)
+	if updated.GetString("verification_status") != "rejected" || updated.GetString("verification_reason") != "" {
+		t.Fatalf("expected only the signed status to be stored, got %v", updated.PublicExport())
+	}
+	session, err := app.Dao().FindFirstRecordByData("didit_sessions", "session_id", "sess_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.GetString("status") != "declined" || session.GetString("decision") != "" {
+		t.Fatalf("expected the unsigned decision to be dropped, got %v", session.PublicExport())
+	}
+
+	// the same signed fields with another unsigned reason are the same delivery
+	send("another reason")
+	deliveries, err := app.Dao().FindRecordsByExpr("webhook_deliveries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("expected a single stored delivery, got %d", len(deliveries))
+	}
+}
+
+func TestDiditWebhookReplay(t *testing.T) {
+	app := newTestApp(t)
+	cfg := newFakeDiditServer(t).config()
+	handler := diditWebhookHandler(app, cfg)
+
+	user := createTestUser(t, app, "freelancer", map[string]any{"didit_session_id": "sess_1", "verification_status": "pending"})
+
+	body, header := signDiditWebhook(t, testDiditWebhookSecret, DiditWebhookPayload{
+		SessionID:   "sess_1",
+		Status:      "In Review",
+		WebhookType: "status.updated",
+	}, time.Now())
+
+	if status, rec := callHandler(t, handler, http.MethodPost, diditWebhookPath, body, header, nil); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d (%s)", status, rec.Body.String())
+	}
+
+	// an admin resolves the review before the same delivery is replayed
+	reviewed := mustFindUser(t, app, user.Id)
+	reviewed.Set("verification_status", "approved")
+	if err := app.Dao().SaveRecord(reviewed); err != nil {
+		t.Fatal(err)
+	}
+
+	// the replay is acknowledged so Didit stops retrying, but not applied
+	if status, rec := callHandler(t, handler, http.MethodPost, diditWebhookPath, body, header, nil); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d (%s)", status, rec.Body.String())
+	}
+
+	// a fresh timestamp header does not match the signed one
+	header.Set("X-Timestamp", strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+	if status, rec := callHandler(t, handler, http.MethodPost, diditWebhookPath, body, header, nil); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d (%s)", status, rec.Body.String())
+	}
+
+	if got := mustFindUser(t, app, user.Id).GetString("verification_status"); got != "approved" {
+		t.Fatalf("expected replay to be ignored, status %q", got)
+	}
+
+	deliveries, err := app.Dao().FindRecordsByExpr("webhook_deliveries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("expected a single stored delivery, got %d", len(deliveries))
+	}
+}
+
+func TestDiditWebhookDeliveryOutcome(t *testing.T) {
+	scenarios := []struct {
+		name            string
+		userSessionID   string
+		userStatus      string
+		diditStatus     string
+		expectedOutcome string
+	}{
+		{"processed", "sess_1", "pending", "Approved", webhookOutcomeProcessed},
+		{"user not found", "sess_other", "pending", "Approved", webhookOutcomeUserNotFound},
+		{"same status", "sess_1", "approved", "Approved", webhookOutcomeSameStatus},
+		{"unknown status", "sess_1", "pending", "Abandoned", webhookOutcomeUnknownStatus},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			app := newTestApp(t)
+			cfg := newFakeDiditServer(t).config()
+
+			createTestUser(t, app, "freelancer", map[string]any{"didit_session_id": s.userSessionID, "verification_status": s.userStatus})
+
+			body, header := signDiditWebhook(t, testDiditWebhookSecret, DiditWebhookPayload{
+				SessionID:   "sess_1",
+				Status:      s.diditStatus,
+				WebhookType: "status.updated",
+			}, time.Now())
+
+			if status, rec := callHandler(t, diditWebhookHandler(app, cfg), http.MethodPost, diditWebhookPath, body, header, nil); status != http.StatusOK {
+				t.Fatalf("expected 200, got %d (%s)", status, rec.Body.String())
+			}
+
+			delivery, err := app.Dao().FindFirstRecordByData("webhook_deliveries", "reference_id", "sess_1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if delivery.GetString("outcome") != s.expectedOutcome {
+				t.Fatalf("expected outcome %q, got %q", s.expectedOutcome, delivery.GetString("outcome"))
+			}
+			if delivery.GetString("signature_scheme") != "v2" || delivery.GetString("provider") != "didit" {
+				t.Fatalf("unexpected delivery %v", delivery.PublicExport())
+			}
+		})
+	}
+}
+
+func TestDiditWebhookRetriesFailedSave(t *testing.T) {
+	app := newTestApp(t)
+	handler := diditWebhookHandler(app, newFakeDiditServer(t).config())
+	user := createTestUser(t, app, "freelancer", map[string]any{"didit_session_id": "sess_1", "verification_status": "pending"})
+
+	failing := app.OnModelBeforeUpdate("users").Add(func(e *core.ModelEvent) error {
+		return errors.New("disk I/O error")
+	})
+
+	body, header := signDiditWebhook(t, testDiditWebhookSecret, DiditWebhookPayload{
+		SessionID:   "sess_1",
+		Status:      "Approved",
+		WebhookType: "status.updated",
+	}, time.Now())
+	if status, _ := callHandler(t, handler, http.MethodPost, diditWebhookPath, body, header, nil); status != http.StatusInternalServerError {
+		t.Fatalf("expected 500 so Didit retries, got %d", status)
+	}
+	delivery, err := app.Dao().FindFirstRecordByData("webhook_deliveries", "reference_id", "sess_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivery.GetString("outcome") != webhookOutcomeSaveFailed {
+		t.Fatalf("expected outcome %q, got %q", webhookOutcomeSaveFailed, delivery.GetString("outcome"))
+	}
+
+	// the redelivery is applied
+	app.OnModelBeforeUpdate("users").Remove(failing)
+	if status, rec := callHandler(t, handler, http.MethodPost, diditWebhookPath, body, header, nil); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d (%s)", status, rec.Body.String())
+	}
+	if got := mustFindUser(t, app, user.Id).GetString("verification_status"); got != "approved" {
+		t.Fatalf("expected the redelivery to approve the user, got %q", got)
+	}
+	delivery, err = app.Dao().FindRecordById("webhook_deliveries", delivery.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivery.GetString("outcome") != webhookOutcomeProcessed {
+		t.Fatalf("expected outcome %q, got %q", webhookOutcomeProcessed, delivery.GetString("outcome"))
+	}
+}
+
+func TestDiditWebhookKeepsAdminReview(t *testing.T) {
+	app := newTestApp(t)
+	user := createTestUser(t, app, "freelancer", map[string]any{"didit_session_id": "sess_1", "verification_status": "in_review"})
+
+	reject := asAdmin(verificationReviewDecisionHandler(app, "reject"))
+	body, _ := json.Marshal(verificationReviewRequest{Note: "document does not match"})
+	if code, rec := callHandler(t, reject, http.MethodPost, "/admin/verifications/"+user.Id+"/reject", body, nil, nil, echo.PathParam{Name: "userId", Value: user.Id}); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d (%s)", code, rec.Body.String())
+	}
+
+	body, header := signDiditWebhook(t, testDiditWebhookSecret, DiditWebhookPayload{
+		SessionID:   "sess_1",
+		Status:      "Approved",
+		WebhookType: "status.updated",
+	}, time.Now())
+	if status, rec := callHandler(t, diditWebhookHandler(app, newFakeDiditServer(t).config()), http.MethodPost, diditWebhookPath, body, header, nil); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d (%s)", status, rec.Body.String())
+	}
+
+	updated := mustFindUser(t, app, user.Id)
+	if updated.GetString("verification_status") != "rejected" || updated.GetString("verification_reason") != "document does not match" {
+		t.Fatalf("expected the admin decision to be kept, got %q (%q)", updated.GetString("verification_status"), updated.GetString("verification_reason"))
+	}
+	delivery, err := app.Dao().FindFirstRecordByData("webhook_deliveries", "reference_id", "sess_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivery.GetString("outcome") != webhookOutcomeAdminReviewed {
+		t.Fatalf("expected outcome %q, got %q", webhookOutcomeAdminReviewed, delivery.GetString("outcome"))
+	}
+}
+
+func TestVerificationReviewListPages(t *testing.T) {
+	app := newTestApp(t)
+
+	expected := []string{}
+	for i := 0; i < 3; i++ {
+		sessionID := "sess_" + strconv.Itoa(i)
+		user := createTestUser(t, app, "freelancer", map[string]any{"didit_session_id": sessionID, "verification_status": "in_review"})
+		createTestRecord(t, app, "didit_sessions", map[string]any{
+			"user_id":          user.Id,
+			"session_id":       sessionID,
+			"verification_url": "https://verify.didit.me/session/" + sessionID,
+			"expires_at":       time.Now().Add(time.Hour),
+			"status":           "in review",
+			"decision":         map[string]any{"session_id": sessionID},
+		})
+		expected = append(expected, user.Id)
+	}
+	// neither awaiting review nor with a session
+	createTestUser(t, app, "freelancer", map[string]any{"verification_status": "pending"})
+	noSession := createTestUser(t, app, "client", map[string]any{"verification_status": "in_review"})
+	expected = append(expected, noSession.Id)
+
+	type page struct {
+		Items []struct {
+			UserID      string          `json:"user_id"`
+			DiditStatus string          `json:"didit_status"`
+			Decision    json.RawMessage `json:"decision"`
+		} `json:"items"`
+		NextCursor *string `json:"next_cursor"`
+	}
+
+	handler := asAdmin(verificationReviewListHandler(app))
+	target := "/admin/verifications/review?limit=3"
+	seen := []string{}
+	pages := 0
+	for target != "" {
+		code, rec := callHandler(t, handler, http.MethodGet, target, nil, nil, nil)
+		if code != http.StatusOK {
+			t.Fatalf("expected 200, got %d (%s)", code, rec.Body.String())
+		}
+		var p page
+		if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range p.Items {
+			seen = append(seen, item.UserID)
+			if item.UserID == noSession.Id {
+				if item.DiditStatus != "" || string(item.Decision) != "null" {
+					t.Fatalf("expected no session data, got %q %s", item.DiditStatus, item.Decision)
+				}
+			} else if item.DiditStatus != "in review" || len(item.Decision) < 2 || string(item.Decision) == "null" {
+				t.Fatalf("expected the joined session, got %q %s", item.DiditStatus, item.Decision)
+			}
+		}
+
+		pages++
+		target = ""
+		if p.NextCursor != nil {
+			target = "/admin/verifications/review?limit=3&cursor=" + *p.NextCursor
+		}
+	}
+
+	if pages != 2 || !sameMembers(seen, expected) || len(seen) != len(expected) {
+		t.Fatalf("expected %v over two pages, got %v over %d", expected, seen, pages)
+	}
+
+	if code, _ := callHandler(t, handler, http.MethodGet, "/admin/verifications/review?cursor=bm90LWpzb24", nil, nil, nil); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid cursor, got %d", code)
+	}
+}
+
+func TestDiditWebhookTimestampSkew(t *testing.T) {
+	scenarios := []struct {
+		name           string
+		offset         time.Duration
+		omitTimestamp  bool
+		freshHeader    bool
+		expectedStatus int
+	}{
+		{name: "now", expectedStatus: http.StatusOK},
+		{name: "within past window", offset: -290 * time.Second, expectedStatus: http.StatusOK},
+		{name: "within future window", offset: 290 * time.Second, expectedStatus: http.StatusOK},
+		{name: "too old", offset: -310 * time.Second, expectedStatus: http.StatusUnauthorized},
+		{name: "too far in future", offset: 310 * time.Second, expectedStatus: http.StatusUnauthorized},
+		{name: "missing timestamp", omitTimestamp: true, expectedStatus: http.StatusUnauthorized},
+		{name: "old body with a fresh header", offset: -time.Hour, freshHeader: true, expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			app := newTestApp(t)
+			cfg := newFakeDiditServer(t).config()
+
+			createTestUser(t, app, "freelancer", map[string]any{"didit_session_id": "sess_1", "verification_status": "pending"})
+
+			body, header := signDiditWebhook(t, testDiditWebhookSecret, DiditWebhookPayload{
+				SessionID:   "sess_1",
+				Status:      "Approved",
+				WebhookType: "status.updated",
+			}, time.Now().Add(s.offset))
+			if s.omitTimestamp {
+				header.Del("X-Timestamp")
+			}
+			if s.freshHeader {
+				header.Set("X-Timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+			}
+
+			status, rec := callHandler(t, diditWebhookHandler(app, cfg), http.MethodPost, diditWebhookPath, body, header, nil)
+			if status != s.expectedStatus {
+				t.Fatalf("expected status %d, got %d (%s)", s.expectedStatus, status, rec.Body.String())
+			}
+		})
+	}
+}
+
+func mustFindUser(t *testing.T, app *tests.TestApp, id string) *models.Record {
+	t.Helper()
+
+	user, err := app.Dao().FindRecordById("users", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
+
+func TestUsersCannotSetTheirVerification(t *testing.T) {
+	app := newTestApp(t)
+	user := createTestUser(t, app, "freelancer", map[string]any{"verification_status": "rejected"})
+	rule := user.Collection().UpdateRule
+
+	scenarios := []struct {
+		name     string
+		data     map[string]any
+		expected bool
+	}{
+		{"profile", map[string]any{"name": "New name"}, true},
+		{"status", map[string]any{"verification_status": "approved"}, false},
+		{"reason", map[string]any{"verification_reason": ""}, false},
+		{"session", map[string]any{"didit_session_id": "forged"}, false},
+	}
+	for _, s := range scenarios {
+		if ok := canAccess(t, app, user, rule, user, s.data); ok != s.expected {
+			t.Fatalf("%s: expected %v, got %v", s.name, s.expected, ok)
+		}
+	}
+}
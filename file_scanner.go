@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// FileScanResult is the verdict of a FileScanner. Signature names the
+// detected threat when the file is not clean.
+type FileScanResult struct {
+	Clean     bool
+	Signature string
+}
+
+// FileScanner checks uploaded files for malware before they are shared.
+type FileScanner interface {
+	Scan(ctx context.Context, content io.Reader) (FileScanResult, error)
+}
+
+// newFileScanner builds the scanner selected by FILE_SCANNER: "clamd" with
+// CLAMD_ADDRESS (default localhost:3310), or "none" to share files
+// unscanned. The choice must be explicit so a missing setting cannot turn
+// scanning off.
+func newFileScanner() (FileScanner, error) {
+	scanner := strings.ToLower(strings.TrimSpace(os.Getenv("FILE_SCANNER")))
+
+	switch scanner {
+	case "":
+		return nil, errors.New("FILE_SCANNER is required (clamd, or none to share files unscanned)")
+	case "none":
+		log.Printf("FILE_SCANNER=none: conversation files are shared without a malware scan")
+		return noopFileScanner{}, nil
+	case "clamd":
+		address := strings.TrimSpace(os.Getenv("CLAMD_ADDRESS"))
+		if address == "" {
+			address = "localhost:3310"
+		}
+		return &clamdFileScanner{address: address, timeout: 2 * time.Minute}, nil
+	default:
+		return nil, fmt.Errorf("unsupported FILE_SCANNER %q", scanner)
+	}
+}
+
+// noopFileScanner accepts every file. It is only used with FILE_SCANNER=none,
+// where no scanner is deployed.
+type noopFileScanner struct{}
+
+func (noopFileScanner) Scan(ctx context.Context, content io.Reader) (FileScanResult, error) {
+	return FileScanResult{Clean: true}, nil
+}
+
+// clamdFileScanner streams files to a clamd daemon with the INSTREAM
+// command.
+type clamdFileScanner struct {
+	address string
+	timeout time.Duration
+}
+
+const clamdChunkSize = 64 << 10
+
+func (s *clamdFileScanner) Scan(ctx context.Context, content io.Reader) (FileScanResult, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return FileScanResult{}, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+		return FileScanResult{}, err
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return FileScanResult{}, err
+	}
+
+	// each chunk is prefixed with its length; a zero length ends the stream
+	buf := make([]byte, clamdChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, readErr := content.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return FileScanResult{}, err
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return FileScanResult{}, err
+			}
+		}
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if readErr != nil {
+			return FileScanResult{}, readErr
+		}
+	}
+
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := conn.Write(size); err != nil {
+		return FileScanResult{}, err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return FileScanResult{}, err
+	}
+
+	return parseClamdReply(reply)
+}
+
+// parseClamdReply parses "stream: OK" or "stream: <signature> FOUND".
+func parseClamdReply(reply string) (FileScanResult, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	reply = strings.TrimPrefix(reply, "stream: ")
+
+	switch {
+	case reply == "OK":
+		return FileScanResult{Clean: true}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return FileScanResult{Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	default:
+		return FileScanResult{}, fmt.Errorf("clamd: %s", reply)
+	}
+}
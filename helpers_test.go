@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+// newTestApp boots a PocketBase app on an empty data dir with all
+// migrations applied, the hooks registered with the default settings and,
+// when SQLite has FTS5, the project search index.
+func newTestApp(t *testing.T) *tests.TestApp {
+	t.Helper()
+
+	lifecycleCfg, err := loadChatLifecycleConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	systemMessageCfg, err := loadChatSystemMessageConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newTestAppWithConfig(t, hooksConfig{Lifecycle: lifecycleCfg, SystemMessages: systemMessageCfg})
+}
+
+// newTestAppWithConfig is newTestApp with custom hook settings.
+func newTestAppWithConfig(t *testing.T, cfg hooksConfig) *tests.TestApp {
+	t.Helper()
+
+	app, err := tests.NewTestApp(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(app.Cleanup)
+	registerHooks(app, cfg)
+	if err := ensureProjectSearchIndex(app.Dao()); err != nil && !errors.Is(err, errProjectSearchUnavailable) {
+		t.Fatal(err)
+	}
+
+	return app
+}
+
+const testUserPassword = "1234567890"
+
+var (
+	testPasswordHashOnce  sync.Once
+	testPasswordHashValue string
+)
+
+// testPasswordHash hashes testUserPassword once, since bcrypt is slow enough
+// to dominate the tests when it runs for every user.
+func testPasswordHash(t *testing.T, col *models.Collection) string {
+	t.Helper()
+
+	testPasswordHashOnce.Do(func() {
+		user := models.NewRecord(col)
+		if err := user.SetPassword(testUserPassword); err == nil {
+			testPasswordHashValue = user.PasswordHash()
+		}
+	})
+	if testPasswordHashValue == "" {
+		t.Fatal("failed to hash the test password")
+	}
+
+	return testPasswordHashValue
+}
+
+func createTestUser(t *testing.T, app *tests.TestApp, role string, fields map[string]any) *models.Record {
+	t.Helper()
+
+	col, err := app.Dao().FindCollectionByNameOrId("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := models.NewRecord(col)
+	user.RefreshId()
+	user.SetUsername("u" + user.Id)
+	user.SetEmail(user.Id + "@example.com")
+	user.RefreshTokenKey()
+	// every test user has the password testUserPassword
+	user.Set(schema.FieldNamePasswordHash, testPasswordHash(t, col))
+	user.Set("name", "User "+user.Id)
+	user.Set("role", role)
+	user.Set("is_deleted", false)
+	for key, value := range fields {
+		user.Set(key, value)
+	}
+
+	if err := app.Dao().SaveRecord(user); err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
+
+func createTestRecord(t *testing.T, app *tests.TestApp, collection string, fields map[string]any) *models.Record {
+	t.Helper()
+
+	col, err := app.Dao().FindCollectionByNameOrId(collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := models.NewRecord(col)
+	for key, value := range fields {
+		record.Set(key, value)
+	}
+
+	if err := app.Dao().SaveRecord(record); err != nil {
+		t.Fatal(err)
+	}
+
+	return record
+}
+
+// callHandler runs an echo handler against a recorded request and returns
+// the resulting status code, converting returned api errors the same way
+// the router would.
+func callHandler(t *testing.T, handler echo.HandlerFunc, method string, target string, body []byte, header http.Header, auth *models.Record, pathParams ...echo.PathParam) (int, *httptest.ResponseRecorder) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	if req.Header.Get("Content-Type") == "" && len(body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	if auth != nil {
+		c.Set(apis.ContextAuthRecordKey, auth)
+	}
+	if len(pathParams) > 0 {
+		c.SetPathParams(pathParams)
+	}
+
+	if err := handler(c); err != nil {
+		var apiErr *apis.ApiError
+		if errors.As(err, &apiErr) {
+			return apiErr.Code, rec
+		}
+		t.Fatalf("unexpected handler error: %v", err)
+	}
+
+	return rec.Code, rec
+}
+
+// asAdmin wraps a handler so it runs with an authenticated admin, which
+// callHandler cannot set up on its own.
+func asAdmin(handler echo.HandlerFunc) echo.HandlerFunc {
+	admin := &models.Admin{}
+	admin.Id = "test_admin"
+
+	return func(c echo.Context) error {
+		c.Set(apis.ContextAdminKey, admin)
+		return handler(c)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// hooksConfig holds the settings the model hooks depend on.
+type hooksConfig struct {
+	Lifecycle      chatLifecycleConfig
+	SystemMessages chatSystemMessageConfig
+}
+
+// registerHooks binds the model hooks of every collection. It is shared by
+// main and the tests, so both run the same hooks.
+func registerHooks(app core.App, cfg hooksConfig) {
+	app.OnModelBeforeCreate("proposals").Add(func(e *core.ModelEvent) error {
+		proposal, ok := e.Model.(*models.Record)
+		if !ok {
+			return nil
+		}
+		prepareProposal(proposal)
+		return nil
+	})
+	// the job is written with the event dao, so it is committed in the same
+	// transaction as the proposal update itself
+	app.OnModelBeforeUpdate("proposals").Add(func(e *core.ModelEvent) error {
+		proposal, ok := e.Model.(*models.Record)
+		if !ok {
+			return nil
+		}
+		if err := recordProposalRevision(app, e.Dao, proposal, time.Now()); err != nil {
+			return err
+		}
+		if err := enqueueProposalLifecycle(e.Dao, cfg.Lifecycle, proposal); err != nil {
+			return err
+		}
+		return enqueueProposalAcceptance(e.Dao, proposal)
+	})
+	app.OnModelBeforeCreate("projects").Add(func(e *core.ModelEvent) error {
+		project, ok := e.Model.(*models.Record)
+		if !ok {
+			return nil
+		}
+		prepareProject(project)
+		if err := validateProject(project, time.Now()); err != nil {
+			return err
+		}
+		if err := indexProject(e.Dao, project); err != nil {
+			return err
+		}
+		return enqueueSavedSearchMatching(e.Dao, project)
+	})
+	app.OnModelBeforeUpdate("projects").Add(func(e *core.ModelEvent) error {
+		project, ok := e.Model.(*models.Record)
+		if !ok {
+			return nil
+		}
+		if err := validateProject(project, time.Now()); err != nil {
+			return err
+		}
+		if err := indexProject(e.Dao, project); err != nil {
+			return err
+		}
+		if err := enqueueSavedSearchMatching(e.Dao, project); err != nil {
+			return err
+		}
+		// announce the closing before the channel gets frozen
+		if err := enqueueProjectSystemMessages(e.Dao, cfg.SystemMessages, project); err != nil {
+			return err
+		}
+		return enqueueProjectLifecycle(e.Dao, cfg.Lifecycle, project)
+	})
+	app.OnModelBeforeDelete("projects").Add(func(e *core.ModelEvent) error {
+		return unindexProject(e.Dao, e.Model.GetId())
+	})
+	app.OnModelBeforeUpdate("payments").Add(func(e *core.ModelEvent) error {
+		payment, ok := e.Model.(*models.Record)
+		if !ok {
+			return nil
+		}
+		return enqueuePaymentSystemMessage(e.Dao, cfg.SystemMessages, payment)
+	})
+
+	app.OnModelBeforeCreate("project_invitations").Add(func(e *core.ModelEvent) error {
+		invitation, ok := e.Model.(*models.Record)
+		if !ok {
+			return nil
+		}
+		prepareProjectInvitation(invitation)
+		return nil
+	})
+	app.OnModelBeforeUpdate("project_invitations").Add(func(e *core.ModelEvent) error {
+		invitation, ok := e.Model.(*models.Record)
+		if !ok {
+			return nil
+		}
+		applyProjectInvitationResponse(invitation, time.Now())
+		return nil
+	})
+
+	app.OnModelBeforeCreate("saved_searches").Add(func(e *core.ModelEvent) error {
+		search, ok := e.Model.(*models.Record)
+		if !ok {
+			return nil
+		}
+		return prepareSavedSearch(e.Dao, search)
+	})
+	app.OnModelBeforeUpdate("saved_searches").Add(func(e *core.ModelEvent) error {
+		search, ok := e.Model.(*models.Record)
+		if !ok {
+			return nil
+		}
+		return prepareSavedSearch(e.Dao, search)
+	})
+
+	app.OnModelBeforeCreate("conversation_files").Add(func(e *core.ModelEvent) error {
+		file, ok := e.Model.(*models.Record)
+		if !ok {
+			return nil
+		}
+		return prepareConversationFile(e.Dao, file)
+	})
+
+	app.OnModelBeforeCreate("users").Add(func(e *core.ModelEvent) error {
+		user, ok := e.Model.(*models.Record)
+		if !ok {
+			return nil
+		}
+		return enqueueChatUserSync(e.Dao, user)
+	})
+	app.OnModelBeforeUpdate("users").Add(func(e *core.ModelEvent) error {
+		user, ok := e.Model.(*models.Record)
+		if !ok {
+			return nil
+		}
+		if err := enqueueUserLifecycle(e.Dao, cfg.Lifecycle, user); err != nil {
+			return err
+		}
+		if err := enqueueChatTokenRevocation(e.Dao, user); err != nil {
+			return err
+		}
+		return enqueueChatUserSync(e.Dao, user)
+	})
+	app.OnModelBeforeDelete("users").Add(func(e *core.ModelEvent) error {
+		return enqueueUserChatTokenRevocation(e.Dao, e.Model.GetId())
+	})
+}
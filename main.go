@@ -12,12 +12,10 @@ import (
 	"net/http"
 	"os"
 	"strconv"
-	"strings"
 	"time"
 
 	_ "pocketbase-backend/migrations"
 
-	stream "github.com/GetStream/stream-chat-go/v5"
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v5"
 	"github.com/labstack/echo/v5/middleware"
@@ -25,6 +23,7 @@ import (
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
 	"github.com/pocketbase/pocketbase/models"
 	"github.com/pocketbase/pocketbase/plugins/migratecmd"
 	"github.com/stripe/stripe-go/v84"
@@ -36,15 +35,106 @@ func main() {
 	app := pocketbase.New()
 	migratecmd.MustRegister(app, app.RootCmd, migratecmd.Config{})
 
-	streamClient := mustStreamClient()
+	chat, err := newChatProvider()
+	if err != nil {
+		log.Fatal(err)
+	}
+	lifecycleCfg, err := loadChatLifecycleConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	chatTokenTTL, err := loadChatTokenTTL()
+	if err != nil {
+		log.Fatal(err)
+	}
+	systemMessageCfg, err := loadChatSystemMessageConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	inquiryCfg, err := loadChatInquiryConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	chatFileCfg, err := loadChatFileConfig(app.IsDev())
+	if err != nil {
+		log.Fatal(err)
+	}
+	savedSearchCfg, err := loadSavedSearchConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fileScanner, err := newFileScanner()
+	if err != nil {
+		log.Fatal(err)
+	}
 	stripeCfg := mustStripeConfig()
 	stripe.Key = stripeCfg.SecretKey
 
-	app.OnRecordAfterUpdateRequest("proposals").Add(func(e *core.RecordUpdateEvent) error {
-		return handleProposalAcceptance(app, streamClient, e)
+	registerHooks(app, hooksConfig{
+		Lifecycle:      lifecycleCfg,
+		SystemMessages: systemMessageCfg,
+	})
+
+	app.RootCmd.AddCommand(newChatCommand(app, chat))
+
+	outbox := newOutboxWorker(app, map[string]outboxHandler{
+		outboxJobCreateConversation: func(app core.App, job *models.Record) error {
+			return handleProposalAcceptance(app, chat, job)
+		},
+		outboxJobSyncChatUser: func(app core.App, job *models.Record) error {
+			return handleChatUserSync(app, chat, job)
+		},
+		outboxJobFreezeConversation: func(app core.App, job *models.Record) error {
+			return handleFreezeConversation(app, chat, lifecycleCfg, job)
+		},
+		outboxJobUnfreezeConversation: func(app core.App, job *models.Record) error {
+			return handleUnfreezeConversation(app, chat, job)
+		},
+		outboxJobArchiveConversation: func(app core.App, job *models.Record) error {
+			return handleArchiveConversation(app, chat, job)
+		},
+		outboxJobRemoveChatMember: func(app core.App, job *models.Record) error {
+			return handleRemoveChatMember(app, chat, job)
+		},
+		outboxJobMigrateConversationChannel: func(app core.App, job *models.Record) error {
+			return handleMigrateConversationChannel(app, chat, job)
+		},
+		outboxJobRevokeChatTokens: func(app core.App, job *models.Record) error {
+			return handleRevokeChatTokens(app, chat, job)
+		},
+		outboxJobSendSystemMessage: func(app core.App, job *models.Record) error {
+			return handleSendSystemMessage(app, chat, systemMessageCfg, job)
+		},
+		outboxJobSyncInquiryRestriction: func(app core.App, job *models.Record) error {
+			return handleSyncInquiryRestriction(app, chat, systemMessageCfg.UserID, job)
+		},
+		outboxJobScanConversationFile: func(app core.App, job *models.Record) error {
+			return handleScanConversationFile(app, fileScanner, job)
+		},
+		outboxJobShareConversationFile: func(app core.App, job *models.Record) error {
+			return handleShareConversationFile(app, chat, chatFileCfg, job)
+		},
+		outboxJobMatchSavedSearches: func(app core.App, job *models.Record) error {
+			return handleMatchSavedSearches(app, savedSearchCfg, job, time.Now())
+		},
+		outboxJobSendSearchAlerts: func(app core.App, job *models.Record) error {
+			return handleSendSearchAlerts(app, job, time.Now())
+		},
+	})
+
+	app.OnTerminate().Add(func(e *core.TerminateEvent) error {
+		outbox.Stop()
+		return nil
 	})
 
 	app.OnBeforeServe().Add(func(e *core.ServeEvent) error {
+		if err := ensureProjectSearchIndex(app.Dao()); err != nil {
+			if !errors.Is(err, errProjectSearchUnavailable) {
+				return err
+			}
+			log.Printf("%v: /projects/search answers 503", err)
+		}
+
 		diditCfg, err := loadDiditConfig(app)
 		if err != nil {
 			return err
@@ -80,16 +170,12 @@ func main() {
 			if err := c.Bind(&payload); err != nil {
 				return apis.NewBadRequestError("invalid request body", err)
 			}
-			if payload.Amount <= 0 {
+			if payload.Amount < 0 {
 				return apis.NewBadRequestError("amount must be positive (in cents)", nil)
 			}
 			if payload.ProjectID == "" || payload.FreelancerID == "" {
 				return apis.NewBadRequestError("project_id and freelancer_id are required", nil)
 			}
-			if payload.Currency == "" {
-				payload.Currency = "usd"
-			}
-			payload.Currency = strings.ToLower(payload.Currency)
 
 			project, err := app.Dao().FindRecordById("projects", payload.ProjectID)
 			if err != nil {
@@ -107,6 +193,11 @@ func main() {
 				return apis.NewBadRequestError("invalid freelancer", nil)
 			}
 
+			payload.Amount, payload.Currency, err = resolveCheckoutTerms(app.Dao(), project.Id, record.Id, freelancer.Id, payload.Amount, payload.Currency)
+			if err != nil {
+				return err
+			}
+
 			platformFee := calculatePlatformFee(payload.Amount, stripeCfg.PlatformFeePercent)
 
 			paymentsCol, err := app.Dao().FindCollectionByNameOrId("payments")
@@ -117,6 +208,7 @@ func main() {
 			payment := models.NewRecord(paymentsCol)
 			payment.Set("client_id", record.Id)
 			payment.Set("freelancer_id", freelancer.Id)
+			payment.Set("project_id", project.Id)
 			payment.Set("amount", payload.Amount)
 			payment.Set("currency", payload.Currency)
 			payment.Set("stripe_checkout_session_id", "")
@@ -183,6 +275,10 @@ func main() {
 
 		e.Router.POST("/didit/verify", diditStartVerificationHandler(app, diditClient, diditCfg), apis.RequireRecordAuth())
 		e.Router.POST("/didit/webhook", diditWebhookHandler(app, diditCfg))
+		registerVerificationReviewRoutes(app, e.Router, diditClient)
+		registerOutboxRoutes(app, e.Router)
+		registerProposalRoutes(app, e.Router)
+		e.Router.GET("/projects/search", projectSearchHandler(app), apis.RequireRecordAuth())
 
 		e.Router.POST("/stripe/webhook", func(c echo.Context) error {
 			payload, err := io.ReadAll(c.Request().Body)
@@ -242,89 +338,19 @@ func main() {
 			}
 		})
 
-		e.Router.POST("/chat/token", func(c echo.Context) error {
-			record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
-			if !ok || record == nil {
-				return apis.NewUnauthorizedError("unauthorized", nil)
-			}
-
-			token, err := streamClient.CreateToken(record.Id, time.Time{})
-			if err != nil {
-				return apis.NewApiError(http.StatusInternalServerError, "failed to generate token", err)
-			}
-
-			return c.JSON(http.StatusOK, map[string]any{
-				"user_id": record.Id,
-				"token":   token,
-			})
-		}, apis.RequireRecordAuth())
-
-		e.Router.GET("/chat/conversations", func(c echo.Context) error {
-			record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
-			if !ok || record == nil {
-				return apis.NewUnauthorizedError("unauthorized", nil)
-			}
-
-			proposals, err := app.Dao().FindRecordsByFilter(
-				"proposals",
-				"status = 'accepted' && is_deleted = false && (client_id = {:uid} || freelancer_id = {:uid})",
-				"-created",
-				200,
-				0,
-				dbx.Params{"uid": record.Id},
-			)
-			if err != nil {
-				return apis.NewApiError(http.StatusInternalServerError, "failed to load proposals", err)
-			}
-
-			response := make([]map[string]any, 0, len(proposals))
-			for _, proposal := range proposals {
-				conversation, err := app.Dao().FindFirstRecordByFilter(
-					"conversations",
-					"proposal_id = {:pid} && is_deleted = false",
-					dbx.Params{"pid": proposal.Id},
-				)
-				if err != nil {
-					if errors.Is(err, sql.ErrNoRows) {
-						continue
-					}
-					return apis.NewApiError(http.StatusInternalServerError, "failed to load conversation", err)
-				}
-
-				project, err := app.Dao().FindRecordById("projects", proposal.GetString("project_id"))
-				if err != nil {
-					return apis.NewApiError(http.StatusInternalServerError, "failed to load project", err)
-				}
-
-				counterpartId := proposal.GetString("freelancer_id")
-				if counterpartId == record.Id {
-					counterpartId = proposal.GetString("client_id")
-				}
+		e.Router.POST("/stream/webhook", streamWebhookHandler(app, os.Getenv("STREAM_API_SECRET"), inquiryCfg))
 
-				counterpart, err := app.Dao().FindRecordById("users", counterpartId)
-				if err != nil {
-					return apis.NewApiError(http.StatusInternalServerError, "failed to load counterpart", err)
-				}
+		e.Router.POST("/chat/token", chatTokenHandler(app, chat, chatTokenTTL), apis.RequireRecordAuth())
+		e.Router.POST("/chat/report", chatReportHandler(app, chat), apis.RequireRecordAuth())
+		e.Router.POST("/chat/inquiries", chatInquiryHandler(app, chat, inquiryCfg), apis.RequireRecordAuth())
+		e.Router.GET("/chat/files/:id/url", chatFileURLHandler(app, chatFileCfg), apis.RequireRecordAuth())
+		e.Router.GET("/chat/files/:id/download", chatFileDownloadHandler(app, chatFileCfg))
+		registerChatModerationRoutes(app, e.Router, chat, systemMessageCfg.UserID)
 
-				response = append(response, map[string]any{
-					"conversation_id":   conversation.Id,
-					"stream_channel_id": conversation.GetString("stream_channel_id"),
-					"project": map[string]any{
-						"id":     project.Id,
-						"title":  project.GetString("title"),
-						"status": project.GetString("status"),
-					},
-					"counterpart": map[string]any{
-						"id":   counterpart.Id,
-						"name": counterpart.GetString("name"),
-						"role": counterpart.GetString("role"),
-					},
-					"proposal_id": proposal.Id,
-				})
-			}
+		e.Router.GET("/chat/conversations", chatConversationsHandler(app), apis.RequireRecordAuth())
+		e.Router.GET("/chat/conversations/:id/transcript", chatTranscriptHandler(app, chat), apis.RequireAdminOrRecordAuth())
 
-			return c.JSON(http.StatusOK, response)
-		}, apis.RequireRecordAuth())
+		outbox.Start()
 
 		return nil
 	})
@@ -357,49 +383,78 @@ func updatePaymentFromWebhook(app *pocketbase.PocketBase, paymentID string, stat
 	return nil
 }
 
-func handleProposalAcceptance(app *pocketbase.PocketBase, client *stream.Client, e *core.RecordUpdateEvent) error {
-	if e.Record == nil {
+// enqueueProposalAcceptance schedules the Stream channel creation for an
+// accepted proposal that has no conversation yet, or the upgrade of its
+// inquiry conversation.
+func enqueueProposalAcceptance(dao *daos.Dao, proposal *models.Record) error {
+	if proposal.GetBool("is_deleted") || proposal.GetString("status") != "accepted" {
 		return nil
 	}
 
-	if e.Record.GetBool("is_deleted") {
+	existing, err := dao.FindFirstRecordByFilter(
+		"conversations",
+		"proposal_id = {:pid} && is_deleted = false",
+		dbx.Params{"pid": proposal.Id},
+	)
+	if err == nil && existing.GetString("type") != "inquiry" {
 		return nil
 	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
 
-	if e.Record.GetString("status") != "accepted" {
-		return nil
+	return enqueueOutboxJob(dao, outboxJobCreateConversation, proposal.Id, map[string]any{
+		"proposal_id": proposal.Id,
+	})
+}
+
+// handleProposalAcceptance is the outbox handler that creates the Stream
+// channel and the matching conversation record for an accepted proposal.
+func handleProposalAcceptance(app core.App, chat ChatProvider, job *models.Record) error {
+	proposal, err := app.Dao().FindRecordById("proposals", job.GetString("reference_id"))
+	if err != nil {
+		return err
+	}
+
+	if proposal.GetBool("is_deleted") || proposal.GetString("status") != "accepted" {
+		return errOutboxJobCancelled
 	}
 
-	_, err := app.Dao().FindFirstRecordByFilter(
+	existing, err := app.Dao().FindFirstRecordByFilter(
 		"conversations",
 		"proposal_id = {:pid} && is_deleted = false",
-		dbx.Params{"pid": e.Record.Id},
+		dbx.Params{"pid": proposal.Id},
 	)
 	if err == nil {
+		if existing.GetString("type") == "inquiry" {
+			return upgradeInquiryConversation(app, chat, existing)
+		}
 		return nil
 	}
 	if !errors.Is(err, sql.ErrNoRows) {
 		return err
 	}
 
-	project, err := app.Dao().FindRecordById("projects", e.Record.GetString("project_id"))
+	project, err := app.Dao().FindRecordById("projects", proposal.GetString("project_id"))
 	if err != nil {
 		return err
 	}
 
-	clientId := e.Record.GetString("client_id")
-	freelancerId := e.Record.GetString("freelancer_id")
-	channelId := "project_" + project.Id
+	clientId := proposal.GetString("client_id")
+	freelancerId := proposal.GetString("freelancer_id")
+	channelId := chatChannelID(proposal.Id)
 
-	_, err = client.UpsertUsers(context.Background(),
-		&stream.User{ID: clientId},
-		&stream.User{ID: freelancerId},
-	)
+	users, err := findChatUsers(app, clientId, freelancerId)
 	if err != nil {
 		return err
 	}
 
-	_, err = client.CreateChannelWithMembers(context.Background(), "messaging", channelId, clientId, freelancerId)
+	err = chat.UpsertUsers(context.Background(), users...)
+	if err != nil {
+		return err
+	}
+
+	err = chat.CreateChannel(context.Background(), channelId, clientId, clientId, freelancerId)
 	if err != nil {
 		return err
 	}
@@ -411,8 +466,10 @@ func handleProposalAcceptance(app *pocketbase.PocketBase, client *stream.Client,
 
 	conversation := models.NewRecord(collection)
 	conversation.Set("project_id", project.Id)
-	conversation.Set("proposal_id", e.Record.Id)
+	conversation.Set("proposal_id", proposal.Id)
 	conversation.Set("stream_channel_id", channelId)
+	conversation.Set("type", "proposal")
+	conversation.Set("state", "active")
 	conversation.Set("is_deleted", false)
 
 	return app.Dao().SaveRecord(conversation)
@@ -426,21 +483,6 @@ func formatPercent(value float64) string {
 	return strconv.FormatFloat(value, 'f', -1, 64)
 }
 
-func mustStreamClient() *stream.Client {
-	apiKey := os.Getenv("STREAM_API_KEY")
-	apiSecret := os.Getenv("STREAM_API_SECRET")
-	if apiKey == "" || apiSecret == "" {
-		log.Fatal("STREAM_API_KEY and STREAM_API_SECRET are required")
-	}
-
-	client, err := stream.NewClient(apiKey, apiSecret)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	return client
-}
-
 func mustStripeConfig() stripeConfig {
 	secret := os.Getenv("STRIPE_SECRET_KEY")
 	successURL := os.Getenv("STRIPE_SUCCESS_URL")
@@ -0,0 +1,74 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func init() {
+	migrations.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		usersCol, err := dao.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+
+		// -----------------------------
+		// DIDIT SESSIONS (admin only)
+		// -----------------------------
+		sessions := &models.Collection{
+			Name:   "didit_sessions",
+			Type:   models.CollectionTypeBase,
+			System: false,
+			Indexes: []string{
+				"CREATE UNIQUE INDEX idx_didit_sessions_session_id ON didit_sessions (session_id)",
+				"CREATE INDEX idx_didit_sessions_user_created ON didit_sessions (user_id, created)",
+			},
+			Schema: schema.NewSchema(
+				&schema.SchemaField{
+					Name:     "user_id",
+					Type:     schema.FieldTypeRelation,
+					Required: true,
+					Options: &schema.RelationOptions{
+						CollectionId: usersCol.Id,
+						MaxSelect:    &maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name:     "session_id",
+					Type:     schema.FieldTypeText,
+					Required: true,
+				},
+				&schema.SchemaField{
+					Name:     "verification_url",
+					Type:     schema.FieldTypeText,
+					Required: true,
+				},
+				&schema.SchemaField{
+					Name:     "expires_at",
+					Type:     schema.FieldTypeDate,
+					Required: true,
+				},
+				&schema.SchemaField{
+					Name: "is_deleted",
+					Type: schema.FieldTypeBool,
+				},
+			),
+		}
+
+		return dao.SaveCollection(sessions)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		col, err := dao.FindCollectionByNameOrId("didit_sessions")
+		if err != nil {
+			return err
+		}
+
+		return dao.DeleteCollection(col)
+	})
+}
@@ -0,0 +1,153 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+const (
+	usersUpdateRuleBeforeReview = "@request.auth.id = id && is_deleted = false"
+	// the verification outcome is only set by the Didit webhook and the
+	// admin review, never by the user
+	usersUpdateRuleWithReview = usersUpdateRuleBeforeReview +
+		" && @request.data.verification_status:isset = false" +
+		" && @request.data.verification_reason:isset = false" +
+		" && @request.data.didit_session_id:isset = false"
+)
+
+func init() {
+	migrations.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		usersCol, err := dao.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+
+		statusField := usersCol.Schema.GetFieldByName("verification_status")
+		if statusField != nil {
+			statusField.Options = &schema.SelectOptions{
+				Values:    []string{"pending", "in_review", "approved", "rejected"},
+				MaxSelect: maxSelectOption,
+			}
+		}
+		usersCol.UpdateRule = strPtr(usersUpdateRuleWithReview)
+
+		if err := dao.SaveCollection(usersCol); err != nil {
+			return err
+		}
+
+		sessionsCol, err := dao.FindCollectionByNameOrId("didit_sessions")
+		if err != nil {
+			return err
+		}
+
+		sessionsCol.Schema.AddField(&schema.SchemaField{
+			Name: "status",
+			Type: schema.FieldTypeText,
+		})
+		sessionsCol.Schema.AddField(&schema.SchemaField{
+			Name:    "decision",
+			Type:    schema.FieldTypeJson,
+			Options: &schema.JsonOptions{MaxSize: 2 << 20},
+		})
+
+		if err := dao.SaveCollection(sessionsCol); err != nil {
+			return err
+		}
+
+		// -----------------------------
+		// VERIFICATION REVIEWS (admin only audit trail)
+		// -----------------------------
+		reviews := &models.Collection{
+			Name:   "verification_reviews",
+			Type:   models.CollectionTypeBase,
+			System: false,
+			Schema: schema.NewSchema(
+				&schema.SchemaField{
+					Name:     "user_id",
+					Type:     schema.FieldTypeRelation,
+					Required: true,
+					Options: &schema.RelationOptions{
+						CollectionId: usersCol.Id,
+						MaxSelect:    &maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name:     "admin_id",
+					Type:     schema.FieldTypeText,
+					Required: true,
+				},
+				&schema.SchemaField{
+					Name:     "action",
+					Type:     schema.FieldTypeSelect,
+					Required: true,
+					Options: &schema.SelectOptions{
+						Values:    []string{"approve", "reject"},
+						MaxSelect: maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name: "note",
+					Type: schema.FieldTypeText,
+				},
+				&schema.SchemaField{
+					Name: "previous_status",
+					Type: schema.FieldTypeText,
+				},
+				&schema.SchemaField{
+					Name: "didit_session_id",
+					Type: schema.FieldTypeText,
+				},
+				&schema.SchemaField{
+					Name: "is_deleted",
+					Type: schema.FieldTypeBool,
+				},
+			),
+		}
+
+		return dao.SaveCollection(reviews)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		reviewsCol, err := dao.FindCollectionByNameOrId("verification_reviews")
+		if err != nil {
+			return err
+		}
+		if err := dao.DeleteCollection(reviewsCol); err != nil {
+			return err
+		}
+
+		sessionsCol, err := dao.FindCollectionByNameOrId("didit_sessions")
+		if err != nil {
+			return err
+		}
+		for _, name := range []string{"status", "decision"} {
+			if field := sessionsCol.Schema.GetFieldByName(name); field != nil {
+				sessionsCol.Schema.RemoveField(field.Id)
+			}
+		}
+		if err := dao.SaveCollection(sessionsCol); err != nil {
+			return err
+		}
+
+		usersCol, err := dao.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+
+		statusField := usersCol.Schema.GetFieldByName("verification_status")
+		if statusField != nil {
+			statusField.Options = &schema.SelectOptions{
+				Values:    []string{"pending", "approved", "rejected"},
+				MaxSelect: maxSelectOption,
+			}
+		}
+		usersCol.UpdateRule = strPtr(usersUpdateRuleBeforeReview)
+
+		return dao.SaveCollection(usersCol)
+	})
+}
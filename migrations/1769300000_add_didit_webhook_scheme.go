@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func init() {
+	migrations.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		sessionsCol, err := dao.FindCollectionByNameOrId("didit_sessions")
+		if err != nil {
+			return err
+		}
+
+		sessionsCol.Schema.AddField(&schema.SchemaField{
+			Name: "webhook_scheme",
+			Type: schema.FieldTypeText,
+		})
+		sessionsCol.Schema.AddField(&schema.SchemaField{
+			Name: "webhook_secret_index",
+			Type: schema.FieldTypeNumber,
+		})
+
+		return dao.SaveCollection(sessionsCol)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		sessionsCol, err := dao.FindCollectionByNameOrId("didit_sessions")
+		if err != nil {
+			return err
+		}
+
+		for _, name := range []string{"webhook_scheme", "webhook_secret_index"} {
+			if field := sessionsCol.Schema.GetFieldByName(name); field != nil {
+				sessionsCol.Schema.RemoveField(field.Id)
+			}
+		}
+
+		return dao.SaveCollection(sessionsCol)
+	})
+}
@@ -0,0 +1,84 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func init() {
+	migrations.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		// -----------------------------
+		// WEBHOOK DELIVERIES (admin only)
+		// -----------------------------
+		deliveries := &models.Collection{
+			Name:   "webhook_deliveries",
+			Type:   models.CollectionTypeBase,
+			System: false,
+			Indexes: []string{
+				"CREATE UNIQUE INDEX idx_webhook_deliveries_provider_hash ON webhook_deliveries (provider, delivery_hash)",
+				"CREATE INDEX idx_webhook_deliveries_expires_at ON webhook_deliveries (expires_at)",
+			},
+			Schema: schema.NewSchema(
+				&schema.SchemaField{
+					Name:     "provider",
+					Type:     schema.FieldTypeText,
+					Required: true,
+				},
+				&schema.SchemaField{
+					Name:     "delivery_hash",
+					Type:     schema.FieldTypeText,
+					Required: true,
+				},
+				&schema.SchemaField{
+					Name: "event_type",
+					Type: schema.FieldTypeText,
+				},
+				&schema.SchemaField{
+					Name: "reference_id",
+					Type: schema.FieldTypeText,
+				},
+				&schema.SchemaField{
+					Name: "signature_scheme",
+					Type: schema.FieldTypeText,
+				},
+				&schema.SchemaField{
+					Name: "secret_index",
+					Type: schema.FieldTypeNumber,
+				},
+				&schema.SchemaField{
+					Name: "outcome",
+					Type: schema.FieldTypeText,
+				},
+				&schema.SchemaField{
+					Name: "error",
+					Type: schema.FieldTypeText,
+				},
+				&schema.SchemaField{
+					Name:     "expires_at",
+					Type:     schema.FieldTypeDate,
+					Required: true,
+				},
+				&schema.SchemaField{
+					Name: "is_deleted",
+					Type: schema.FieldTypeBool,
+				},
+			),
+		}
+
+		return dao.SaveCollection(deliveries)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		col, err := dao.FindCollectionByNameOrId("webhook_deliveries")
+		if err != nil {
+			return err
+		}
+
+		return dao.DeleteCollection(col)
+	})
+}
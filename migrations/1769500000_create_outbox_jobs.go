@@ -0,0 +1,85 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func init() {
+	migrations.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		// -----------------------------
+		// OUTBOX JOBS (admin only)
+		// -----------------------------
+		jobs := &models.Collection{
+			Name:   "outbox_jobs",
+			Type:   models.CollectionTypeBase,
+			System: false,
+			Indexes: []string{
+				"CREATE INDEX idx_outbox_jobs_status_next ON outbox_jobs (status, next_attempt_at)",
+				"CREATE INDEX idx_outbox_jobs_type_reference ON outbox_jobs (type, reference_id)",
+			},
+			Schema: schema.NewSchema(
+				&schema.SchemaField{
+					Name:     "type",
+					Type:     schema.FieldTypeText,
+					Required: true,
+				},
+				&schema.SchemaField{
+					Name:     "reference_id",
+					Type:     schema.FieldTypeText,
+					Required: true,
+				},
+				&schema.SchemaField{
+					Name:    "payload",
+					Type:    schema.FieldTypeJson,
+					Options: &schema.JsonOptions{MaxSize: 2 << 20},
+				},
+				&schema.SchemaField{
+					Name:     "status",
+					Type:     schema.FieldTypeSelect,
+					Required: true,
+					Options: &schema.SelectOptions{
+						Values:    []string{"pending", "done", "cancelled", "dead"},
+						MaxSelect: maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name: "attempts",
+					Type: schema.FieldTypeNumber,
+				},
+				&schema.SchemaField{
+					Name: "next_attempt_at",
+					Type: schema.FieldTypeDate,
+				},
+				&schema.SchemaField{
+					Name: "last_error",
+					Type: schema.FieldTypeText,
+				},
+				&schema.SchemaField{
+					Name: "processed_at",
+					Type: schema.FieldTypeDate,
+				},
+				&schema.SchemaField{
+					Name: "is_deleted",
+					Type: schema.FieldTypeBool,
+				},
+			),
+		}
+
+		return dao.SaveCollection(jobs)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		col, err := dao.FindCollectionByNameOrId("outbox_jobs")
+		if err != nil {
+			return err
+		}
+
+		return dao.DeleteCollection(col)
+	})
+}
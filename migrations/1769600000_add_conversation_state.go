@@ -0,0 +1,67 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func init() {
+	migrations.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		conversationsCol, err := dao.FindCollectionByNameOrId("conversations")
+		if err != nil {
+			return err
+		}
+
+		conversationsCol.Schema.AddField(&schema.SchemaField{
+			Name: "state",
+			Type: schema.FieldTypeSelect,
+			Options: &schema.SelectOptions{
+				Values:    []string{"active", "frozen", "archived"},
+				MaxSelect: maxSelectOption,
+			},
+		})
+		conversationsCol.Schema.AddField(&schema.SchemaField{
+			Name: "state_reason",
+			Type: schema.FieldTypeText,
+		})
+		conversationsCol.Schema.AddField(&schema.SchemaField{
+			Name: "frozen_at",
+			Type: schema.FieldTypeDate,
+		})
+		conversationsCol.Schema.AddField(&schema.SchemaField{
+			Name: "archived_at",
+			Type: schema.FieldTypeDate,
+		})
+		conversationsCol.Schema.AddField(&schema.SchemaField{
+			Name:    "removed_members",
+			Type:    schema.FieldTypeJson,
+			Options: &schema.JsonOptions{MaxSize: 2 << 20},
+		})
+
+		if err := dao.SaveCollection(conversationsCol); err != nil {
+			return err
+		}
+
+		_, err = db.NewQuery("UPDATE conversations SET state = 'active' WHERE state = '' OR state IS NULL").Execute()
+		return err
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		conversationsCol, err := dao.FindCollectionByNameOrId("conversations")
+		if err != nil {
+			return err
+		}
+
+		for _, name := range []string{"state", "state_reason", "frozen_at", "archived_at", "removed_members"} {
+			if field := conversationsCol.Schema.GetFieldByName(name); field != nil {
+				conversationsCol.Schema.RemoveField(field.Id)
+			}
+		}
+
+		return dao.SaveCollection(conversationsCol)
+	})
+}
@@ -0,0 +1,79 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+// Conversations used to share one "project_<id>" channel per project. This
+// migration keeps the old channel id around and queues a
+// "migrate_conversation_channel" outbox job per conversation so the worker
+// can move it to its own "proposal_<id>" channel.
+func init() {
+	migrations.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		conversationsCol, err := dao.FindCollectionByNameOrId("conversations")
+		if err != nil {
+			return err
+		}
+
+		conversationsCol.Schema.AddField(&schema.SchemaField{
+			Name: "legacy_stream_channel_id",
+			Type: schema.FieldTypeText,
+		})
+
+		if err := dao.SaveCollection(conversationsCol); err != nil {
+			return err
+		}
+
+		conversations, err := dao.FindRecordsByExpr(
+			"conversations",
+			dbx.Like("stream_channel_id", "project_").Match(false, true),
+			dbx.HashExp{"is_deleted": false},
+		)
+		if err != nil {
+			return err
+		}
+
+		jobsCol, err := dao.FindCollectionByNameOrId("outbox_jobs")
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, conversation := range conversations {
+			job := models.NewRecord(jobsCol)
+			job.Set("type", "migrate_conversation_channel")
+			job.Set("reference_id", conversation.Id)
+			job.Set("status", "pending")
+			job.Set("attempts", 0)
+			job.Set("next_attempt_at", now)
+			job.Set("is_deleted", false)
+
+			if err := dao.SaveRecord(job); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		conversationsCol, err := dao.FindCollectionByNameOrId("conversations")
+		if err != nil {
+			return err
+		}
+
+		if field := conversationsCol.Schema.GetFieldByName("legacy_stream_channel_id"); field != nil {
+			conversationsCol.Schema.RemoveField(field.Id)
+		}
+
+		return dao.SaveCollection(conversationsCol)
+	})
+}
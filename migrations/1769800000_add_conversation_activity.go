@@ -0,0 +1,61 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+const conversationsStreamChannelIndex = "CREATE INDEX idx_conversations_stream_channel_id ON conversations (stream_channel_id)"
+
+func init() {
+	migrations.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		conversationsCol, err := dao.FindCollectionByNameOrId("conversations")
+		if err != nil {
+			return err
+		}
+
+		conversationsCol.Schema.AddField(&schema.SchemaField{
+			Name: "last_message_at",
+			Type: schema.FieldTypeDate,
+		})
+		conversationsCol.Schema.AddField(&schema.SchemaField{
+			Name: "last_message_preview",
+			Type: schema.FieldTypeText,
+		})
+		conversationsCol.Schema.AddField(&schema.SchemaField{
+			Name:    "unread_counts",
+			Type:    schema.FieldTypeJson,
+			Options: &schema.JsonOptions{MaxSize: 2 << 20},
+		})
+		conversationsCol.Indexes = append(conversationsCol.Indexes, conversationsStreamChannelIndex)
+
+		return dao.SaveCollection(conversationsCol)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		conversationsCol, err := dao.FindCollectionByNameOrId("conversations")
+		if err != nil {
+			return err
+		}
+
+		for _, name := range []string{"last_message_at", "last_message_preview", "unread_counts"} {
+			if field := conversationsCol.Schema.GetFieldByName(name); field != nil {
+				conversationsCol.Schema.RemoveField(field.Id)
+			}
+		}
+
+		indexes := conversationsCol.Indexes[:0]
+		for _, index := range conversationsCol.Indexes {
+			if index != conversationsStreamChannelIndex {
+				indexes = append(indexes, index)
+			}
+		}
+		conversationsCol.Indexes = indexes
+
+		return dao.SaveCollection(conversationsCol)
+	})
+}
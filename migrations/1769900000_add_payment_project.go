@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+// Payments did not store their project, which is needed to find the
+// conversation to post payment updates into. Older payments keep an empty
+// project_id and fall back to the client/freelancer pair.
+func init() {
+	migrations.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		paymentsCol, err := dao.FindCollectionByNameOrId("payments")
+		if err != nil {
+			return err
+		}
+
+		projectsCol, err := dao.FindCollectionByNameOrId("projects")
+		if err != nil {
+			return err
+		}
+
+		paymentsCol.Schema.AddField(&schema.SchemaField{
+			Name: "project_id",
+			Type: schema.FieldTypeRelation,
+			Options: &schema.RelationOptions{
+				CollectionId: projectsCol.Id,
+				MaxSelect:    &maxSelectOption,
+			},
+		})
+
+		return dao.SaveCollection(paymentsCol)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		paymentsCol, err := dao.FindCollectionByNameOrId("payments")
+		if err != nil {
+			return err
+		}
+
+		if field := paymentsCol.Schema.GetFieldByName("project_id"); field != nil {
+			paymentsCol.Schema.RemoveField(field.Id)
+		}
+
+		return dao.SaveCollection(paymentsCol)
+	})
+}
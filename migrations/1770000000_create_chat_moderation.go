@@ -0,0 +1,180 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+const (
+	usersUpdateRuleBeforeChatBan = usersUpdateRuleWithReview
+	// users must not be able to lift their own chat ban
+	usersUpdateRuleWithChatBan = usersUpdateRuleBeforeChatBan +
+		" && @request.data.chat_ban:isset = false" +
+		" && @request.data.chat_ban_reason:isset = false" +
+		" && @request.data.chat_ban_expires_at:isset = false"
+)
+
+func init() {
+	migrations.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		usersCol, err := dao.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+
+		usersCol.Schema.AddField(&schema.SchemaField{
+			Name: "chat_ban",
+			Type: schema.FieldTypeSelect,
+			Options: &schema.SelectOptions{
+				Values:    []string{"banned", "shadow_banned"},
+				MaxSelect: maxSelectOption,
+			},
+		})
+		usersCol.Schema.AddField(&schema.SchemaField{
+			Name: "chat_ban_reason",
+			Type: schema.FieldTypeText,
+		})
+		usersCol.Schema.AddField(&schema.SchemaField{
+			Name: "chat_ban_expires_at",
+			Type: schema.FieldTypeDate,
+		})
+		usersCol.UpdateRule = strPtr(usersUpdateRuleWithChatBan)
+
+		if err := dao.SaveCollection(usersCol); err != nil {
+			return err
+		}
+
+		conversationsCol, err := dao.FindCollectionByNameOrId("conversations")
+		if err != nil {
+			return err
+		}
+
+		// -----------------------------
+		// CHAT REPORTS (admin only)
+		// -----------------------------
+		reports := &models.Collection{
+			Name:   "chat_reports",
+			Type:   models.CollectionTypeBase,
+			System: false,
+			Indexes: []string{
+				"CREATE UNIQUE INDEX idx_chat_reports_reporter_message ON chat_reports (reporter_id, message_id)",
+				"CREATE INDEX idx_chat_reports_status ON chat_reports (status)",
+			},
+			Schema: schema.NewSchema(
+				&schema.SchemaField{
+					Name:     "reporter_id",
+					Type:     schema.FieldTypeRelation,
+					Required: true,
+					Options: &schema.RelationOptions{
+						CollectionId: usersCol.Id,
+						MaxSelect:    &maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name:     "reported_user_id",
+					Type:     schema.FieldTypeRelation,
+					Required: true,
+					Options: &schema.RelationOptions{
+						CollectionId: usersCol.Id,
+						MaxSelect:    &maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name:     "conversation_id",
+					Type:     schema.FieldTypeRelation,
+					Required: true,
+					Options: &schema.RelationOptions{
+						CollectionId: conversationsCol.Id,
+						MaxSelect:    &maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name:     "stream_channel_id",
+					Type:     schema.FieldTypeText,
+					Required: true,
+				},
+				&schema.SchemaField{
+					Name:     "message_id",
+					Type:     schema.FieldTypeText,
+					Required: true,
+				},
+				&schema.SchemaField{
+					Name:     "reason",
+					Type:     schema.FieldTypeSelect,
+					Required: true,
+					Options: &schema.SelectOptions{
+						Values:    []string{"spam", "harassment", "fraud", "inappropriate", "other"},
+						MaxSelect: maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name: "details",
+					Type: schema.FieldTypeText,
+				},
+				&schema.SchemaField{
+					Name:     "status",
+					Type:     schema.FieldTypeSelect,
+					Required: true,
+					Options: &schema.SelectOptions{
+						Values:    []string{"open", "actioned", "dismissed"},
+						MaxSelect: maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name: "action",
+					Type: schema.FieldTypeSelect,
+					Options: &schema.SelectOptions{
+						Values:    []string{"dismiss", "delete_message", "ban", "shadow_ban"},
+						MaxSelect: maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name: "admin_id",
+					Type: schema.FieldTypeText,
+				},
+				&schema.SchemaField{
+					Name: "resolution_note",
+					Type: schema.FieldTypeText,
+				},
+				&schema.SchemaField{
+					Name: "resolved_at",
+					Type: schema.FieldTypeDate,
+				},
+				&schema.SchemaField{
+					Name: "is_deleted",
+					Type: schema.FieldTypeBool,
+				},
+			),
+		}
+
+		return dao.SaveCollection(reports)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		reportsCol, err := dao.FindCollectionByNameOrId("chat_reports")
+		if err != nil {
+			return err
+		}
+		if err := dao.DeleteCollection(reportsCol); err != nil {
+			return err
+		}
+
+		usersCol, err := dao.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+
+		for _, name := range []string{"chat_ban", "chat_ban_reason", "chat_ban_expires_at"} {
+			if field := usersCol.Schema.GetFieldByName(name); field != nil {
+				usersCol.Schema.RemoveField(field.Id)
+			}
+		}
+		usersCol.UpdateRule = strPtr(usersUpdateRuleBeforeChatBan)
+
+		return dao.SaveCollection(usersCol)
+	})
+}
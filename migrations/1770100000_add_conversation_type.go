@@ -0,0 +1,80 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func init() {
+	migrations.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		usersCol, err := dao.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+		conversationsCol, err := dao.FindCollectionByNameOrId("conversations")
+		if err != nil {
+			return err
+		}
+
+		conversationsCol.Schema.AddField(&schema.SchemaField{
+			Name: "type",
+			Type: schema.FieldTypeSelect,
+			Options: &schema.SelectOptions{
+				Values:    []string{"proposal", "inquiry"},
+				MaxSelect: maxSelectOption,
+			},
+		})
+		// inquiry only: a member becomes read-only after too many messages
+		// the other member has not answered yet
+		conversationsCol.Schema.AddField(&schema.SchemaField{
+			Name: "unanswered_by",
+			Type: schema.FieldTypeRelation,
+			Options: &schema.RelationOptions{
+				CollectionId: usersCol.Id,
+				MaxSelect:    &maxSelectOption,
+			},
+		})
+		conversationsCol.Schema.AddField(&schema.SchemaField{
+			Name: "unanswered_count",
+			Type: schema.FieldTypeNumber,
+		})
+		conversationsCol.Schema.AddField(&schema.SchemaField{
+			Name: "read_only_user_id",
+			Type: schema.FieldTypeRelation,
+			Options: &schema.RelationOptions{
+				CollectionId: usersCol.Id,
+				MaxSelect:    &maxSelectOption,
+			},
+		})
+		conversationsCol.Schema.AddField(&schema.SchemaField{
+			Name: "replied_at",
+			Type: schema.FieldTypeDate,
+		})
+
+		if err := dao.SaveCollection(conversationsCol); err != nil {
+			return err
+		}
+
+		_, err = db.NewQuery("UPDATE conversations SET type = 'proposal' WHERE type = '' OR type IS NULL").Execute()
+		return err
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		conversationsCol, err := dao.FindCollectionByNameOrId("conversations")
+		if err != nil {
+			return err
+		}
+
+		for _, name := range []string{"type", "unanswered_by", "unanswered_count", "read_only_user_id", "replied_at"} {
+			if field := conversationsCol.Schema.GetFieldByName(name); field != nil {
+				conversationsCol.Schema.RemoveField(field.Id)
+			}
+		}
+
+		return dao.SaveCollection(conversationsCol)
+	})
+}
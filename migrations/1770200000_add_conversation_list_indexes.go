@@ -0,0 +1,70 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+)
+
+// conversationListIndexes back the joined /chat/conversations query, which
+// starts from the proposals of a user and joins their conversations.
+var conversationListIndexes = map[string][]string{
+	"conversations": {
+		"CREATE INDEX idx_conversations_proposal_id ON conversations (proposal_id)",
+	},
+	"proposals": {
+		"CREATE INDEX idx_proposals_client_id ON proposals (client_id)",
+		"CREATE INDEX idx_proposals_freelancer_id ON proposals (freelancer_id)",
+	},
+}
+
+func init() {
+	migrations.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		for name, indexes := range conversationListIndexes {
+			collection, err := dao.FindCollectionByNameOrId(name)
+			if err != nil {
+				return err
+			}
+
+			collection.Indexes = append(collection.Indexes, indexes...)
+
+			if err := dao.SaveCollection(collection); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		for name, added := range conversationListIndexes {
+			collection, err := dao.FindCollectionByNameOrId(name)
+			if err != nil {
+				return err
+			}
+
+			indexes := collection.Indexes[:0]
+			for _, index := range collection.Indexes {
+				keep := true
+				for _, a := range added {
+					if index == a {
+						keep = false
+						break
+					}
+				}
+				if keep {
+					indexes = append(indexes, index)
+				}
+			}
+			collection.Indexes = indexes
+
+			if err := dao.SaveCollection(collection); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,150 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+const (
+	conversationFileParticipantRule = "(conversation_id.proposal_id.client_id = @request.auth.id || " +
+		"conversation_id.proposal_id.freelancer_id = @request.auth.id)"
+
+	// conversationFileMaxSize is the largest accepted upload (25 MB).
+	conversationFileMaxSize = 25 << 20
+)
+
+// conversationFileMimeTypes are the accepted deliverable formats.
+var conversationFileMimeTypes = []string{
+	"application/pdf",
+	"application/zip",
+	"application/msword",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"application/vnd.ms-excel",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"application/vnd.ms-powerpoint",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	"text/plain",
+	"text/csv",
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"image/svg+xml",
+	"video/mp4",
+}
+
+func init() {
+	migrations.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		usersCol, err := dao.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+		conversationsCol, err := dao.FindCollectionByNameOrId("conversations")
+		if err != nil {
+			return err
+		}
+
+		// -----------------------------
+		// CONVERSATION FILES
+		// -----------------------------
+		// Files only become visible to the other participant once the
+		// virus scan marked them clean; scan fields are set by the backend.
+		files := &models.Collection{
+			Name:   "conversation_files",
+			Type:   models.CollectionTypeBase,
+			System: false,
+			CreateRule: strPtr(
+				"@request.auth.id != '' && @request.auth.is_deleted = false && uploader_id = @request.auth.id && " +
+					"conversation_id.is_deleted = false && conversation_id.state = 'active' && " +
+					conversationFileParticipantRule + " && " +
+					"@request.data.scan_status:isset = false && @request.data.scan_result:isset = false && " +
+					"@request.data.scanned_at:isset = false && @request.data.message_id:isset = false",
+			),
+			ListRule: strPtr("is_deleted = false && @request.auth.id != '' && " + conversationFileParticipantRule +
+				" && (scan_status = 'clean' || uploader_id = @request.auth.id)"),
+			ViewRule: strPtr("is_deleted = false && @request.auth.id != '' && " + conversationFileParticipantRule +
+				" && (scan_status = 'clean' || uploader_id = @request.auth.id)"),
+			UpdateRule: nil,
+			DeleteRule: strPtr("false"),
+			Indexes: []string{
+				"CREATE INDEX idx_conversation_files_conversation ON conversation_files (conversation_id, created)",
+			},
+			Schema: schema.NewSchema(
+				&schema.SchemaField{
+					Name:     "conversation_id",
+					Type:     schema.FieldTypeRelation,
+					Required: true,
+					Options: &schema.RelationOptions{
+						CollectionId: conversationsCol.Id,
+						MaxSelect:    &maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name:     "uploader_id",
+					Type:     schema.FieldTypeRelation,
+					Required: true,
+					Options: &schema.RelationOptions{
+						CollectionId: usersCol.Id,
+						MaxSelect:    &maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name:     "file",
+					Type:     schema.FieldTypeFile,
+					Required: true,
+					Options: &schema.FileOptions{
+						MaxSelect: 1,
+						MaxSize:   conversationFileMaxSize,
+						MimeTypes: conversationFileMimeTypes,
+						Protected: true,
+					},
+				},
+				&schema.SchemaField{
+					Name: "name",
+					Type: schema.FieldTypeText,
+				},
+				// set to pending by the backend when the file is created
+				&schema.SchemaField{
+					Name: "scan_status",
+					Type: schema.FieldTypeSelect,
+					Options: &schema.SelectOptions{
+						Values:    []string{"pending", "clean", "infected"},
+						MaxSelect: maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name: "scan_result",
+					Type: schema.FieldTypeText,
+				},
+				&schema.SchemaField{
+					Name: "scanned_at",
+					Type: schema.FieldTypeDate,
+				},
+				&schema.SchemaField{
+					Name: "message_id",
+					Type: schema.FieldTypeText,
+				},
+				&schema.SchemaField{
+					Name: "is_deleted",
+					Type: schema.FieldTypeBool,
+				},
+			),
+		}
+
+		return dao.SaveCollection(files)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		filesCol, err := dao.FindCollectionByNameOrId("conversation_files")
+		if err != nil {
+			return err
+		}
+
+		return dao.DeleteCollection(filesCol)
+	})
+}
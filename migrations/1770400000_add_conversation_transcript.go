@@ -0,0 +1,67 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+// conversationTranscriptMaxSize is the largest stored transcript (50 MB).
+const conversationTranscriptMaxSize = 50 << 20
+
+func init() {
+	migrations.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		conversationsCol, err := dao.FindCollectionByNameOrId("conversations")
+		if err != nil {
+			return err
+		}
+
+		// the latest stored export, kept as evidence for disputes
+		conversationsCol.Schema.AddField(&schema.SchemaField{
+			Name: "transcript_json",
+			Type: schema.FieldTypeFile,
+			Options: &schema.FileOptions{
+				MaxSelect: 1,
+				MaxSize:   conversationTranscriptMaxSize,
+				Protected: true,
+			},
+		})
+		conversationsCol.Schema.AddField(&schema.SchemaField{
+			Name: "transcript_pdf",
+			Type: schema.FieldTypeFile,
+			Options: &schema.FileOptions{
+				MaxSelect: 1,
+				MaxSize:   conversationTranscriptMaxSize,
+				Protected: true,
+			},
+		})
+		conversationsCol.Schema.AddField(&schema.SchemaField{
+			Name: "transcript_sha256",
+			Type: schema.FieldTypeText,
+		})
+		conversationsCol.Schema.AddField(&schema.SchemaField{
+			Name: "transcript_exported_at",
+			Type: schema.FieldTypeDate,
+		})
+
+		return dao.SaveCollection(conversationsCol)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		conversationsCol, err := dao.FindCollectionByNameOrId("conversations")
+		if err != nil {
+			return err
+		}
+
+		for _, name := range []string{"transcript_json", "transcript_pdf", "transcript_sha256", "transcript_exported_at"} {
+			if field := conversationsCol.Schema.GetFieldByName(name); field != nil {
+				conversationsCol.Schema.RemoveField(field.Id)
+			}
+		}
+
+		return dao.SaveCollection(conversationsCol)
+	})
+}
@@ -0,0 +1,201 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+const (
+	proposalsUpdateRuleBeforeBidding = "is_deleted = false && " +
+		"((@request.auth.role = 'freelancer' && freelancer_id = @request.auth.id && status = 'sent') || " +
+		"(@request.auth.role = 'client' && client_id = @request.auth.id))"
+	// the revision counter is kept by the backend and only the freelancer
+	// may change the terms of a proposal
+	proposalsUpdateRuleWithBidding = "is_deleted = false && " +
+		"@request.data.revision:isset = false && @request.data.revised_at:isset = false && " +
+		"((@request.auth.role = 'freelancer' && freelancer_id = @request.auth.id && status = 'sent') || " +
+		"(@request.auth.role = 'client' && client_id = @request.auth.id && " +
+		"@request.data.message:isset = false && @request.data.bid_amount:isset = false && " +
+		"@request.data.currency:isset = false && @request.data.estimated_duration:isset = false && " +
+		"@request.data.attachments:isset = false))"
+
+	proposalRevisionParticipantRule = "(proposal_id.client_id = @request.auth.id || " +
+		"proposal_id.freelancer_id = @request.auth.id)"
+
+	// proposalAttachmentMaxSize is the largest accepted attachment (10 MB).
+	proposalAttachmentMaxSize  = 10 << 20
+	proposalAttachmentMaxCount = 5
+)
+
+var proposalBiddingIndexes = []string{
+	"CREATE INDEX idx_proposals_project_bid ON proposals (project_id, bid_amount)",
+	"CREATE INDEX idx_proposals_project_created ON proposals (project_id, created)",
+}
+
+// proposalTermsFields are shared by proposals and their revisions.
+func proposalTermsFields() []*schema.SchemaField {
+	zero := 0.0
+
+	return []*schema.SchemaField{
+		// in the smallest currency unit, like payments
+		{
+			Name: "bid_amount",
+			Type: schema.FieldTypeNumber,
+			Options: &schema.NumberOptions{
+				Min:       &zero,
+				NoDecimal: true,
+			},
+		},
+		{
+			Name: "currency",
+			Type: schema.FieldTypeText,
+			Options: &schema.TextOptions{
+				Pattern: "^[A-Za-z]{3}$",
+			},
+		},
+		// in days
+		{
+			Name: "estimated_duration",
+			Type: schema.FieldTypeNumber,
+			Options: &schema.NumberOptions{
+				Min:       &zero,
+				NoDecimal: true,
+			},
+		},
+		{
+			Name: "attachments",
+			Type: schema.FieldTypeFile,
+			Options: &schema.FileOptions{
+				MaxSelect: proposalAttachmentMaxCount,
+				MaxSize:   proposalAttachmentMaxSize,
+				MimeTypes: conversationFileMimeTypes,
+				Protected: true,
+			},
+		},
+	}
+}
+
+func init() {
+	migrations.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		proposalsCol, err := dao.FindCollectionByNameOrId("proposals")
+		if err != nil {
+			return err
+		}
+
+		for _, field := range proposalTermsFields() {
+			proposalsCol.Schema.AddField(field)
+		}
+		// set by the backend, starts at 1 and grows with every edit
+		proposalsCol.Schema.AddField(&schema.SchemaField{
+			Name:    "revision",
+			Type:    schema.FieldTypeNumber,
+			Options: &schema.NumberOptions{NoDecimal: true},
+		})
+		proposalsCol.Schema.AddField(&schema.SchemaField{
+			Name: "revised_at",
+			Type: schema.FieldTypeDate,
+		})
+		proposalsCol.UpdateRule = strPtr(proposalsUpdateRuleWithBidding)
+		proposalsCol.Indexes = append(proposalsCol.Indexes, proposalBiddingIndexes...)
+
+		if err := dao.SaveCollection(proposalsCol); err != nil {
+			return err
+		}
+
+		if _, err := db.NewQuery("UPDATE proposals SET revision = 1").Execute(); err != nil {
+			return err
+		}
+
+		// -----------------------------
+		// PROPOSAL REVISIONS
+		// -----------------------------
+		// Previous versions of a proposal, written by the backend whenever
+		// the freelancer changes its terms.
+		revisionFields := []*schema.SchemaField{
+			{
+				Name:     "proposal_id",
+				Type:     schema.FieldTypeRelation,
+				Required: true,
+				Options: &schema.RelationOptions{
+					CollectionId: proposalsCol.Id,
+					MaxSelect:    &maxSelectOption,
+				},
+			},
+			{
+				Name:    "revision",
+				Type:    schema.FieldTypeNumber,
+				Options: &schema.NumberOptions{NoDecimal: true},
+			},
+			{
+				Name: "message",
+				Type: schema.FieldTypeText,
+			},
+		}
+		revisionFields = append(revisionFields, proposalTermsFields()...)
+		revisionFields = append(revisionFields, &schema.SchemaField{
+			Name: "is_deleted",
+			Type: schema.FieldTypeBool,
+		})
+
+		revisions := &models.Collection{
+			Name:       "proposal_revisions",
+			Type:       models.CollectionTypeBase,
+			System:     false,
+			CreateRule: strPtr("false"),
+			ListRule:   strPtr("is_deleted = false && @request.auth.id != '' && " + proposalRevisionParticipantRule),
+			ViewRule:   strPtr("is_deleted = false && @request.auth.id != '' && " + proposalRevisionParticipantRule),
+			UpdateRule: strPtr("false"),
+			DeleteRule: strPtr("false"),
+			Indexes: []string{
+				"CREATE UNIQUE INDEX idx_proposal_revisions_proposal_revision ON proposal_revisions (proposal_id, revision)",
+			},
+			Schema: schema.NewSchema(revisionFields...),
+		}
+
+		return dao.SaveCollection(revisions)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		revisionsCol, err := dao.FindCollectionByNameOrId("proposal_revisions")
+		if err != nil {
+			return err
+		}
+		if err := dao.DeleteCollection(revisionsCol); err != nil {
+			return err
+		}
+
+		proposalsCol, err := dao.FindCollectionByNameOrId("proposals")
+		if err != nil {
+			return err
+		}
+
+		for _, name := range []string{"bid_amount", "currency", "estimated_duration", "attachments", "revision", "revised_at"} {
+			if field := proposalsCol.Schema.GetFieldByName(name); field != nil {
+				proposalsCol.Schema.RemoveField(field.Id)
+			}
+		}
+		proposalsCol.UpdateRule = strPtr(proposalsUpdateRuleBeforeBidding)
+
+		indexes := proposalsCol.Indexes[:0]
+		for _, index := range proposalsCol.Indexes {
+			keep := true
+			for _, added := range proposalBiddingIndexes {
+				if index == added {
+					keep = false
+					break
+				}
+			}
+			if keep {
+				indexes = append(indexes, index)
+			}
+		}
+		proposalsCol.Indexes = indexes
+
+		return dao.SaveCollection(proposalsCol)
+	})
+}
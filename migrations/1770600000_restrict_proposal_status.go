@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+)
+
+const (
+	proposalsCreateRuleBeforeStatusEndpoints = "@request.auth.role = 'freelancer' && @request.auth.is_deleted = false && " +
+		"@request.data.project_id.status = 'open' && @request.data.project_id.is_deleted = false"
+	// new proposals always start as sent, are sent by the freelancer
+	// themselves to the project's client; every later status change goes
+	// through the /proposals/:id endpoints
+	proposalsCreateRuleWithStatusEndpoints = proposalsCreateRuleBeforeStatusEndpoints +
+		" && @request.data.status = 'sent' && @request.data.freelancer_id = @request.auth.id && " +
+		"@request.data.client_id = @request.data.project_id.client_id"
+	// the parties and the project are fixed at creation, like the status
+	proposalsUpdateRuleWithStatusEndpoints = "@request.data.status:isset = false && " +
+		"@request.data.project_id:isset = false && @request.data.client_id:isset = false && " +
+		"@request.data.freelancer_id:isset = false && " +
+		proposalsUpdateRuleWithBidding
+)
+
+func init() {
+	migrations.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		proposalsCol, err := dao.FindCollectionByNameOrId("proposals")
+		if err != nil {
+			return err
+		}
+
+		proposalsCol.CreateRule = strPtr(proposalsCreateRuleWithStatusEndpoints)
+		proposalsCol.UpdateRule = strPtr(proposalsUpdateRuleWithStatusEndpoints)
+
+		return dao.SaveCollection(proposalsCol)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		proposalsCol, err := dao.FindCollectionByNameOrId("proposals")
+		if err != nil {
+			return err
+		}
+
+		proposalsCol.CreateRule = strPtr(proposalsCreateRuleBeforeStatusEndpoints)
+		proposalsCol.UpdateRule = strPtr(proposalsUpdateRuleWithBidding)
+
+		return dao.SaveCollection(proposalsCol)
+	})
+}
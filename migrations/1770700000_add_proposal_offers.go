@@ -0,0 +1,195 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+const (
+	// the agreed terms are only written when a proposal is accepted
+	proposalsUpdateRuleWithOffers = "@request.data.agreed_amount:isset = false && " +
+		"@request.data.agreed_currency:isset = false && @request.data.agreed_duration:isset = false && " +
+		"@request.data.accepted_offer_id:isset = false && " +
+		proposalsUpdateRuleWithStatusEndpoints
+
+	proposalOfferParticipantRule = "(proposal_id.client_id = @request.auth.id || " +
+		"proposal_id.freelancer_id = @request.auth.id)"
+)
+
+var (
+	proposalStatusesBeforeOffers = []string{"sent", "accepted", "rejected"}
+	proposalStatusesWithOffers   = []string{"sent", "countered", "accepted", "rejected", "withdrawn"}
+)
+
+func init() {
+	migrations.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		usersCol, err := dao.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+		proposalsCol, err := dao.FindCollectionByNameOrId("proposals")
+		if err != nil {
+			return err
+		}
+
+		// -----------------------------
+		// PROPOSAL OFFERS
+		// -----------------------------
+		// Counter-offers on a proposal, written by the /proposals/:id/offers
+		// endpoint. Only the latest offer is open; accepting it makes its
+		// terms the agreed terms of the proposal.
+		zero := 0.0
+		offers := &models.Collection{
+			Name:       "proposal_offers",
+			Type:       models.CollectionTypeBase,
+			System:     false,
+			CreateRule: strPtr("false"),
+			ListRule:   strPtr("is_deleted = false && @request.auth.id != '' && " + proposalOfferParticipantRule),
+			ViewRule:   strPtr("is_deleted = false && @request.auth.id != '' && " + proposalOfferParticipantRule),
+			UpdateRule: strPtr("false"),
+			DeleteRule: strPtr("false"),
+			Indexes: []string{
+				"CREATE INDEX idx_proposal_offers_proposal ON proposal_offers (proposal_id, created)",
+			},
+			Schema: schema.NewSchema(
+				&schema.SchemaField{
+					Name:     "proposal_id",
+					Type:     schema.FieldTypeRelation,
+					Required: true,
+					Options: &schema.RelationOptions{
+						CollectionId: proposalsCol.Id,
+						MaxSelect:    &maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name:     "author_id",
+					Type:     schema.FieldTypeRelation,
+					Required: true,
+					Options: &schema.RelationOptions{
+						CollectionId: usersCol.Id,
+						MaxSelect:    &maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name:     "author_role",
+					Type:     schema.FieldTypeSelect,
+					Required: true,
+					Options: &schema.SelectOptions{
+						Values:    []string{"client", "freelancer"},
+						MaxSelect: maxSelectOption,
+					},
+				},
+				// in the smallest currency unit, like payments
+				&schema.SchemaField{
+					Name:     "amount",
+					Type:     schema.FieldTypeNumber,
+					Required: true,
+					Options: &schema.NumberOptions{
+						Min:       &zero,
+						NoDecimal: true,
+					},
+				},
+				&schema.SchemaField{
+					Name:     "currency",
+					Type:     schema.FieldTypeText,
+					Required: true,
+					Options: &schema.TextOptions{
+						Pattern: "^[a-z]{3}$",
+					},
+				},
+				// in days
+				&schema.SchemaField{
+					Name: "estimated_duration",
+					Type: schema.FieldTypeNumber,
+					Options: &schema.NumberOptions{
+						Min:       &zero,
+						NoDecimal: true,
+					},
+				},
+				&schema.SchemaField{
+					Name: "terms",
+					Type: schema.FieldTypeText,
+				},
+				&schema.SchemaField{
+					Name:     "status",
+					Type:     schema.FieldTypeSelect,
+					Required: true,
+					Options: &schema.SelectOptions{
+						Values:    []string{"open", "superseded", "accepted", "declined"},
+						MaxSelect: maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name: "is_deleted",
+					Type: schema.FieldTypeBool,
+				},
+			),
+		}
+
+		if err := dao.SaveCollection(offers); err != nil {
+			return err
+		}
+
+		proposalsCol.Schema.GetFieldByName("status").Options.(*schema.SelectOptions).Values = proposalStatusesWithOffers
+		proposalsCol.Schema.AddField(&schema.SchemaField{
+			Name: "agreed_amount",
+			Type: schema.FieldTypeNumber,
+			Options: &schema.NumberOptions{
+				NoDecimal: true,
+			},
+		})
+		proposalsCol.Schema.AddField(&schema.SchemaField{
+			Name: "agreed_currency",
+			Type: schema.FieldTypeText,
+		})
+		proposalsCol.Schema.AddField(&schema.SchemaField{
+			Name: "agreed_duration",
+			Type: schema.FieldTypeNumber,
+			Options: &schema.NumberOptions{
+				NoDecimal: true,
+			},
+		})
+		proposalsCol.Schema.AddField(&schema.SchemaField{
+			Name: "accepted_offer_id",
+			Type: schema.FieldTypeRelation,
+			Options: &schema.RelationOptions{
+				CollectionId: offers.Id,
+				MaxSelect:    &maxSelectOption,
+			},
+		})
+		proposalsCol.UpdateRule = strPtr(proposalsUpdateRuleWithOffers)
+
+		return dao.SaveCollection(proposalsCol)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		proposalsCol, err := dao.FindCollectionByNameOrId("proposals")
+		if err != nil {
+			return err
+		}
+
+		for _, name := range []string{"agreed_amount", "agreed_currency", "agreed_duration", "accepted_offer_id"} {
+			if field := proposalsCol.Schema.GetFieldByName(name); field != nil {
+				proposalsCol.Schema.RemoveField(field.Id)
+			}
+		}
+		proposalsCol.Schema.GetFieldByName("status").Options.(*schema.SelectOptions).Values = proposalStatusesBeforeOffers
+		proposalsCol.UpdateRule = strPtr(proposalsUpdateRuleWithStatusEndpoints)
+
+		if err := dao.SaveCollection(proposalsCol); err != nil {
+			return err
+		}
+
+		offersCol, err := dao.FindCollectionByNameOrId("proposal_offers")
+		if err != nil {
+			return err
+		}
+
+		return dao.DeleteCollection(offersCol)
+	})
+}
@@ -0,0 +1,205 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+const (
+	projectsViewRuleBeforeInvitations = "is_deleted = false && @request.auth.id != '' && " +
+		"((@request.auth.role = 'client' && client_id = @request.auth.id) || " +
+		"(@request.auth.role = 'freelancer' && status = 'open'))"
+
+	// the aliased join keeps all conditions on the same invitation row
+	projectInviteeRule = "(@collection.project_invitations:invitation.project_id ?= id && " +
+		"@collection.project_invitations:invitation.freelancer_id ?= @request.auth.id && " +
+		"@collection.project_invitations:invitation.status ?!= 'declined' && " +
+		"@collection.project_invitations:invitation.is_deleted ?= false)"
+
+	// invitees see the project whatever its status; invite-only projects
+	// are hidden from everybody else
+	projectsViewRuleWithInvitations = "is_deleted = false && @request.auth.id != '' && " +
+		"((@request.auth.role = 'client' && client_id = @request.auth.id) || " +
+		"(@request.auth.role = 'freelancer' && ((status = 'open' && visibility != 'invite_only') || " +
+		projectInviteeRule + ")))"
+
+	// only freelancers with an accepted invitation can propose on an
+	// invite-only project
+	proposalsCreateRuleWithInvitations = proposalsCreateRuleWithStatusEndpoints +
+		" && (@request.data.project_id.visibility != 'invite_only' || " +
+		"(@collection.project_invitations:invitation.project_id ?= @request.data.project_id && " +
+		"@collection.project_invitations:invitation.freelancer_id ?= @request.auth.id && " +
+		"@collection.project_invitations:invitation.status ?= 'accepted' && " +
+		"@collection.project_invitations:invitation.is_deleted ?= false))"
+
+	projectInvitationParticipantRule = "(client_id = @request.auth.id || freelancer_id = @request.auth.id)"
+)
+
+func init() {
+	migrations.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		usersCol, err := dao.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+		projectsCol, err := dao.FindCollectionByNameOrId("projects")
+		if err != nil {
+			return err
+		}
+
+		// -----------------------------
+		// PROJECT INVITATIONS
+		// -----------------------------
+		// Clients invite freelancers to a project; the freelancer accepts
+		// or declines. status and responded_at are set by the backend.
+		invitations := &models.Collection{
+			Name:   "project_invitations",
+			Type:   models.CollectionTypeBase,
+			System: false,
+			CreateRule: strPtr(
+				"@request.auth.role = 'client' && @request.auth.is_deleted = false && " +
+					"@request.data.client_id = @request.auth.id && " +
+					"@request.data.project_id.client_id = @request.auth.id && " +
+					"@request.data.project_id.is_deleted = false && @request.data.project_id.status != 'closed' && " +
+					"@request.data.freelancer_id.role = 'freelancer' && @request.data.freelancer_id.is_deleted = false && " +
+					"@request.data.status:isset = false && @request.data.responded_at:isset = false",
+			),
+			ListRule: strPtr("is_deleted = false && @request.auth.id != '' && " + projectInvitationParticipantRule),
+			ViewRule: strPtr("is_deleted = false && @request.auth.id != '' && " + projectInvitationParticipantRule),
+			// the freelancer answers a pending invitation once; the client
+			// may edit the message or withdraw it by soft-deleting it
+			UpdateRule: strPtr(
+				"is_deleted = false && " +
+					"@request.data.project_id:isset = false && @request.data.client_id:isset = false && " +
+					"@request.data.freelancer_id:isset = false && @request.data.responded_at:isset = false && " +
+					"((freelancer_id = @request.auth.id && status = 'pending' && " +
+					"@request.data.message:isset = false && @request.data.is_deleted:isset = false && " +
+					"(@request.data.status = 'accepted' || @request.data.status = 'declined')) || " +
+					"(client_id = @request.auth.id && @request.data.status:isset = false))",
+			),
+			DeleteRule: strPtr("false"),
+			Indexes: []string{
+				"CREATE UNIQUE INDEX idx_project_invitations_project_freelancer ON project_invitations (project_id, freelancer_id)",
+				"CREATE INDEX idx_project_invitations_freelancer ON project_invitations (freelancer_id, status)",
+			},
+			Schema: schema.NewSchema(
+				&schema.SchemaField{
+					Name:     "project_id",
+					Type:     schema.FieldTypeRelation,
+					Required: true,
+					Options: &schema.RelationOptions{
+						CollectionId: projectsCol.Id,
+						MaxSelect:    &maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name:     "client_id",
+					Type:     schema.FieldTypeRelation,
+					Required: true,
+					Options: &schema.RelationOptions{
+						CollectionId: usersCol.Id,
+						MaxSelect:    &maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name:     "freelancer_id",
+					Type:     schema.FieldTypeRelation,
+					Required: true,
+					Options: &schema.RelationOptions{
+						CollectionId: usersCol.Id,
+						MaxSelect:    &maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name: "message",
+					Type: schema.FieldTypeText,
+				},
+				// set to pending by the backend when the invitation is created
+				&schema.SchemaField{
+					Name: "status",
+					Type: schema.FieldTypeSelect,
+					Options: &schema.SelectOptions{
+						Values:    []string{"pending", "accepted", "declined"},
+						MaxSelect: maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name: "responded_at",
+					Type: schema.FieldTypeDate,
+				},
+				&schema.SchemaField{
+					Name: "is_deleted",
+					Type: schema.FieldTypeBool,
+				},
+			),
+		}
+
+		if err := dao.SaveCollection(invitations); err != nil {
+			return err
+		}
+
+		// empty means public
+		projectsCol.Schema.AddField(&schema.SchemaField{
+			Name: "visibility",
+			Type: schema.FieldTypeSelect,
+			Options: &schema.SelectOptions{
+				Values:    []string{"public", "invite_only"},
+				MaxSelect: maxSelectOption,
+			},
+		})
+		projectsCol.ListRule = strPtr(projectsViewRuleWithInvitations)
+		projectsCol.ViewRule = strPtr(projectsViewRuleWithInvitations)
+
+		if err := dao.SaveCollection(projectsCol); err != nil {
+			return err
+		}
+
+		proposalsCol, err := dao.FindCollectionByNameOrId("proposals")
+		if err != nil {
+			return err
+		}
+
+		proposalsCol.CreateRule = strPtr(proposalsCreateRuleWithInvitations)
+
+		return dao.SaveCollection(proposalsCol)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		proposalsCol, err := dao.FindCollectionByNameOrId("proposals")
+		if err != nil {
+			return err
+		}
+
+		proposalsCol.CreateRule = strPtr(proposalsCreateRuleWithStatusEndpoints)
+
+		if err := dao.SaveCollection(proposalsCol); err != nil {
+			return err
+		}
+
+		projectsCol, err := dao.FindCollectionByNameOrId("projects")
+		if err != nil {
+			return err
+		}
+
+		if field := projectsCol.Schema.GetFieldByName("visibility"); field != nil {
+			projectsCol.Schema.RemoveField(field.Id)
+		}
+		projectsCol.ListRule = strPtr(projectsViewRuleBeforeInvitations)
+		projectsCol.ViewRule = strPtr(projectsViewRuleBeforeInvitations)
+
+		if err := dao.SaveCollection(projectsCol); err != nil {
+			return err
+		}
+
+		invitationsCol, err := dao.FindCollectionByNameOrId("project_invitations")
+		if err != nil {
+			return err
+		}
+
+		return dao.DeleteCollection(invitationsCol)
+	})
+}
@@ -0,0 +1,187 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+const (
+	projectSkillsMaxCount = 20
+
+	// projectAttachmentMaxSize is the largest accepted attachment (10 MB).
+	projectAttachmentMaxSize  = 10 << 20
+	projectAttachmentMaxCount = 10
+)
+
+var projectDetailFields = []string{
+	"budget_min", "budget_max", "currency", "pricing_model", "skills", "deadline", "location", "attachments",
+}
+
+var projectDetailIndexes = []string{
+	"CREATE INDEX idx_projects_status_deadline ON projects (status, deadline)",
+}
+
+func init() {
+	migrations.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		nameMax := 100
+		locationMax := 200
+
+		// -----------------------------
+		// SKILLS
+		// -----------------------------
+		// Managed by admins; projects reference them as required skills.
+		skills := &models.Collection{
+			Name:     "skills",
+			Type:     models.CollectionTypeBase,
+			System:   false,
+			ListRule: strPtr("is_deleted = false && @request.auth.id != ''"),
+			ViewRule: strPtr("is_deleted = false && @request.auth.id != ''"),
+			Indexes: []string{
+				"CREATE UNIQUE INDEX idx_skills_name ON skills (name COLLATE NOCASE)",
+			},
+			Schema: schema.NewSchema(
+				&schema.SchemaField{
+					Name:     "name",
+					Type:     schema.FieldTypeText,
+					Required: true,
+					Options: &schema.TextOptions{
+						Max: &nameMax,
+					},
+				},
+				&schema.SchemaField{
+					Name: "is_deleted",
+					Type: schema.FieldTypeBool,
+				},
+			),
+		}
+
+		if err := dao.SaveCollection(skills); err != nil {
+			return err
+		}
+
+		projectsCol, err := dao.FindCollectionByNameOrId("projects")
+		if err != nil {
+			return err
+		}
+
+		zero := 0.0
+		skillsMax := projectSkillsMaxCount
+
+		// the budget is in the smallest currency unit, like payments; the
+		// backend checks the range and requires a location for onsite and
+		// hybrid projects
+		projectsCol.Schema.AddField(&schema.SchemaField{
+			Name: "budget_min",
+			Type: schema.FieldTypeNumber,
+			Options: &schema.NumberOptions{
+				Min:       &zero,
+				NoDecimal: true,
+			},
+		})
+		projectsCol.Schema.AddField(&schema.SchemaField{
+			Name: "budget_max",
+			Type: schema.FieldTypeNumber,
+			Options: &schema.NumberOptions{
+				Min:       &zero,
+				NoDecimal: true,
+			},
+		})
+		projectsCol.Schema.AddField(&schema.SchemaField{
+			Name: "currency",
+			Type: schema.FieldTypeText,
+			Options: &schema.TextOptions{
+				Pattern: "^[A-Za-z]{3}$",
+			},
+		})
+		projectsCol.Schema.AddField(&schema.SchemaField{
+			Name: "pricing_model",
+			Type: schema.FieldTypeSelect,
+			Options: &schema.SelectOptions{
+				Values:    []string{"fixed", "hourly"},
+				MaxSelect: maxSelectOption,
+			},
+		})
+		projectsCol.Schema.AddField(&schema.SchemaField{
+			Name: "skills",
+			Type: schema.FieldTypeRelation,
+			Options: &schema.RelationOptions{
+				CollectionId: skills.Id,
+				MaxSelect:    &skillsMax,
+			},
+		})
+		projectsCol.Schema.AddField(&schema.SchemaField{
+			Name: "deadline",
+			Type: schema.FieldTypeDate,
+		})
+		projectsCol.Schema.AddField(&schema.SchemaField{
+			Name: "location",
+			Type: schema.FieldTypeText,
+			Options: &schema.TextOptions{
+				Max: &locationMax,
+			},
+		})
+		projectsCol.Schema.AddField(&schema.SchemaField{
+			Name: "attachments",
+			Type: schema.FieldTypeFile,
+			Options: &schema.FileOptions{
+				MaxSelect: projectAttachmentMaxCount,
+				MaxSize:   projectAttachmentMaxSize,
+				MimeTypes: conversationFileMimeTypes,
+				Protected: true,
+			},
+		})
+		projectsCol.Indexes = append(projectsCol.Indexes, projectDetailIndexes...)
+
+		if err := dao.SaveCollection(projectsCol); err != nil {
+			return err
+		}
+
+		// existing projects were all fixed price
+		_, err = db.NewQuery("UPDATE projects SET pricing_model = 'fixed', currency = 'usd'").Execute()
+		return err
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		projectsCol, err := dao.FindCollectionByNameOrId("projects")
+		if err != nil {
+			return err
+		}
+
+		for _, name := range projectDetailFields {
+			if field := projectsCol.Schema.GetFieldByName(name); field != nil {
+				projectsCol.Schema.RemoveField(field.Id)
+			}
+		}
+
+		indexes := make([]string, 0, len(projectsCol.Indexes))
+		for _, index := range projectsCol.Indexes {
+			keep := true
+			for _, added := range projectDetailIndexes {
+				if index == added {
+					keep = false
+					break
+				}
+			}
+			if keep {
+				indexes = append(indexes, index)
+			}
+		}
+		projectsCol.Indexes = indexes
+
+		if err := dao.SaveCollection(projectsCol); err != nil {
+			return err
+		}
+
+		skillsCol, err := dao.FindCollectionByNameOrId("skills")
+		if err != nil {
+			return err
+		}
+
+		return dao.DeleteCollection(skillsCol)
+	})
+}
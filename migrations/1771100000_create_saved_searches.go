@@ -0,0 +1,214 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func init() {
+	migrations.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		usersCol, err := dao.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+		projectsCol, err := dao.FindCollectionByNameOrId("projects")
+		if err != nil {
+			return err
+		}
+		skillsCol, err := dao.FindCollectionByNameOrId("skills")
+		if err != nil {
+			return err
+		}
+
+		zero := 0.0
+		nameMax := 100
+		keywordsMax := 200
+		skillsMax := projectSkillsMaxCount
+
+		// -----------------------------
+		// SAVED SEARCHES
+		// -----------------------------
+		// Search criteria of a freelancer, matched by the backend against
+		// projects that are created or reopened. last_notified_at is set by
+		// the backend.
+		savedSearches := &models.Collection{
+			Name:   "saved_searches",
+			Type:   models.CollectionTypeBase,
+			System: false,
+			CreateRule: strPtr(
+				"@request.auth.role = 'freelancer' && @request.auth.is_deleted = false && " +
+					"@request.data.freelancer_id = @request.auth.id && @request.data.last_notified_at:isset = false",
+			),
+			ListRule: strPtr("is_deleted = false && freelancer_id = @request.auth.id"),
+			ViewRule: strPtr("is_deleted = false && freelancer_id = @request.auth.id"),
+			UpdateRule: strPtr(
+				"is_deleted = false && freelancer_id = @request.auth.id && " +
+					"@request.data.freelancer_id:isset = false && @request.data.last_notified_at:isset = false",
+			),
+			DeleteRule: strPtr("false"),
+			Indexes: []string{
+				"CREATE INDEX idx_saved_searches_freelancer ON saved_searches (freelancer_id)",
+			},
+			Schema: schema.NewSchema(
+				&schema.SchemaField{
+					Name:     "freelancer_id",
+					Type:     schema.FieldTypeRelation,
+					Required: true,
+					Options: &schema.RelationOptions{
+						CollectionId: usersCol.Id,
+						MaxSelect:    &maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name:     "name",
+					Type:     schema.FieldTypeText,
+					Required: true,
+					Options: &schema.TextOptions{
+						Max: &nameMax,
+					},
+				},
+				&schema.SchemaField{
+					Name: "keywords",
+					Type: schema.FieldTypeText,
+					Options: &schema.TextOptions{
+						Max: &keywordsMax,
+					},
+				},
+				&schema.SchemaField{
+					Name: "skills",
+					Type: schema.FieldTypeRelation,
+					Options: &schema.RelationOptions{
+						CollectionId: skillsCol.Id,
+						MaxSelect:    &skillsMax,
+					},
+				},
+				&schema.SchemaField{
+					Name: "type",
+					Type: schema.FieldTypeSelect,
+					Options: &schema.SelectOptions{
+						Values:    []string{"remote", "onsite", "hybrid"},
+						MaxSelect: maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name: "budget_min",
+					Type: schema.FieldTypeNumber,
+					Options: &schema.NumberOptions{
+						Min:       &zero,
+						NoDecimal: true,
+					},
+				},
+				&schema.SchemaField{
+					Name: "budget_max",
+					Type: schema.FieldTypeNumber,
+					Options: &schema.NumberOptions{
+						Min:       &zero,
+						NoDecimal: true,
+					},
+				},
+				// defaults to instant
+				&schema.SchemaField{
+					Name: "frequency",
+					Type: schema.FieldTypeSelect,
+					Options: &schema.SelectOptions{
+						Values:    []string{"instant", "daily"},
+						MaxSelect: maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name: "last_notified_at",
+					Type: schema.FieldTypeDate,
+				},
+				&schema.SchemaField{
+					Name: "is_deleted",
+					Type: schema.FieldTypeBool,
+				},
+			),
+		}
+
+		if err := dao.SaveCollection(savedSearches); err != nil {
+			return err
+		}
+
+		// -----------------------------
+		// SEARCH ALERTS
+		// -----------------------------
+		// One row per project matched by a saved search, written by the
+		// backend. notified_at stays empty until the alert was emailed,
+		// either immediately or with the daily digest.
+		searchAlerts := &models.Collection{
+			Name:     "search_alerts",
+			Type:     models.CollectionTypeBase,
+			System:   false,
+			ListRule: strPtr("is_deleted = false && freelancer_id = @request.auth.id"),
+			ViewRule: strPtr("is_deleted = false && freelancer_id = @request.auth.id"),
+			Indexes: []string{
+				"CREATE UNIQUE INDEX idx_search_alerts_search_project ON search_alerts (saved_search_id, project_id, project_opened_at)",
+				"CREATE INDEX idx_search_alerts_search_notified ON search_alerts (saved_search_id, notified_at)",
+			},
+			Schema: schema.NewSchema(
+				&schema.SchemaField{
+					Name:     "saved_search_id",
+					Type:     schema.FieldTypeRelation,
+					Required: true,
+					Options: &schema.RelationOptions{
+						CollectionId: savedSearches.Id,
+						MaxSelect:    &maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name:     "freelancer_id",
+					Type:     schema.FieldTypeRelation,
+					Required: true,
+					Options: &schema.RelationOptions{
+						CollectionId: usersCol.Id,
+						MaxSelect:    &maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name:     "project_id",
+					Type:     schema.FieldTypeRelation,
+					Required: true,
+					Options: &schema.RelationOptions{
+						CollectionId: projectsCol.Id,
+						MaxSelect:    &maxSelectOption,
+					},
+				},
+				&schema.SchemaField{
+					Name:     "project_opened_at",
+					Type:     schema.FieldTypeDate,
+					Required: true,
+				},
+				&schema.SchemaField{
+					Name: "notified_at",
+					Type: schema.FieldTypeDate,
+				},
+				&schema.SchemaField{
+					Name: "is_deleted",
+					Type: schema.FieldTypeBool,
+				},
+			),
+		}
+
+		return dao.SaveCollection(searchAlerts)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		for _, name := range []string{"search_alerts", "saved_searches"} {
+			col, err := dao.FindCollectionByNameOrId(name)
+			if err != nil {
+				return err
+			}
+			if err := dao.DeleteCollection(col); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+const (
+	projectsUpdateRuleBeforeMultipleHires = "is_deleted = false && @request.auth.role = 'client' && client_id = @request.auth.id"
+	// hires_multiple is only set by /proposals/:id/accept
+	projectsUpdateRuleWithMultipleHires = projectsUpdateRuleBeforeMultipleHires +
+		" && @request.data.hires_multiple:isset = false"
+)
+
+func init() {
+	migrations.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		projectsCol, err := dao.FindCollectionByNameOrId("projects")
+		if err != nil {
+			return err
+		}
+
+		// set when a proposal is accepted without rejecting the others, so
+		// the project keeps taking decisions on them while in progress
+		projectsCol.Schema.AddField(&schema.SchemaField{
+			Name: "hires_multiple",
+			Type: schema.FieldTypeBool,
+		})
+		projectsCol.UpdateRule = strPtr(projectsUpdateRuleWithMultipleHires)
+
+		return dao.SaveCollection(projectsCol)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		projectsCol, err := dao.FindCollectionByNameOrId("projects")
+		if err != nil {
+			return err
+		}
+
+		if field := projectsCol.Schema.GetFieldByName("hires_multiple"); field != nil {
+			projectsCol.Schema.RemoveField(field.Id)
+		}
+		projectsCol.UpdateRule = strPtr(projectsUpdateRuleBeforeMultipleHires)
+
+		return dao.SaveCollection(projectsCol)
+	})
+}
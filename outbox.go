@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	outboxJobCreateConversation = "create_conversation"
+
+	outboxPollInterval = 5 * time.Second
+	outboxBatchSize    = 20
+	outboxMaxAttempts  = 8
+	outboxBaseBackoff  = 10 * time.Second
+	outboxMaxBackoff   = 1 * time.Hour
+)
+
+// errOutboxJobCancelled can be returned by a handler when the job is no
+// longer relevant (e.g. the proposal was un-accepted before it ran).
+var errOutboxJobCancelled = errors.New("outbox job cancelled")
+
+type outboxHandler func(app core.App, job *models.Record) error
+
+type outboxWorker struct {
+	app      core.App
+	handlers map[string]outboxHandler
+	interval time.Duration
+
+	mu      sync.Mutex
+	running bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func newOutboxWorker(app core.App, handlers map[string]outboxHandler) *outboxWorker {
+	return &outboxWorker{
+		app:      app,
+		handlers: handlers,
+		interval: outboxPollInterval,
+	}
+}
+
+func (w *outboxWorker) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.running {
+		return
+	}
+	w.running = true
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+
+	go func(stop <-chan struct{}, done chan<- struct{}) {
+		defer close(done)
+
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := w.RunOnce(time.Now()); err != nil {
+				log.Printf("outbox worker: %v", err)
+			}
+
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}(w.stop, w.done)
+}
+
+func (w *outboxWorker) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.running {
+		return
+	}
+	w.running = false
+
+	close(w.stop)
+	<-w.done
+}
+
+// RunOnce processes every pending job that is due at the given time.
+func (w *outboxWorker) RunOnce(now time.Time) error {
+	due, err := types.ParseDateTime(now)
+	if err != nil {
+		return err
+	}
+
+	jobs, err := w.app.Dao().FindRecordsByFilter(
+		"outbox_jobs",
+		"status = 'pending' && is_deleted = false && (next_attempt_at = '' || next_attempt_at <= {:now})",
+		"next_attempt_at,created",
+		outboxBatchSize,
+		0,
+		dbx.Params{"now": due.String()},
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		w.process(job, now)
+	}
+
+	return nil
+}
+
+func (w *outboxWorker) process(job *models.Record, now time.Time) {
+	handler, ok := w.handlers[job.GetString("type")]
+
+	var err error
+	if !ok {
+		err = fmt.Errorf("no handler for job type %q", job.GetString("type"))
+	} else {
+		err = handler(w.app, job)
+	}
+
+	attempts := job.GetInt("attempts") + 1
+	job.Set("attempts", attempts)
+
+	switch {
+	case err == nil:
+		job.Set("status", "done")
+		job.Set("last_error", "")
+		job.Set("processed_at", now)
+	case errors.Is(err, errOutboxJobCancelled):
+		job.Set("status", "cancelled")
+		job.Set("last_error", err.Error())
+		job.Set("processed_at", now)
+	case attempts >= outboxMaxAttempts:
+		job.Set("status", "dead")
+		job.Set("last_error", err.Error())
+		log.Printf("outbox job %s (%s) moved to dead-letter after %d attempts: %v", job.Id, job.GetString("type"), attempts, err)
+	default:
+		job.Set("last_error", err.Error())
+		job.Set("next_attempt_at", now.Add(outboxBackoff(attempts)))
+	}
+
+	if err := w.app.Dao().SaveRecord(job); err != nil {
+		log.Printf("outbox job %s: failed to save state: %v", job.Id, err)
+	}
+}
+
+// outboxBackoff returns the exponential delay before the next attempt.
+func outboxBackoff(attempts int) time.Duration {
+	delay := time.Duration(float64(outboxBaseBackoff) * math.Pow(2, float64(attempts-1)))
+	if delay <= 0 || delay > outboxMaxBackoff {
+		return outboxMaxBackoff
+	}
+	return delay
+}
+
+// enqueueOutboxJob stores a pending job using the provided dao, so callers
+// inside a transaction get the job committed (or rolled back) together
+// with their own changes. Jobs with the same type and reference that are
+// still pending are not duplicated, see enqueueOutboxJobAt.
+func enqueueOutboxJob(dao *daos.Dao, jobType string, referenceID string, payload map[string]any) error {
+	return enqueueOutboxJobAt(dao, jobType, referenceID, payload, time.Now())
+}
+
+// enqueueOutboxJobAt is like enqueueOutboxJob but delays the first attempt
+// until runAt. When a job with the same type and reference is still pending
+// it is merged into that job instead: a non-nil payload replaces the stored
+// one and an earlier runAt brings the next attempt forward, while a later
+// runAt keeps the existing schedule.
+func enqueueOutboxJobAt(dao *daos.Dao, jobType string, referenceID string, payload map[string]any, runAt time.Time) error {
+	existing, err := dao.FindFirstRecordByFilter(
+		"outbox_jobs",
+		"type = {:type} && reference_id = {:ref} && status = 'pending' && is_deleted = false",
+		dbx.Params{"type": jobType, "ref": referenceID},
+	)
+	if err == nil {
+		return mergeOutboxJob(dao, existing, payload, runAt)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	col, err := dao.FindCollectionByNameOrId("outbox_jobs")
+	if err != nil {
+		return err
+	}
+
+	job := models.NewRecord(col)
+	job.Set("type", jobType)
+	job.Set("reference_id", referenceID)
+	job.Set("payload", payload)
+	job.Set("status", "pending")
+	job.Set("attempts", 0)
+	job.Set("next_attempt_at", runAt)
+	job.Set("is_deleted", false)
+
+	return dao.SaveRecord(job)
+}
+
+func mergeOutboxJob(dao *daos.Dao, job *models.Record, payload map[string]any, runAt time.Time) error {
+	changed := false
+
+	if payload != nil {
+		current, _ := json.Marshal(outboxJobPayload(job))
+		next, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(current, next) {
+			job.Set("payload", payload)
+			changed = true
+		}
+	}
+
+	if nextAttempt := job.GetDateTime("next_attempt_at"); !nextAttempt.IsZero() && runAt.Before(nextAttempt.Time()) {
+		job.Set("next_attempt_at", runAt)
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return dao.SaveRecord(job)
+}
+
+func registerOutboxRoutes(app core.App, router *echo.Echo) {
+	group := router.Group("/admin/outbox", apis.RequireAdminAuth())
+
+	group.GET("/jobs", func(c echo.Context) error {
+		status := c.QueryParam("status")
+		if status == "" {
+			status = "dead"
+		}
+
+		jobs, err := app.Dao().FindRecordsByFilter(
+			"outbox_jobs",
+			"status = {:status} && is_deleted = false",
+			"-updated",
+			200,
+			0,
+			dbx.Params{"status": status},
+		)
+		if err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to load outbox jobs", err)
+		}
+
+		return c.JSON(http.StatusOK, jobs)
+	})
+
+	group.POST("/jobs/:id/retry", func(c echo.Context) error {
+		job, err := app.Dao().FindRecordById("outbox_jobs", c.PathParam("id"))
+		if err != nil {
+			return apis.NewNotFoundError("outbox job not found", err)
+		}
+		if job.GetString("status") != "dead" {
+			return apis.NewApiError(http.StatusConflict, "only dead jobs can be retried", nil)
+		}
+
+		job.Set("status", "pending")
+		job.Set("attempts", 0)
+		job.Set("next_attempt_at", time.Now())
+
+		if err := app.Dao().SaveRecord(job); err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to requeue outbox job", err)
+		}
+
+		return c.JSON(http.StatusOK, job)
+	})
+}
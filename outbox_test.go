@@ -0,0 +1,247 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+func TestEnqueueProposalAcceptanceIsTransactional(t *testing.T) {
+	app := newTestApp(t)
+
+	proposal := createTestProposal(t, app)
+
+	// a failing transaction must not leave a job behind
+	err := app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		proposal.Set("status", "accepted")
+		if err := txDao.SaveRecord(proposal); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	if err == nil {
+		t.Fatal("expected rollback error")
+	}
+	if jobs := findOutboxJobsByType(t, app, outboxJobCreateConversation); len(jobs) != 0 {
+		t.Fatalf("expected no jobs after rollback, got %d", len(jobs))
+	}
+
+	proposal, err = app.Dao().FindRecordById("proposals", proposal.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proposal.Set("status", "accepted")
+	if err := app.Dao().SaveRecord(proposal); err != nil {
+		t.Fatal(err)
+	}
+	// saving again while the job is pending must not duplicate it
+	if err := app.Dao().SaveRecord(proposal); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs := findOutboxJobsByType(t, app, outboxJobCreateConversation)
+	if len(jobs) != 1 {
+		t.Fatalf("expected 1 job, got %d", len(jobs))
+	}
+	if jobs[0].GetString("type") != outboxJobCreateConversation || jobs[0].GetString("reference_id") != proposal.Id {
+		t.Fatalf("unexpected job %v", jobs[0].PublicExport())
+	}
+}
+
+func TestOutboxWorkerRetriesAndDeadLetters(t *testing.T) {
+	app := newTestApp(t)
+
+	calls := 0
+	worker := newOutboxWorker(app, map[string]outboxHandler{
+		"always_fails": func(app core.App, job *models.Record) error {
+			calls++
+			return errors.New("stream unavailable")
+		},
+	})
+
+	if err := enqueueOutboxJob(app.Dao(), "always_fails", "ref_1", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	if err := worker.RunOnce(now); err != nil {
+		t.Fatal(err)
+	}
+
+	job := findOutboxJobs(t, app)[0]
+	if job.GetString("status") != "pending" || job.GetInt("attempts") != 1 {
+		t.Fatalf("expected pending job with 1 attempt, got %v", job.PublicExport())
+	}
+	if next := job.GetDateTime("next_attempt_at").Time(); !next.After(now) {
+		t.Fatalf("expected backoff, next attempt at %s", next)
+	}
+
+	// not due yet
+	if err := worker.RunOnce(now); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected the job to wait for its backoff, got %d calls", calls)
+	}
+
+	for i := 1; i < outboxMaxAttempts; i++ {
+		now = now.Add(outboxMaxBackoff + time.Second)
+		if err := worker.RunOnce(now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	job = findOutboxJobs(t, app)[0]
+	if job.GetString("status") != "dead" || job.GetString("last_error") != "stream unavailable" {
+		t.Fatalf("expected dead job, got %v", job.PublicExport())
+	}
+	if calls != outboxMaxAttempts {
+		t.Fatalf("expected %d calls, got %d", outboxMaxAttempts, calls)
+	}
+}
+
+func TestOutboxWorkerCancelledJob(t *testing.T) {
+	app := newTestApp(t)
+
+	worker := newOutboxWorker(app, map[string]outboxHandler{
+		"stale": func(app core.App, job *models.Record) error {
+			return errOutboxJobCancelled
+		},
+	})
+
+	if err := enqueueOutboxJob(app.Dao(), "stale", "ref_1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := worker.RunOnce(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := findOutboxJobs(t, app)[0].GetString("status"); status != "cancelled" {
+		t.Fatalf("expected cancelled job, got %q", status)
+	}
+}
+
+func TestEnqueueOutboxJobMergesPendingJob(t *testing.T) {
+	app := newTestApp(t)
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	load := func() *models.Record {
+		t.Helper()
+		jobs := findOutboxJobsByType(t, app, "merge")
+		if len(jobs) != 1 {
+			t.Fatalf("expected 1 job, got %d", len(jobs))
+		}
+		return jobs[0]
+	}
+
+	if err := enqueueOutboxJobAt(app.Dao(), "merge", "ref_1", map[string]any{"reason": "first"}, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// a later run time keeps the schedule, a new payload replaces the old one
+	if err := enqueueOutboxJobAt(app.Dao(), "merge", "ref_1", map[string]any{"reason": "second"}, now.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	job := load()
+	if reason := outboxJobPayload(job)["reason"]; reason != "second" {
+		t.Fatalf("expected the payload to be replaced, got %v", reason)
+	}
+	if next := job.GetDateTime("next_attempt_at").Time(); !next.Equal(now.Add(time.Hour)) {
+		t.Fatalf("expected the next attempt to stay at %s, got %s", now.Add(time.Hour), next)
+	}
+
+	// an earlier run time brings the job forward, a nil payload keeps it
+	if err := enqueueOutboxJobAt(app.Dao(), "merge", "ref_1", nil, now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	job = load()
+	if reason := outboxJobPayload(job)["reason"]; reason != "second" {
+		t.Fatalf("expected the payload to be kept, got %v", reason)
+	}
+	if next := job.GetDateTime("next_attempt_at").Time(); !next.Equal(now.Add(time.Minute)) {
+		t.Fatalf("expected the next attempt to move to %s, got %s", now.Add(time.Minute), next)
+	}
+
+	// jobs that are no longer pending are not merged into
+	job.Set("status", "done")
+	if err := app.Dao().SaveRecord(job); err != nil {
+		t.Fatal(err)
+	}
+	if err := enqueueOutboxJob(app.Dao(), "merge", "ref_1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if jobs := findOutboxJobsByType(t, app, "merge"); len(jobs) != 2 {
+		t.Fatalf("expected a new job once the first is done, got %d", len(jobs))
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	scenarios := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, outboxBaseBackoff},
+		{2, 2 * outboxBaseBackoff},
+		{3, 4 * outboxBaseBackoff},
+		{100, outboxMaxBackoff},
+	}
+
+	for _, s := range scenarios {
+		if got := outboxBackoff(s.attempts); got != s.expected {
+			t.Errorf("attempts %d: expected %s, got %s", s.attempts, s.expected, got)
+		}
+	}
+}
+
+func createTestProposal(t *testing.T, app *tests.TestApp) *models.Record {
+	t.Helper()
+
+	client := createTestUser(t, app, "client", nil)
+	freelancer := createTestUser(t, app, "freelancer", nil)
+
+	project := createTestRecord(t, app, "projects", map[string]any{
+		"title":       "Project",
+		"description": "Description",
+		"type":        "remote",
+		"client_id":   client.Id,
+		"status":      "open",
+		"is_deleted":  false,
+	})
+
+	return createTestRecord(t, app, "proposals", map[string]any{
+		"project_id":    project.Id,
+		"freelancer_id": freelancer.Id,
+		"client_id":     client.Id,
+		"message":       "Hello",
+		"status":        "sent",
+		"is_deleted":    false,
+	})
+}
+
+func findOutboxJobs(t *testing.T, app *tests.TestApp) []*models.Record {
+	t.Helper()
+
+	jobs, err := app.Dao().FindRecordsByExpr("outbox_jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return jobs
+}
+
+func findOutboxJobsByType(t *testing.T, app *tests.TestApp, jobType string) []*models.Record {
+	t.Helper()
+
+	jobs, err := app.Dao().FindRecordsByFilter("outbox_jobs", "type = {:type}", "created", 0, 0, dbx.Params{"type": jobType})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return jobs
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"time"
+
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// prepareProjectInvitation resets the backend managed fields of a new
+// invitation.
+func prepareProjectInvitation(invitation *models.Record) {
+	invitation.Set("status", "pending")
+	invitation.Set("responded_at", "")
+}
+
+// applyProjectInvitationResponse stamps the freelancer's answer to a
+// pending invitation.
+func applyProjectInvitationResponse(invitation *models.Record, now time.Time) {
+	if invitation.IsNew() || invitation.OriginalCopy().GetString("status") != "pending" {
+		return
+	}
+	if status := invitation.GetString("status"); status == "accepted" || status == "declined" {
+		invitation.Set("responded_at", now)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+func createTestProject(t *testing.T, app *tests.TestApp, client *models.Record, status string, visibility string) *models.Record {
+	t.Helper()
+
+	return createTestRecord(t, app, "projects", map[string]any{
+		"title":       "Project",
+		"description": "Description",
+		"type":        "remote",
+		"client_id":   client.Id,
+		"status":      status,
+		"visibility":  visibility,
+		"is_deleted":  false,
+	})
+}
+
+func createTestInvitation(t *testing.T, app *tests.TestApp, project *models.Record, freelancer *models.Record) *models.Record {
+	t.Helper()
+
+	return createTestRecord(t, app, "project_invitations", map[string]any{
+		"project_id":    project.Id,
+		"client_id":     project.GetString("client_id"),
+		"freelancer_id": freelancer.Id,
+		"message":       "Interested?",
+		// reset by the backend
+		"status":     "accepted",
+		"is_deleted": false,
+	})
+}
+
+func canAccess(t *testing.T, app *tests.TestApp, record *models.Record, rule *string, auth *models.Record, data map[string]any) bool {
+	t.Helper()
+
+	ok, err := app.Dao().CanAccessRecord(record, &models.RequestInfo{AuthRecord: auth, Data: data}, rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ok
+}
+
+func TestInvitedFreelancerCanViewProject(t *testing.T) {
+	app := newTestApp(t)
+	client := createTestUser(t, app, "client", nil)
+	invitee := createTestUser(t, app, "freelancer", nil)
+	other := createTestUser(t, app, "freelancer", nil)
+
+	private := createTestProject(t, app, client, "in_progress", "invite_only")
+	public := createTestProject(t, app, client, "open", "public")
+	hidden := createTestProject(t, app, client, "open", "invite_only")
+
+	invitation := createTestInvitation(t, app, private, invitee)
+	// an invitation to another project must not leak access
+	createTestInvitation(t, app, public, other)
+
+	rule := private.Collection().ViewRule
+
+	if !canAccess(t, app, private, rule, invitee, nil) {
+		t.Fatal("expected the invitee to see the private project")
+	}
+	if canAccess(t, app, private, rule, other, nil) {
+		t.Fatal("expected other freelancers not to see the private project")
+	}
+	if canAccess(t, app, hidden, rule, other, nil) {
+		t.Fatal("expected invite-only projects to be hidden even when open")
+	}
+	if !canAccess(t, app, public, rule, other, nil) {
+		t.Fatal("expected open public projects to stay visible")
+	}
+
+	invitation.Set("status", "declined")
+	if err := app.Dao().SaveRecord(invitation); err != nil {
+		t.Fatal(err)
+	}
+	if canAccess(t, app, private, rule, invitee, nil) {
+		t.Fatal("expected a declined invitation to hide the project again")
+	}
+}
+
+func TestProjectInvitationResponse(t *testing.T) {
+	app := newTestApp(t)
+	client := createTestUser(t, app, "client", nil)
+	freelancer := createTestUser(t, app, "freelancer", nil)
+	project := createTestProject(t, app, client, "open", "invite_only")
+
+	invitation := createTestInvitation(t, app, project, freelancer)
+	if invitation.GetString("status") != "pending" {
+		t.Fatalf("expected a pending invitation, got %q", invitation.GetString("status"))
+	}
+
+	rule := invitation.Collection().UpdateRule
+	scenarios := []struct {
+		name     string
+		auth     *models.Record
+		data     map[string]any
+		expected bool
+	}{
+		{"freelancer accepts", freelancer, map[string]any{"status": "accepted"}, true},
+		{"freelancer resets to pending", freelancer, map[string]any{"status": "pending"}, false},
+		{"freelancer edits the message", freelancer, map[string]any{"status": "accepted", "message": "Hi"}, false},
+		{"client accepts", client, map[string]any{"status": "accepted"}, false},
+		{"client withdraws", client, map[string]any{"is_deleted": true}, true},
+	}
+	for _, s := range scenarios {
+		if ok := canAccess(t, app, invitation, rule, s.auth, s.data); ok != s.expected {
+			t.Fatalf("%s: expected %v, got %v", s.name, s.expected, ok)
+		}
+	}
+
+	proposal := createTestProposal(t, app)
+	proposalRule := proposal.Collection().CreateRule
+	proposalData := map[string]any{"project_id": project.Id, "freelancer_id": freelancer.Id, "client_id": client.Id, "status": "sent"}
+
+	if canAccess(t, app, proposal, proposalRule, freelancer, proposalData) {
+		t.Fatal("expected proposals on invite-only projects to need an accepted invitation")
+	}
+
+	// reload so the original copy reflects the stored pending status
+	invitation, err := app.Dao().FindRecordById("project_invitations", invitation.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	invitation.Set("status", "accepted")
+	if err := app.Dao().SaveRecord(invitation); err != nil {
+		t.Fatal(err)
+	}
+	if invitation.GetDateTime("responded_at").IsZero() {
+		t.Fatal("expected responded_at to be set")
+	}
+	if !canAccess(t, app, proposal, proposalRule, freelancer, proposalData) {
+		t.Fatal("expected the invitee to be able to propose")
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/resolvers"
+	"github.com/pocketbase/pocketbase/tools/search"
+)
+
+const (
+	defaultProjectSearchPageSize = 20
+	maxProjectSearchPageSize     = 100
+	maxProjectSearchSkills       = 20
+)
+
+// projectSearchTable is the FTS5 index of the project texts. It is created
+// at startup by ensureProjectSearchIndex and only exists when SQLite was
+// built with FTS5.
+const projectSearchTable = "projects_fts"
+
+// errProjectSearchUnavailable is returned by ensureProjectSearchIndex when
+// SQLite lacks FTS5; cgo builds only ship it with the sqlite_fts5 build tag.
+var errProjectSearchUnavailable = errors.New("SQLite was built without FTS5, project search is disabled")
+
+// ensureProjectSearchIndex creates the search index and fills it with the
+// existing projects when it is missing. Running it on every start rather
+// than in a migration lets a binary built with FTS5 pick up a database that
+// was created by one without it.
+func ensureProjectSearchIndex(dao *daos.Dao) error {
+	if dao.HasTable(projectSearchTable) {
+		return nil
+	}
+
+	return dao.RunInTransaction(func(txDao *daos.Dao) error {
+		// access is checked against the projects table, the index only
+		// holds the texts
+		_, err := txDao.DB().NewQuery(`
+			CREATE VIRTUAL TABLE projects_fts USING fts5(
+				project_id UNINDEXED,
+				title,
+				description,
+				tokenize = 'unicode61 remove_diacritics 2'
+			)
+		`).Execute()
+		if err != nil {
+			if strings.Contains(err.Error(), "no such module: fts5") {
+				return errProjectSearchUnavailable
+			}
+			return err
+		}
+
+		_, err = txDao.DB().NewQuery(`
+			INSERT INTO projects_fts (project_id, title, description)
+			SELECT id, title, description FROM projects
+		`).Execute()
+		return err
+	})
+}
+
+// indexProject refreshes the search index entry of a project. It is called
+// with the event dao so the index is written in the same transaction as the
+// project itself.
+func indexProject(dao *daos.Dao, project *models.Record) error {
+	if !dao.HasTable(projectSearchTable) {
+		return nil
+	}
+	if err := unindexProject(dao, project.Id); err != nil {
+		return err
+	}
+
+	_, err := dao.DB().Insert(projectSearchTable, dbx.Params{
+		"project_id":  project.Id,
+		"title":       project.GetString("title"),
+		"description": project.GetString("description"),
+	}).Execute()
+	return err
+}
+
+// unindexProject removes a project from the search index.
+func unindexProject(dao *daos.Dao, projectID string) error {
+	if !dao.HasTable(projectSearchTable) {
+		return nil
+	}
+	_, err := dao.DB().Delete(projectSearchTable, dbx.HashExp{"project_id": projectID}).Execute()
+	return err
+}
+
+// projectSearchMatch turns free text into an FTS5 query: every word must
+// match and the last one may be a prefix, so results show up while typing.
+// Quoting each word keeps FTS5 operators in the input from being parsed.
+func projectSearchMatch(q string) string {
+	terms := []string{}
+	for _, word := range strings.Fields(q) {
+		word = strings.ReplaceAll(word, `"`, "")
+		if word == "" {
+			continue
+		}
+		terms = append(terms, `"`+word+`"`)
+	}
+	if len(terms) == 0 {
+		return ""
+	}
+	terms[len(terms)-1] += "*"
+
+	return strings.Join(terms, " ")
+}
+
+// queryInt parses an optional non negative integer query param.
+func queryInt(c echo.Context, name string, fallback int) (int, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		return 0, apis.NewBadRequestError(name+" must be a non negative integer", nil)
+	}
+	return parsed, nil
+}
+
+// projectSearchHandler searches the title and description of the projects
+// visible to the current user, best matches first.
+//
+// Query params: q (free text; without it the newest projects come first),
+// type, budget_min and budget_max (projects whose budget range overlaps),
+// skills (comma separated skill ids, any of them), page and limit (default
+// 20, max 100).
+//
+// Visibility is enforced by applying the projects ListRule to the query, so
+// the results always match what the records API would list.
+func projectSearchHandler(app core.App) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+		if !ok || record == nil {
+			return apis.NewUnauthorizedError("unauthorized", nil)
+		}
+
+		limit, err := queryInt(c, "limit", defaultProjectSearchPageSize)
+		if err != nil {
+			return err
+		}
+		if limit < 1 || limit > maxProjectSearchPageSize {
+			return apis.NewBadRequestError("limit must be between 1 and "+strconv.Itoa(maxProjectSearchPageSize), nil)
+		}
+		page, err := queryInt(c, "page", 1)
+		if err != nil {
+			return err
+		}
+		if page < 1 {
+			return apis.NewBadRequestError("page must be at least 1", nil)
+		}
+
+		if !app.Dao().HasTable(projectSearchTable) {
+			return apis.NewApiError(http.StatusServiceUnavailable, "project search is not available", nil)
+		}
+
+		col, err := app.Dao().FindCollectionByNameOrId("projects")
+		if err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to load projects", err)
+		}
+		if col.ListRule == nil {
+			return apis.NewForbiddenError("only admins can list projects", nil)
+		}
+
+		query := app.Dao().RecordQuery(col)
+
+		if *col.ListRule != "" {
+			resolver := resolvers.NewRecordFieldResolver(app.Dao(), col, apis.RequestInfo(c), true)
+			expr, err := search.FilterData(*col.ListRule).BuildExpr(resolver)
+			if err != nil {
+				return apis.NewApiError(http.StatusInternalServerError, "failed to apply the list rule", err)
+			}
+			query.AndWhere(expr)
+			if err := resolver.UpdateQuery(query); err != nil {
+				return apis.NewApiError(http.StatusInternalServerError, "failed to apply the list rule", err)
+			}
+		}
+
+		if projectType := c.QueryParam("type"); projectType != "" {
+			if projectType != "remote" && projectType != "onsite" && projectType != "hybrid" {
+				return apis.NewBadRequestError("type must be one of remote, onsite, hybrid", nil)
+			}
+			query.AndWhere(dbx.HashExp{"projects.type": projectType})
+		}
+
+		budgetMin, err := queryInt(c, "budget_min", 0)
+		if err != nil {
+			return err
+		}
+		budgetMax, err := queryInt(c, "budget_max", 0)
+		if err != nil {
+			return err
+		}
+		if budgetMax > 0 && budgetMin > budgetMax {
+			return apis.NewBadRequestError("budget_max must not be lower than budget_min", nil)
+		}
+		// an empty budget_max means the project has no upper bound
+		if budgetMin > 0 {
+			query.AndWhere(dbx.NewExp(
+				"([[projects.budget_max]] >= {:budget_min} OR [[projects.budget_max]] = 0)",
+				dbx.Params{"budget_min": budgetMin},
+			))
+		}
+		if budgetMax > 0 {
+			query.AndWhere(dbx.NewExp("[[projects.budget_min]] <= {:budget_max}", dbx.Params{"budget_max": budgetMax}))
+		}
+
+		if value := c.QueryParam("skills"); value != "" {
+			skills := strings.Split(value, ",")
+			if len(skills) > maxProjectSearchSkills {
+				return apis.NewBadRequestError("at most "+strconv.Itoa(maxProjectSearchSkills)+" skills can be searched", nil)
+			}
+			placeholders := make([]string, 0, len(skills))
+			params := dbx.Params{}
+			for i, skill := range skills {
+				name := "skill" + strconv.Itoa(i)
+				placeholders = append(placeholders, "{:"+name+"}")
+				params[name] = strings.TrimSpace(skill)
+			}
+			query.AndWhere(dbx.NewExp(
+				"EXISTS (SELECT 1 FROM json_each([[projects.skills]]) WHERE value IN ("+strings.Join(placeholders, ", ")+"))",
+				params,
+			))
+		}
+
+		// the title matters more than the description for the ranking
+		match := projectSearchMatch(c.QueryParam("q"))
+		if match != "" {
+			query.
+				InnerJoin("projects_fts", dbx.NewExp("[[projects_fts.project_id]] = [[projects.id]]")).
+				AndWhere(dbx.NewExp("projects_fts MATCH {:match}", dbx.Params{"match": match})).
+				AndSelect(
+					"highlight(projects_fts, 1, '<mark>', '</mark>') AS search_title",
+					"snippet(projects_fts, 2, '<mark>', '</mark>', '…', 32) AS search_snippet",
+					"bm25(projects_fts, 0.0, 10.0, 1.0) AS search_rank",
+				).
+				OrderBy("search_rank ASC", "[[projects.created]] DESC")
+		} else {
+			query.OrderBy("[[projects.created]] DESC")
+		}
+
+		// one row more than requested tells whether there is a next page
+		rows := []dbx.NullStringMap{}
+		if err := query.Limit(int64(limit + 1)).Offset(int64((page - 1) * limit)).All(&rows); err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to search projects", err)
+		}
+
+		hasMore := len(rows) > limit
+		if hasMore {
+			rows = rows[:limit]
+		}
+
+		items := make([]map[string]any, 0, len(rows))
+		for _, row := range rows {
+			item := models.NewRecordFromNullStringMap(col, row).PublicExport()
+			if match != "" {
+				rank, _ := strconv.ParseFloat(row["search_rank"].String, 64)
+				item["search"] = map[string]any{
+					"title":   row["search_title"].String,
+					"snippet": row["search_snippet"].String,
+					// bm25 scores are negative, lower is better
+					"score": -rank,
+				}
+			}
+			items = append(items, item)
+		}
+
+		return c.JSON(http.StatusOK, map[string]any{
+			"items":    items,
+			"page":     page,
+			"limit":    limit,
+			"has_more": hasMore,
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+func searchProjects(t *testing.T, app *tests.TestApp, auth *models.Record, params url.Values) (int, []map[string]any) {
+	t.Helper()
+
+	code, rec := callHandler(t, projectSearchHandler(app), http.MethodGet, "/projects/search?"+params.Encode(), nil, http.Header{}, auth)
+
+	var response struct {
+		Items []map[string]any `json:"items"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &response)
+	return code, response.Items
+}
+
+func projectTitles(items []map[string]any) []string {
+	titles := make([]string, 0, len(items))
+	for _, item := range items {
+		titles = append(titles, item["title"].(string))
+	}
+	return titles
+}
+
+func TestProjectSearch(t *testing.T) {
+	app := newTestApp(t)
+	if !app.Dao().HasTable(projectSearchTable) {
+		t.Skip("SQLite was built without FTS5, run make test or add -tags sqlite_fts5")
+	}
+
+	client := createTestUser(t, app, "client", nil)
+	freelancer := createTestUser(t, app, "freelancer", nil)
+	mobile := createTestRecord(t, app, "skills", map[string]any{"name": "Mobile"})
+	design := createTestRecord(t, app, "skills", map[string]any{"name": "Design"})
+
+	project := func(title string, description string, fields map[string]any) *models.Record {
+		data := map[string]any{
+			"title":       title,
+			"description": description,
+			"type":        "remote",
+			"client_id":   client.Id,
+			"status":      "open",
+			"is_deleted":  false,
+		}
+		for key, value := range fields {
+			data[key] = value
+		}
+		return createTestRecord(t, app, "projects", data)
+	}
+
+	project("React Native app", "An iOS and Android client", map[string]any{
+		"budget_min": 100000, "budget_max": 500000, "skills": []string{mobile.Id},
+	})
+	redesign := project("Website redesign", "Move the landing page to React", map[string]any{
+		"type": "onsite", "location": "Berlin", "budget_min": 1000000, "skills": []string{design.Id},
+	})
+	project("React dashboard", "Private work", map[string]any{"visibility": "invite_only"})
+	project("React migration", "Already staffed", map[string]any{"status": "in_progress"})
+
+	code, items := searchProjects(t, app, freelancer, url.Values{"q": {"react"}})
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if titles := projectTitles(items); len(titles) != 2 || titles[0] != "React Native app" || titles[1] != "Website redesign" {
+		t.Fatalf("expected the visible projects, title matches first, got %v", titles)
+	}
+	highlight, _ := items[0]["search"].(map[string]any)
+	if title, _ := highlight["title"].(string); !strings.Contains(title, "<mark>React</mark>") {
+		t.Fatalf("expected a highlighted title, got %v", highlight)
+	}
+
+	// the client sees their own projects whatever their status
+	if _, items := searchProjects(t, app, client, url.Values{"q": {"react"}}); len(items) != 4 {
+		t.Fatalf("expected 4 projects for the client, got %v", projectTitles(items))
+	}
+
+	scenarios := []struct {
+		name     string
+		params   url.Values
+		expected []string
+	}{
+		{"prefix", url.Values{"q": {"redes"}}, []string{"Website redesign"}},
+		{"type", url.Values{"q": {"react"}, "type": {"onsite"}}, []string{"Website redesign"}},
+		{"budget", url.Values{"budget_min": {"600000"}}, []string{"Website redesign"}},
+		{"open ended budget", url.Values{"budget_max": {"200000"}}, []string{"React Native app"}},
+		{"skills", url.Values{"skills": {mobile.Id + ",unknown"}}, []string{"React Native app"}},
+		{"operators are plain text", url.Values{"q": {`react" OR "dashboard`}}, []string{}},
+	}
+	for _, s := range scenarios {
+		code, items := searchProjects(t, app, freelancer, s.params)
+		if code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", s.name, code)
+		}
+		if titles := projectTitles(items); strings.Join(titles, "|") != strings.Join(s.expected, "|") {
+			t.Fatalf("%s: expected %v, got %v", s.name, s.expected, titles)
+		}
+	}
+
+	if code, _ := searchProjects(t, app, freelancer, url.Values{"type": {"office"}}); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown type, got %d", code)
+	}
+
+	// the index follows the edits
+	redesign.Set("title", "Logo")
+	if err := app.Dao().SaveRecord(redesign); err != nil {
+		t.Fatal(err)
+	}
+	if _, items := searchProjects(t, app, freelancer, url.Values{"q": {"redesign"}}); len(items) != 0 {
+		t.Fatalf("expected the old title to be gone, got %v", projectTitles(items))
+	}
+}
+
+func TestEnsureProjectSearchIndexBackfills(t *testing.T) {
+	app := newTestApp(t)
+	if !app.Dao().HasTable(projectSearchTable) {
+		t.Skip("SQLite was built without FTS5, run make test or add -tags sqlite_fts5")
+	}
+
+	client := createTestUser(t, app, "client", nil)
+	freelancer := createTestUser(t, app, "freelancer", nil)
+	project := createTestProject(t, app, client, "open", "public")
+
+	// a database created by a build without FTS5
+	if _, err := app.Dao().DB().NewQuery("DROP TABLE " + projectSearchTable).Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ensureProjectSearchIndex(app.Dao()); err != nil {
+		t.Fatal(err)
+	}
+
+	code, items := searchProjects(t, app, freelancer, url.Values{"q": {project.GetString("title")}})
+	if code != http.StatusOK || len(items) != 1 || items[0]["id"] != project.Id {
+		t.Fatalf("expected the existing project to be indexed, got %d: %v", code, items)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+const (
+	defaultProjectCurrency     = "usd"
+	defaultProjectPricingModel = "fixed"
+)
+
+// prepareProject fills in the defaults of a new project.
+func prepareProject(project *models.Record) {
+	project.Set("currency", strings.ToLower(strings.TrimSpace(project.GetString("currency"))))
+	if project.GetString("currency") == "" {
+		project.Set("currency", defaultProjectCurrency)
+	}
+	if project.GetString("pricing_model") == "" {
+		project.Set("pricing_model", defaultProjectPricingModel)
+	}
+}
+
+// validateProject checks the constraints the collection schema cannot
+// express. On updates only the changed fields are checked, so older projects
+// keep moving through their statuses. The errors are returned as
+// validation.Errors so the API reports them per field.
+func validateProject(project *models.Record, now time.Time) error {
+	var original *models.Record
+	if !project.IsNew() {
+		original = project.OriginalCopy()
+		project.Set("currency", strings.ToLower(strings.TrimSpace(project.GetString("currency"))))
+	}
+	changed := func(fields ...string) bool {
+		if original == nil {
+			return true
+		}
+		for _, field := range fields {
+			if project.GetString(field) != original.GetString(field) {
+				return true
+			}
+		}
+		return false
+	}
+
+	errs := validation.Errors{}
+
+	if changed("type", "location") {
+		projectType := project.GetString("type")
+		if (projectType == "onsite" || projectType == "hybrid") && strings.TrimSpace(project.GetString("location")) == "" {
+			errs["location"] = validation.NewError("validation_required", "Location is required for onsite and hybrid projects.")
+		}
+	}
+
+	if changed("budget_min", "budget_max") {
+		min, max := project.GetInt("budget_min"), project.GetInt("budget_max")
+		if max > 0 && min > max {
+			errs["budget_max"] = validation.NewError("validation_budget_range", "Must not be lower than budget_min.")
+		}
+	}
+
+	if changed("deadline") {
+		deadline := project.GetDateTime("deadline")
+		if !deadline.IsZero() && !deadline.Time().After(now) {
+			errs["deadline"] = validation.NewError("validation_past_deadline", "Must be in the future.")
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+func TestValidateProject(t *testing.T) {
+	app := newTestApp(t)
+	client := createTestUser(t, app, "client", nil)
+
+	col, err := app.Dao().FindCollectionByNameOrId("projects")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	scenarios := []struct {
+		name     string
+		fields   map[string]any
+		expected string
+	}{
+		{"remote without location", map[string]any{"type": "remote"}, ""},
+		{"onsite without location", map[string]any{"type": "onsite"}, "location"},
+		{"hybrid with blank location", map[string]any{"type": "hybrid", "location": "  "}, "location"},
+		{"onsite with location", map[string]any{"type": "onsite", "location": "Berlin"}, ""},
+		{"inverted budget", map[string]any{"type": "remote", "budget_min": 5000, "budget_max": 1000}, "budget_max"},
+		{"open ended budget", map[string]any{"type": "remote", "budget_min": 5000}, ""},
+		{"past deadline", map[string]any{"type": "remote", "deadline": now.Add(-time.Hour)}, "deadline"},
+		{"future deadline", map[string]any{"type": "remote", "deadline": now.Add(24 * time.Hour)}, ""},
+	}
+	for _, s := range scenarios {
+		project := models.NewRecord(col)
+		for key, value := range s.fields {
+			project.Set(key, value)
+		}
+
+		err := validateProject(project, now)
+		if s.expected == "" {
+			if err != nil {
+				t.Fatalf("%s: unexpected error %v", s.name, err)
+			}
+			continue
+		}
+		var errs validation.Errors
+		if !errors.As(err, &errs) || errs[s.expected] == nil {
+			t.Fatalf("%s: expected a %s error, got %v", s.name, s.expected, err)
+		}
+	}
+
+	project := createTestRecord(t, app, "projects", map[string]any{
+		"title":       "Project",
+		"description": "Description",
+		"type":        "remote",
+		"client_id":   client.Id,
+		"status":      "open",
+		"currency":    "EUR",
+	})
+	if project.GetString("currency") != "eur" || project.GetString("pricing_model") != defaultProjectPricingModel {
+		t.Fatalf("expected the defaults to be applied, got %q and %q", project.GetString("currency"), project.GetString("pricing_model"))
+	}
+}
+
+func TestValidateProjectUpdate(t *testing.T) {
+	app := newTestApp(t)
+	client := createTestUser(t, app, "client", nil)
+
+	col, err := app.Dao().FindCollectionByNameOrId("projects")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// created before locations were required, so without the hooks
+	legacy := models.NewRecord(col)
+	legacy.Load(map[string]any{
+		"title":       "Project",
+		"description": "Description",
+		"type":        "onsite",
+		"client_id":   client.Id,
+		"status":      "open",
+		"deadline":    time.Now().Add(-time.Hour),
+	})
+	if err := app.Dao().WithoutHooks().SaveRecord(legacy); err != nil {
+		t.Fatal(err)
+	}
+	project, err := app.Dao().FindRecordById("projects", legacy.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	project.Set("status", "in_progress")
+	if err := validateProject(project, time.Now()); err != nil {
+		t.Fatalf("expected untouched fields to be skipped, got %v", err)
+	}
+
+	project.Set("type", "hybrid")
+	if err := validateProject(project, time.Now()); err == nil {
+		t.Fatal("expected a location to be required when changing the type")
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+const defaultProposalCurrency = "usd"
+
+// proposalTermsFields are copied into a revision whenever one of them
+// changes on a proposal.
+var proposalTermsFields = []string{"message", "bid_amount", "currency", "estimated_duration", "attachments"}
+
+// normalizeProposalCurrency stores currencies lowercased, like payments.
+func normalizeProposalCurrency(proposal *models.Record) {
+	proposal.Set("currency", strings.ToLower(strings.TrimSpace(proposal.GetString("currency"))))
+}
+
+// prepareProposal resets the backend managed fields of a new proposal.
+func prepareProposal(proposal *models.Record) {
+	normalizeProposalCurrency(proposal)
+	if proposal.GetString("currency") == "" {
+		proposal.Set("currency", defaultProposalCurrency)
+	}
+	proposal.Set("revision", 1)
+	proposal.Set("revised_at", "")
+}
+
+// proposalTermsChanged reports whether an update touches the terms of a
+// proposal rather than only its status.
+func proposalTermsChanged(proposal *models.Record) bool {
+	original := proposal.OriginalCopy()
+	for _, field := range proposalTermsFields {
+		if field == "attachments" {
+			if !slices.Equal(proposal.GetStringSlice(field), original.GetStringSlice(field)) {
+				return true
+			}
+			continue
+		}
+		if proposal.GetString(field) != original.GetString(field) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordProposalRevision stores the previous terms of an edited proposal as
+// a proposal_revisions record and bumps its revision. The attachments are
+// copied so they survive being replaced on the proposal.
+func recordProposalRevision(app core.App, dao *daos.Dao, proposal *models.Record, now time.Time) error {
+	if proposal.IsNew() {
+		return nil
+	}
+	normalizeProposalCurrency(proposal)
+	if !proposalTermsChanged(proposal) {
+		return nil
+	}
+
+	original := proposal.OriginalCopy()
+	number := original.GetInt("revision")
+	if number < 1 {
+		number = 1
+	}
+
+	col, err := dao.FindCollectionByNameOrId("proposal_revisions")
+	if err != nil {
+		return err
+	}
+
+	revision := models.NewRecord(col)
+	revision.RefreshId()
+	revision.Set("proposal_id", proposal.Id)
+	revision.Set("revision", number)
+	for _, field := range proposalTermsFields {
+		revision.Set(field, original.Get(field))
+	}
+	revision.Set("is_deleted", false)
+
+	if err := dao.SaveRecord(revision); err != nil {
+		return err
+	}
+
+	if attachments := original.GetStringSlice("attachments"); len(attachments) > 0 {
+		fs, err := app.NewFilesystem()
+		if err != nil {
+			return err
+		}
+		defer fs.Close()
+
+		for _, name := range attachments {
+			if err := fs.Copy(proposal.BaseFilesPath()+"/"+name, revision.BaseFilesPath()+"/"+name); err != nil {
+				return err
+			}
+		}
+	}
+
+	proposal.Set("revision", number+1)
+	proposal.Set("revised_at", now)
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/forms"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tests"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+)
+
+func submitProposalForm(t *testing.T, app *tests.TestApp, proposal *models.Record, data map[string]any, files []string, removed []string) {
+	t.Helper()
+
+	form := forms.NewRecordUpsert(app, proposal)
+	form.SetFullManageAccess(true)
+	if err := form.LoadData(data); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range files {
+		file, err := filesystem.NewFileFromBytes([]byte("content of "+name), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := form.AddFiles("attachments", file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(removed) > 0 {
+		if err := form.RemoveFiles("attachments", removed...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := form.Submit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProposalRevisions(t *testing.T) {
+	app := newTestApp(t)
+	base := createTestProposal(t, app)
+
+	col, err := app.Dao().FindCollectionByNameOrId("proposals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	freelancer := createTestUser(t, app, "freelancer", nil)
+
+	proposal := models.NewRecord(col)
+	submitProposalForm(t, app, proposal, map[string]any{
+		"project_id":         base.GetString("project_id"),
+		"freelancer_id":      freelancer.Id,
+		"client_id":          base.GetString("client_id"),
+		"message":            "First offer",
+		"bid_amount":         100000,
+		"currency":           "EUR",
+		"estimated_duration": 14,
+		"status":             "sent",
+		"revision":           7,
+	}, []string{"plan.txt"}, nil)
+
+	if proposal.GetInt("revision") != 1 || proposal.GetString("currency") != "eur" {
+		t.Fatalf("unexpected new proposal %v", proposal.PublicExport())
+	}
+	firstAttachment := proposal.GetStringSlice("attachments")[0]
+
+	proposal, err = app.Dao().FindRecordById("proposals", proposal.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	submitProposalForm(t, app, proposal, map[string]any{
+		"message":    "Lower offer",
+		"bid_amount": 80000,
+	}, []string{"plan_v2.txt"}, []string{firstAttachment})
+
+	updated, err := app.Dao().FindRecordById("proposals", proposal.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.GetInt("revision") != 2 || updated.GetDateTime("revised_at").IsZero() {
+		t.Fatalf("expected revision 2, got %v", updated.PublicExport())
+	}
+
+	revisions, err := app.Dao().FindRecordsByFilter("proposal_revisions", "proposal_id = {:id}", "revision", 0, 0, dbx.Params{"id": proposal.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 1 {
+		t.Fatalf("expected 1 revision, got %d", len(revisions))
+	}
+	previous := revisions[0]
+	if previous.GetInt("revision") != 1 || previous.GetString("message") != "First offer" ||
+		previous.GetInt("bid_amount") != 100000 || previous.GetString("currency") != "eur" || previous.GetInt("estimated_duration") != 14 {
+		t.Fatalf("unexpected revision %v", previous.PublicExport())
+	}
+
+	fs, err := app.NewFilesystem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	if names := previous.GetStringSlice("attachments"); len(names) != 1 || names[0] != firstAttachment {
+		t.Fatalf("unexpected revision attachments %v", names)
+	}
+	if exists, err := fs.Exists(previous.BaseFilesPath() + "/" + firstAttachment); err != nil || !exists {
+		t.Fatalf("expected the replaced attachment to be kept with the revision (%v)", err)
+	}
+	if exists, _ := fs.Exists(updated.BaseFilesPath() + "/" + firstAttachment); exists {
+		t.Fatal("expected the replaced attachment to be removed from the proposal")
+	}
+
+	// status changes are not revisions
+	updated.Set("status", "accepted")
+	if err := app.Dao().SaveRecord(updated); err != nil {
+		t.Fatal(err)
+	}
+	if total, _ := app.Dao().FindRecordsByFilter("proposal_revisions", "proposal_id = {:id}", "", 0, 0, dbx.Params{"id": proposal.Id}); len(total) != 1 {
+		t.Fatalf("expected no new revision, got %d", len(total))
+	}
+}
+
+func TestProposalUpdateRule(t *testing.T) {
+	app := newTestApp(t)
+	proposal := createTestProposal(t, app)
+
+	client, err := app.Dao().FindRecordById("users", proposal.GetString("client_id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	freelancer, err := app.Dao().FindRecordById("users", proposal.GetString("freelancer_id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []struct {
+		name     string
+		auth     *models.Record
+		data     map[string]any
+		expected bool
+	}{
+		{"client accepts", client, map[string]any{"status": "accepted"}, false},
+		{"freelancer accepts", freelancer, map[string]any{"status": "accepted"}, false},
+		{"client changes the bid", client, map[string]any{"bid_amount": 1}, false},
+		{"client replaces attachments", client, map[string]any{"attachments": []string{}}, false},
+		{"freelancer changes the bid", freelancer, map[string]any{"bid_amount": 1, "message": "Cheaper"}, true},
+		{"freelancer sets the revision", freelancer, map[string]any{"revision": 9}, false},
+	}
+
+	for _, s := range scenarios {
+		ok, err := app.Dao().CanAccessRecord(proposal, &models.RequestInfo{AuthRecord: s.auth, Data: s.data}, proposal.Collection().UpdateRule)
+		if err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+		if ok != s.expected {
+			t.Fatalf("%s: expected %v, got %v", s.name, s.expected, ok)
+		}
+	}
+}
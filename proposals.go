@@ -0,0 +1,499 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+var (
+	errProposalNotOpen  = errors.New("the proposal is no longer open")
+	errProjectNotOpen   = errors.New("the project is no longer open")
+	errOfferNotYourTurn = errors.New("the latest offer is your own, wait for the other side")
+	errProposalClient   = errors.New("the proposal is not addressed to the client of the project")
+
+	currencyPattern = regexp.MustCompile(`^[a-z]{3}$`)
+)
+
+type proposalAcceptRequest struct {
+	// RejectOthers rejects the other open proposals of the project;
+	// defaults to true.
+	RejectOthers *bool `json:"reject_others"`
+}
+
+type proposalOfferRequest struct {
+	Amount            int64  `json:"amount"`
+	Currency          string `json:"currency"`
+	EstimatedDuration int    `json:"estimated_duration"`
+	Terms             string `json:"terms"`
+}
+
+func registerProposalRoutes(app core.App, router *echo.Echo) {
+	group := router.Group("/proposals", apis.RequireRecordAuth())
+
+	group.POST("/:id/accept", proposalAcceptHandler(app))
+	group.POST("/:id/reject", proposalRejectHandler(app))
+	group.POST("/:id/withdraw", proposalWithdrawHandler(app))
+	group.POST("/:id/offers", proposalOfferHandler(app))
+}
+
+// isOpenProposal reports whether a proposal still awaits a decision.
+func isOpenProposal(proposal *models.Record) bool {
+	status := proposal.GetString("status")
+	return !proposal.GetBool("is_deleted") && (status == "sent" || status == "countered")
+}
+
+// findParticipantProposal loads a proposal for a change by its client or
+// freelancer.
+func findParticipantProposal(app core.App, c echo.Context) (*models.Record, *models.Record, error) {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		return nil, nil, apis.NewUnauthorizedError("unauthorized", nil)
+	}
+
+	proposal, err := app.Dao().FindRecordById("proposals", c.PathParam("id"))
+	if err != nil || proposal.GetBool("is_deleted") {
+		return nil, nil, apis.NewNotFoundError("proposal not found", err)
+	}
+	if record.Id != proposal.GetString("client_id") && record.Id != proposal.GetString("freelancer_id") {
+		return nil, nil, apis.NewForbiddenError("not a participant of this proposal", nil)
+	}
+
+	return proposal, record, nil
+}
+
+// proposalTransitionError maps the errors of a status change to api errors.
+func proposalTransitionError(err error, message string) error {
+	if errors.Is(err, errProposalNotOpen) || errors.Is(err, errProjectNotOpen) || errors.Is(err, errOfferNotYourTurn) ||
+		errors.Is(err, errProposalClient) {
+		return apis.NewApiError(http.StatusConflict, err.Error(), nil)
+	}
+	return apis.NewApiError(http.StatusInternalServerError, message, err)
+}
+
+// findOpenOffer returns the latest offer of a proposal that still awaits an
+// answer, or nil when the proposal's own bid is the latest offer.
+func findOpenOffer(dao *daos.Dao, proposalID string) (*models.Record, error) {
+	offer, err := dao.FindFirstRecordByFilter(
+		"proposal_offers",
+		"proposal_id = {:pid} && status = 'open' && is_deleted = false",
+		dbx.Params{"pid": proposalID},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return offer, err
+}
+
+// latestOfferAuthor returns who made the latest offer; the bid of the
+// proposal counts as an offer of the freelancer. Only the other side may
+// answer it.
+func latestOfferAuthor(proposal *models.Record, offer *models.Record) string {
+	if offer != nil {
+		return offer.GetString("author_id")
+	}
+	return proposal.GetString("freelancer_id")
+}
+
+// closeOpenOffer marks the open offer of a proposal with the given status.
+func closeOpenOffer(dao *daos.Dao, proposalID string, status string) error {
+	offer, err := findOpenOffer(dao, proposalID)
+	if err != nil || offer == nil {
+		return err
+	}
+	offer.Set("status", status)
+	return dao.SaveRecord(offer)
+}
+
+// findOpenProject loads the project of a proposal that is still open for
+// decisions: an open project, or one in progress that hires multiple
+// freelancers because a proposal was accepted without rejecting the others.
+func findOpenProject(dao *daos.Dao, proposal *models.Record) (*models.Record, error) {
+	project, err := dao.FindRecordById("projects", proposal.GetString("project_id"))
+	if err != nil {
+		return nil, err
+	}
+	status := project.GetString("status")
+	hiring := status == "open" || (status == "in_progress" && project.GetBool("hires_multiple"))
+	if project.GetBool("is_deleted") || !hiring {
+		return nil, errProjectNotOpen
+	}
+	return project, nil
+}
+
+// acceptProposal accepts the latest offer of an open proposal on behalf of
+// userID, stores its terms as the agreed terms, moves the project to
+// in_progress and, when rejectOthers is set, rejects the other open
+// proposals of the project; otherwise the project keeps hiring (see
+// findOpenProject). It returns the ids of the rejected proposals. The dao is expected to run in a transaction; the proposal hooks queue the
+// conversation jobs with it.
+func acceptProposal(dao *daos.Dao, proposalID string, userID string, rejectOthers bool) ([]string, error) {
+	proposal, err := dao.FindRecordById("proposals", proposalID)
+	if err != nil {
+		return nil, err
+	}
+	if !isOpenProposal(proposal) {
+		return nil, errProposalNotOpen
+	}
+
+	project, err := findOpenProject(dao, proposal)
+	if err != nil {
+		return nil, err
+	}
+	if proposal.GetString("client_id") != project.GetString("client_id") {
+		return nil, errProposalClient
+	}
+
+	offer, err := findOpenOffer(dao, proposal.Id)
+	if err != nil {
+		return nil, err
+	}
+	if latestOfferAuthor(proposal, offer) == userID {
+		return nil, errOfferNotYourTurn
+	}
+
+	if offer != nil {
+		offer.Set("status", "accepted")
+		if err := dao.SaveRecord(offer); err != nil {
+			return nil, err
+		}
+		proposal.Set("agreed_amount", offer.GetInt("amount"))
+		proposal.Set("agreed_currency", offer.GetString("currency"))
+		proposal.Set("agreed_duration", offer.GetInt("estimated_duration"))
+		proposal.Set("accepted_offer_id", offer.Id)
+	} else {
+		proposal.Set("agreed_amount", proposal.GetInt("bid_amount"))
+		proposal.Set("agreed_currency", proposal.GetString("currency"))
+		proposal.Set("agreed_duration", proposal.GetInt("estimated_duration"))
+		proposal.Set("accepted_offer_id", "")
+	}
+	proposal.Set("status", "accepted")
+	if err := dao.SaveRecord(proposal); err != nil {
+		return nil, err
+	}
+
+	project.Set("status", "in_progress")
+	project.Set("hires_multiple", !rejectOthers)
+	if err := dao.SaveRecord(project); err != nil {
+		return nil, err
+	}
+
+	rejected := []string{}
+	if !rejectOthers {
+		return rejected, nil
+	}
+
+	others, err := dao.FindRecordsByFilter(
+		"proposals",
+		"project_id = {:pid} && id != {:id} && (status = 'sent' || status = 'countered') && is_deleted = false",
+		"",
+		0,
+		0,
+		dbx.Params{"pid": project.Id, "id": proposal.Id},
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range others {
+		if err := closeOpenOffer(dao, other.Id, "declined"); err != nil {
+			return nil, err
+		}
+		other.Set("status", "rejected")
+		if err := dao.SaveRecord(other); err != nil {
+			return nil, err
+		}
+		rejected = append(rejected, other.Id)
+	}
+
+	return rejected, nil
+}
+
+// closeProposal moves an open proposal to rejected or withdrawn and
+// declines its open offer.
+func closeProposal(dao *daos.Dao, proposalID string, status string) error {
+	proposal, err := dao.FindRecordById("proposals", proposalID)
+	if err != nil {
+		return err
+	}
+	if !isOpenProposal(proposal) {
+		return errProposalNotOpen
+	}
+
+	if err := closeOpenOffer(dao, proposal.Id, "declined"); err != nil {
+		return err
+	}
+
+	proposal.Set("status", status)
+	return dao.SaveRecord(proposal)
+}
+
+// createProposalOffer adds a counter-offer of author to an open proposal and
+// supersedes the previous one.
+func createProposalOffer(dao *daos.Dao, proposalID string, author *models.Record, payload proposalOfferRequest) (*models.Record, error) {
+	proposal, err := dao.FindRecordById("proposals", proposalID)
+	if err != nil {
+		return nil, err
+	}
+	if !isOpenProposal(proposal) {
+		return nil, errProposalNotOpen
+	}
+	if _, err := findOpenProject(dao, proposal); err != nil {
+		return nil, err
+	}
+
+	previous, err := findOpenOffer(dao, proposal.Id)
+	if err != nil {
+		return nil, err
+	}
+	if latestOfferAuthor(proposal, previous) == author.Id {
+		return nil, errOfferNotYourTurn
+	}
+	if previous != nil {
+		previous.Set("status", "superseded")
+		if err := dao.SaveRecord(previous); err != nil {
+			return nil, err
+		}
+	}
+
+	col, err := dao.FindCollectionByNameOrId("proposal_offers")
+	if err != nil {
+		return nil, err
+	}
+
+	role := "client"
+	if author.Id == proposal.GetString("freelancer_id") {
+		role = "freelancer"
+	}
+
+	offer := models.NewRecord(col)
+	offer.Set("proposal_id", proposal.Id)
+	offer.Set("author_id", author.Id)
+	offer.Set("author_role", role)
+	offer.Set("amount", payload.Amount)
+	offer.Set("currency", payload.Currency)
+	offer.Set("estimated_duration", payload.EstimatedDuration)
+	offer.Set("terms", payload.Terms)
+	offer.Set("status", "open")
+	offer.Set("is_deleted", false)
+
+	if err := dao.SaveRecord(offer); err != nil {
+		return nil, err
+	}
+
+	if proposal.GetString("status") != "countered" {
+		proposal.Set("status", "countered")
+		if err := dao.SaveRecord(proposal); err != nil {
+			return nil, err
+		}
+	}
+
+	return offer, nil
+}
+
+// findAgreedProposal returns the accepted proposal with agreed terms between
+// a client and a freelancer on a project, or nil when there is none.
+func findAgreedProposal(dao *daos.Dao, projectID string, clientID string, freelancerID string) (*models.Record, error) {
+	proposal, err := dao.FindFirstRecordByFilter(
+		"proposals",
+		"project_id = {:pid} && client_id = {:cid} && freelancer_id = {:fid} && status = 'accepted' && agreed_amount > 0 && is_deleted = false",
+		dbx.Params{"pid": projectID, "cid": clientID, "fid": freelancerID},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return proposal, err
+}
+
+// resolveCheckoutTerms returns the amount and currency a client pays a
+// freelancer on a project. Once a proposal is accepted its agreed terms are
+// binding: an explicit amount or currency must match them. Without an
+// accepted proposal the client's own positive amount is used.
+func resolveCheckoutTerms(dao *daos.Dao, projectID string, clientID string, freelancerID string, amount int64, currency string) (int64, string, error) {
+	currency = strings.ToLower(currency)
+
+	agreed, err := findAgreedProposal(dao, projectID, clientID, freelancerID)
+	if err != nil {
+		return 0, "", apis.NewApiError(http.StatusInternalServerError, "failed to load the agreed terms", err)
+	}
+
+	if agreed == nil {
+		if amount <= 0 {
+			return 0, "", apis.NewBadRequestError("amount must be positive (in cents)", nil)
+		}
+		if currency == "" {
+			currency = "usd"
+		}
+		return amount, currency, nil
+	}
+
+	agreedAmount := int64(agreed.GetInt("agreed_amount"))
+	agreedCurrency := agreed.GetString("agreed_currency")
+	if amount != 0 && amount != agreedAmount {
+		return 0, "", apis.NewBadRequestError("amount does not match the agreed terms of the proposal", nil)
+	}
+	if currency != "" && currency != agreedCurrency {
+		return 0, "", apis.NewBadRequestError("currency does not match the agreed terms of the proposal", nil)
+	}
+
+	return agreedAmount, agreedCurrency, nil
+}
+
+// proposalAcceptHandler is the only way to accept a proposal. The client
+// accepts the bid or a counter-offer of the freelancer, the freelancer
+// accepts a counter-offer of the client.
+func proposalAcceptHandler(app core.App) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		proposal, record, err := findParticipantProposal(app, c)
+		if err != nil {
+			return err
+		}
+
+		var payload proposalAcceptRequest
+		if err := c.Bind(&payload); err != nil {
+			return apis.NewBadRequestError("invalid request body", err)
+		}
+		rejectOthers := payload.RejectOthers == nil || *payload.RejectOthers
+
+		var rejected []string
+		err = app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+			var err error
+			rejected, err = acceptProposal(txDao, proposal.Id, record.Id, rejectOthers)
+			return err
+		})
+		if err != nil {
+			return proposalTransitionError(err, "failed to accept proposal")
+		}
+
+		accepted, err := app.Dao().FindRecordById("proposals", proposal.Id)
+		if err != nil {
+			return apis.NewApiError(http.StatusInternalServerError, "failed to load proposal", err)
+		}
+
+		return c.JSON(http.StatusOK, map[string]any{
+			"proposal_id":           accepted.Id,
+			"status":                accepted.GetString("status"),
+			"agreed_amount":         accepted.GetInt("agreed_amount"),
+			"agreed_currency":       accepted.GetString("agreed_currency"),
+			"agreed_duration":       accepted.GetInt("agreed_duration"),
+			"accepted_offer_id":     accepted.GetString("accepted_offer_id"),
+			"project_id":            accepted.GetString("project_id"),
+			"project_status":        "in_progress",
+			"rejected_proposal_ids": rejected,
+		})
+	}
+}
+
+// proposalRejectHandler lets clients decline an open proposal.
+func proposalRejectHandler(app core.App) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		proposal, record, err := findParticipantProposal(app, c)
+		if err != nil {
+			return err
+		}
+		if record.Id != proposal.GetString("client_id") {
+			return apis.NewForbiddenError("only the client of the project can reject this proposal", nil)
+		}
+
+		err = app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+			return closeProposal(txDao, proposal.Id, "rejected")
+		})
+		if err != nil {
+			return proposalTransitionError(err, "failed to reject proposal")
+		}
+
+		return c.JSON(http.StatusOK, map[string]any{
+			"proposal_id": proposal.Id,
+			"status":      "rejected",
+		})
+	}
+}
+
+// proposalWithdrawHandler lets freelancers take back an open proposal.
+func proposalWithdrawHandler(app core.App) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		proposal, record, err := findParticipantProposal(app, c)
+		if err != nil {
+			return err
+		}
+		if record.Id != proposal.GetString("freelancer_id") {
+			return apis.NewForbiddenError("only the freelancer can withdraw this proposal", nil)
+		}
+
+		err = app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+			return closeProposal(txDao, proposal.Id, "withdrawn")
+		})
+		if err != nil {
+			return proposalTransitionError(err, "failed to withdraw proposal")
+		}
+
+		return c.JSON(http.StatusOK, map[string]any{
+			"proposal_id": proposal.Id,
+			"status":      "withdrawn",
+		})
+	}
+}
+
+// proposalOfferHandler adds a counter-offer to the thread of a proposal.
+// Offers alternate between the client and the freelancer.
+func proposalOfferHandler(app core.App) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		proposal, record, err := findParticipantProposal(app, c)
+		if err != nil {
+			return err
+		}
+
+		var payload proposalOfferRequest
+		if err := c.Bind(&payload); err != nil {
+			return apis.NewBadRequestError("invalid request body", err)
+		}
+		if payload.Amount <= 0 {
+			return apis.NewBadRequestError("amount must be positive (in cents)", nil)
+		}
+		if payload.EstimatedDuration < 0 {
+			return apis.NewBadRequestError("estimated_duration must not be negative", nil)
+		}
+		payload.Currency = strings.ToLower(strings.TrimSpace(payload.Currency))
+		if payload.Currency == "" {
+			payload.Currency = proposal.GetString("currency")
+		}
+		if payload.Currency == "" {
+			payload.Currency = defaultProposalCurrency
+		}
+		if !currencyPattern.MatchString(payload.Currency) {
+			return apis.NewBadRequestError("currency must be a three letter ISO code", nil)
+		}
+		payload.Terms = strings.TrimSpace(payload.Terms)
+
+		var offer *models.Record
+		err = app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+			var err error
+			offer, err = createProposalOffer(txDao, proposal.Id, record, payload)
+			return err
+		})
+		if err != nil {
+			return proposalTransitionError(err, "failed to save offer")
+		}
+
+		return c.JSON(http.StatusCreated, map[string]any{
+			"id":                 offer.Id,
+			"proposal_id":        proposal.Id,
+			"author_id":          record.Id,
+			"author_role":        offer.GetString("author_role"),
+			"amount":             offer.GetInt("amount"),
+			"currency":           offer.GetString("currency"),
+			"estimated_duration": offer.GetInt("estimated_duration"),
+			"terms":              offer.GetString("terms"),
+			"status":             offer.GetString("status"),
+			"created":            offer.GetDateTime("created"),
+		})
+	}
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+type proposalFixture struct {
+	app       *tests.TestApp
+	client    *models.Record
+	proposals []*models.Record
+}
+
+// newProposalFixture creates a project with the given number of sent
+// proposals from different freelancers.
+func newProposalFixture(t *testing.T, count int) *proposalFixture {
+	t.Helper()
+
+	app := newTestApp(t)
+
+	first := createTestProposal(t, app)
+	client, err := app.Dao().FindRecordById("users", first.GetString("client_id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &proposalFixture{app: app, client: client, proposals: []*models.Record{first}}
+	for i := 1; i < count; i++ {
+		freelancer := createTestUser(t, app, "freelancer", nil)
+		f.proposals = append(f.proposals, createTestRecord(t, app, "proposals", map[string]any{
+			"project_id":    first.GetString("project_id"),
+			"freelancer_id": freelancer.Id,
+			"client_id":     client.Id,
+			"message":       "Hello",
+			"status":        "sent",
+			"is_deleted":    false,
+		}))
+	}
+
+	return f
+}
+
+func (f *proposalFixture) status(t *testing.T, collection string, id string) string {
+	t.Helper()
+
+	record, err := f.app.Dao().FindRecordById(collection, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return record.GetString("status")
+}
+
+func (f *proposalFixture) call(t *testing.T, handler echo.HandlerFunc, action string, proposal *models.Record, body string, auth *models.Record) (int, map[string]any) {
+	t.Helper()
+
+	header := http.Header{}
+	if body != "" {
+		header.Set("Content-Type", "application/json")
+	}
+	code, rec := callHandler(t, handler, http.MethodPost, "/proposals/"+proposal.Id+"/"+action, []byte(body), header, auth, echo.PathParam{Name: "id", Value: proposal.Id})
+
+	response := map[string]any{}
+	_ = json.Unmarshal(rec.Body.Bytes(), &response)
+	return code, response
+}
+
+func TestProposalAcceptRejectsCompetitors(t *testing.T) {
+	f := newProposalFixture(t, 3)
+	accepted := f.proposals[0]
+	handler := proposalAcceptHandler(f.app)
+
+	freelancer, err := f.app.Dao().FindRecordById("users", accepted.GetString("freelancer_id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outsider := createTestUser(t, f.app, "client", nil)
+	if code, _ := f.call(t, handler, "accept", accepted, "", outsider); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for another client, got %d", code)
+	}
+	// the bid is the freelancer's own offer
+	if code, _ := f.call(t, handler, "accept", accepted, "", freelancer); code != http.StatusConflict {
+		t.Fatalf("expected 409 for the freelancer, got %d", code)
+	}
+
+	code, response := f.call(t, handler, "accept", accepted, "", f.client)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, response)
+	}
+	if rejected, _ := response["rejected_proposal_ids"].([]any); len(rejected) != 2 {
+		t.Fatalf("expected 2 rejected proposals, got %v", response["rejected_proposal_ids"])
+	}
+
+	if status := f.status(t, "proposals", accepted.Id); status != "accepted" {
+		t.Fatalf("expected accepted, got %q", status)
+	}
+	for _, other := range f.proposals[1:] {
+		if status := f.status(t, "proposals", other.Id); status != "rejected" {
+			t.Fatalf("expected competitor to be rejected, got %q", status)
+		}
+	}
+	if status := f.status(t, "projects", accepted.GetString("project_id")); status != "in_progress" {
+		t.Fatalf("expected project in progress, got %q", status)
+	}
+
+	jobs := findOutboxJobsByType(t, f.app, outboxJobCreateConversation)
+	if len(jobs) != 1 || jobs[0].GetString("reference_id") != accepted.Id {
+		t.Fatalf("expected one create_conversation job, got %d", len(jobs))
+	}
+
+	// the transition can only happen once
+	if code, _ := f.call(t, handler, "accept", accepted, "", f.client); code != http.StatusConflict {
+		t.Fatalf("expected 409 for an accepted proposal, got %d", code)
+	}
+}
+
+func TestProposalAcceptKeepsCompetitors(t *testing.T) {
+	f := newProposalFixture(t, 2)
+
+	code, response := f.call(t, proposalAcceptHandler(f.app), "accept", f.proposals[0], `{"reject_others":false}`, f.client)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, response)
+	}
+	if status := f.status(t, "proposals", f.proposals[1].Id); status != "sent" {
+		t.Fatalf("expected the other proposal to stay sent, got %q", status)
+	}
+
+	// the project keeps hiring, so the kept proposal can still be
+	// negotiated and accepted
+	project, err := f.app.Dao().FindRecordById("projects", f.proposals[0].GetString("project_id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project.GetString("status") != "in_progress" || !project.GetBool("hires_multiple") {
+		t.Fatalf("expected a project in progress that keeps hiring, got %v", project.PublicExport())
+	}
+	if code, _ := f.call(t, proposalOfferHandler(f.app), "offers", f.proposals[1], `{"amount":5000}`, f.client); code != http.StatusCreated {
+		t.Fatalf("expected 201 for a counter-offer, got %d", code)
+	}
+
+	freelancer := mustFindUser(t, f.app, f.proposals[1].GetString("freelancer_id"))
+	code, response = f.call(t, proposalAcceptHandler(f.app), "accept", f.proposals[1], "", freelancer)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200 for the second freelancer, got %d: %v", code, response)
+	}
+	if status := f.status(t, "proposals", f.proposals[1].Id); status != "accepted" {
+		t.Fatalf("expected the second proposal to be accepted, got %q", status)
+	}
+
+	// rejecting the others on that accept ends the hiring
+	project, err = f.app.Dao().FindRecordById("projects", project.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project.GetBool("hires_multiple") {
+		t.Fatal("expected the project to stop hiring")
+	}
+	if jobs := findOutboxJobsByType(t, f.app, outboxJobCreateConversation); len(jobs) != 2 {
+		t.Fatalf("expected a conversation per accepted proposal, got %d", len(jobs))
+	}
+}
+
+func TestProposalAcceptOnProjectInProgress(t *testing.T) {
+	f := newProposalFixture(t, 2)
+
+	project, err := f.app.Dao().FindRecordById("projects", f.proposals[0].GetString("project_id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	project.Set("status", "in_progress")
+	if err := f.app.Dao().SaveRecord(project); err != nil {
+		t.Fatal(err)
+	}
+
+	// only an accept can keep the project hiring
+	if canAccess(t, f.app, project, project.Collection().UpdateRule, f.client, map[string]any{"hires_multiple": true}) {
+		t.Fatal("expected hires_multiple to be read only")
+	}
+
+	// in progress without hires_multiple takes no more decisions
+	if code, _ := f.call(t, proposalAcceptHandler(f.app), "accept", f.proposals[1], "", f.client); code != http.StatusConflict {
+		t.Fatalf("expected 409 once the project is in progress, got %d", code)
+	}
+	if status := f.status(t, "proposals", f.proposals[1].Id); status != "sent" {
+		t.Fatalf("expected a failed accept to change nothing, got %q", status)
+	}
+}
+
+func TestProposalCreateRule(t *testing.T) {
+	app := newTestApp(t)
+	client := createTestUser(t, app, "client", nil)
+	otherClient := createTestUser(t, app, "client", nil)
+	freelancer := createTestUser(t, app, "freelancer", nil)
+	otherFreelancer := createTestUser(t, app, "freelancer", nil)
+	project := createTestProject(t, app, client, "open", "public")
+
+	proposal := createTestProposal(t, app)
+	rule := proposal.Collection().CreateRule
+
+	scenarios := []struct {
+		name     string
+		data     map[string]any
+		expected bool
+	}{
+		{"own proposal to the project client", map[string]any{"freelancer_id": freelancer.Id, "client_id": client.Id}, true},
+		{"on behalf of another freelancer", map[string]any{"freelancer_id": otherFreelancer.Id, "client_id": client.Id}, false},
+		{"addressed to another client", map[string]any{"freelancer_id": freelancer.Id, "client_id": otherClient.Id}, false},
+	}
+
+	for _, s := range scenarios {
+		data := map[string]any{"project_id": project.Id, "status": "sent"}
+		for key, value := range s.data {
+			data[key] = value
+		}
+		if ok := canAccess(t, app, proposal, rule, freelancer, data); ok != s.expected {
+			t.Fatalf("%s: expected %v, got %v", s.name, s.expected, ok)
+		}
+	}
+}
+
+func TestProposalUpdateRuleKeepsParties(t *testing.T) {
+	app := newTestApp(t)
+	proposal := createTestProposal(t, app)
+	rule := proposal.Collection().UpdateRule
+
+	client := mustFindUser(t, app, proposal.GetString("client_id"))
+	freelancer := mustFindUser(t, app, proposal.GetString("freelancer_id"))
+	otherClient := createTestUser(t, app, "client", nil)
+	otherFreelancer := createTestUser(t, app, "freelancer", nil)
+	otherProject := createTestProject(t, app, otherClient, "open", "public")
+
+	scenarios := []struct {
+		name string
+		auth *models.Record
+		data map[string]any
+	}{
+		{"client hands it to another freelancer", client, map[string]any{"freelancer_id": otherFreelancer.Id}},
+		{"client moves it to another project", client, map[string]any{"project_id": otherProject.Id}},
+		{"client re-points the client", client, map[string]any{"client_id": otherClient.Id}},
+		{"freelancer hands it to another freelancer", freelancer, map[string]any{"freelancer_id": otherFreelancer.Id}},
+		{"freelancer moves it to another project", freelancer, map[string]any{"project_id": otherProject.Id}},
+		{"freelancer re-points the client", freelancer, map[string]any{"client_id": otherClient.Id}},
+	}
+
+	for _, s := range scenarios {
+		if canAccess(t, app, proposal, rule, s.auth, s.data) {
+			t.Fatalf("%s: expected the update to be denied", s.name)
+		}
+	}
+
+	if !canAccess(t, app, proposal, rule, freelancer, map[string]any{"message": "Updated scope"}) {
+		t.Fatal("expected the freelancer to still edit the message")
+	}
+}
+
+func TestProposalAcceptChecksProjectClient(t *testing.T) {
+	f := newProposalFixture(t, 1)
+	proposal := f.proposals[0]
+
+	// a proposal stored before the create rule tied it to the project
+	// client must not let another client take over the project
+	intruder := createTestUser(t, f.app, "client", nil)
+	proposal.Set("client_id", intruder.Id)
+	if err := f.app.Dao().WithoutHooks().SaveRecord(proposal); err != nil {
+		t.Fatal(err)
+	}
+
+	if code, _ := f.call(t, proposalAcceptHandler(f.app), "accept", proposal, "", intruder); code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", code)
+	}
+	if status := f.status(t, "projects", proposal.GetString("project_id")); status != "open" {
+		t.Fatalf("expected the project to stay open, got %q", status)
+	}
+}
+
+func TestProposalReject(t *testing.T) {
+	f := newProposalFixture(t, 1)
+	handler := proposalRejectHandler(f.app)
+
+	outsider := createTestUser(t, f.app, "client", nil)
+	if code, _ := f.call(t, handler, "reject", f.proposals[0], "", outsider); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for another client, got %d", code)
+	}
+
+	if code, _ := f.call(t, handler, "reject", f.proposals[0], "", f.client); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if status := f.status(t, "proposals", f.proposals[0].Id); status != "rejected" {
+		t.Fatalf("expected rejected, got %q", status)
+	}
+
+	if code, _ := f.call(t, handler, "reject", f.proposals[0], "", f.client); code != http.StatusConflict {
+		t.Fatalf("expected 409 for a rejected proposal, got %d", code)
+	}
+	if code, _ := f.call(t, proposalAcceptHandler(f.app), "accept", f.proposals[0], "", f.client); code != http.StatusConflict {
+		t.Fatalf("expected 409 when accepting a rejected proposal, got %d", code)
+	}
+}
+
+func TestProposalCounterOffers(t *testing.T) {
+	f := newProposalFixture(t, 1)
+	proposal := f.proposals[0]
+	proposal.Set("bid_amount", 100000)
+	proposal.Set("currency", "eur")
+	if err := f.app.Dao().SaveRecord(proposal); err != nil {
+		t.Fatal(err)
+	}
+
+	freelancer, err := f.app.Dao().FindRecordById("users", proposal.GetString("freelancer_id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	offers := proposalOfferHandler(f.app)
+
+	// the freelancer answers the client, not their own bid
+	if code, _ := f.call(t, offers, "offers", proposal, `{"amount":90000}`, freelancer); code != http.StatusConflict {
+		t.Fatalf("expected 409 for a freelancer offer on their own bid, got %d", code)
+	}
+	if code, _ := f.call(t, offers, "offers", proposal, `{"amount":0}`, f.client); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without an amount, got %d", code)
+	}
+
+	code, first := f.call(t, offers, "offers", proposal, `{"amount":70000,"terms":"Without the admin panel"}`, f.client)
+	if code != http.StatusCreated || first["currency"] != "eur" || first["author_role"] != "client" {
+		t.Fatalf("expected the client offer, got %d: %v", code, first)
+	}
+	if status := f.status(t, "proposals", proposal.Id); status != "countered" {
+		t.Fatalf("expected countered, got %q", status)
+	}
+	if code, _ := f.call(t, proposalAcceptHandler(f.app), "accept", proposal, "", f.client); code != http.StatusConflict {
+		t.Fatalf("expected 409 when the client accepts their own offer, got %d", code)
+	}
+
+	code, second := f.call(t, offers, "offers", proposal, `{"amount":80000,"estimated_duration":10}`, freelancer)
+	if code != http.StatusCreated {
+		t.Fatalf("expected the freelancer offer, got %d: %v", code, second)
+	}
+	if status := f.status(t, "proposal_offers", first["id"].(string)); status != "superseded" {
+		t.Fatalf("expected the first offer to be superseded, got %q", status)
+	}
+
+	code, response := f.call(t, proposalAcceptHandler(f.app), "accept", proposal, "", f.client)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, response)
+	}
+	if status := f.status(t, "proposal_offers", second["id"].(string)); status != "accepted" {
+		t.Fatalf("expected the latest offer to be accepted, got %q", status)
+	}
+
+	agreed, err := findAgreedProposal(f.app.Dao(), proposal.GetString("project_id"), f.client.Id, freelancer.Id)
+	if err != nil || agreed == nil {
+		t.Fatalf("expected agreed terms (%v)", err)
+	}
+	if agreed.GetInt("agreed_amount") != 80000 || agreed.GetString("agreed_currency") != "eur" ||
+		agreed.GetInt("agreed_duration") != 10 || agreed.GetString("accepted_offer_id") != second["id"] {
+		t.Fatalf("unexpected agreed terms %v", agreed.PublicExport())
+	}
+
+	if code, _ := f.call(t, offers, "offers", proposal, `{"amount":1000}`, freelancer); code != http.StatusConflict {
+		t.Fatalf("expected 409 for an offer on an accepted proposal, got %d", code)
+	}
+}
+
+func TestResolveCheckoutTerms(t *testing.T) {
+	f := newProposalFixture(t, 2)
+	proposal := f.proposals[0]
+	proposal.Set("bid_amount", 50000)
+	proposal.Set("currency", "eur")
+	if err := f.app.Dao().SaveRecord(proposal); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := f.call(t, proposalAcceptHandler(f.app), "accept", proposal, `{"reject_others":false}`, f.client); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+
+	projectID := proposal.GetString("project_id")
+	agreedFreelancer := proposal.GetString("freelancer_id")
+	otherFreelancer := f.proposals[1].GetString("freelancer_id")
+
+	scenarios := []struct {
+		name             string
+		freelancerID     string
+		amount           int64
+		currency         string
+		expectedAmount   int64
+		expectedCurrency string
+		expectErr        bool
+	}{
+		{"agreed terms by default", agreedFreelancer, 0, "", 50000, "eur", false},
+		{"matching explicit terms", agreedFreelancer, 50000, "EUR", 50000, "eur", false},
+		{"different amount", agreedFreelancer, 1000, "", 0, "", true},
+		{"different currency", agreedFreelancer, 0, "usd", 0, "", true},
+		{"no agreement and no amount", otherFreelancer, 0, "", 0, "", true},
+		{"no agreement", otherFreelancer, 2500, "", 2500, "usd", false},
+	}
+
+	for _, s := range scenarios {
+		amount, currency, err := resolveCheckoutTerms(f.app.Dao(), projectID, f.client.Id, s.freelancerID, s.amount, s.currency)
+		if (err != nil) != s.expectErr {
+			t.Fatalf("%s: unexpected error %v", s.name, err)
+		}
+		if amount != s.expectedAmount || currency != s.expectedCurrency {
+			t.Fatalf("%s: expected %d %s, got %d %s", s.name, s.expectedAmount, s.expectedCurrency, amount, currency)
+		}
+	}
+}
+
+func TestProposalWithdraw(t *testing.T) {
+	f := newProposalFixture(t, 1)
+	proposal := f.proposals[0]
+	handler := proposalWithdrawHandler(f.app)
+
+	code, offer := f.call(t, proposalOfferHandler(f.app), "offers", proposal, `{"amount":5000}`, f.client)
+	if code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", code)
+	}
+
+	if code, _ := f.call(t, handler, "withdraw", proposal, "", f.client); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for the client, got %d", code)
+	}
+
+	freelancer, err := f.app.Dao().FindRecordById("users", proposal.GetString("freelancer_id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := f.call(t, handler, "withdraw", proposal, "", freelancer); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if status := f.status(t, "proposals", proposal.Id); status != "withdrawn" {
+		t.Fatalf("expected withdrawn, got %q", status)
+	}
+	if status := f.status(t, "proposal_offers", offer["id"].(string)); status != "declined" {
+		t.Fatalf("expected the open offer to be declined, got %q", status)
+	}
+
+	if code, _ := f.call(t, handler, "withdraw", proposal, "", freelancer); code != http.StatusConflict {
+		t.Fatalf("expected 409 for a withdrawn proposal, got %d", code)
+	}
+}
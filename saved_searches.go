@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/mail"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/mailer"
+)
+
+const (
+	outboxJobMatchSavedSearches = "match_saved_searches"
+	outboxJobSendSearchAlerts   = "send_search_alerts"
+
+	defaultSavedSearchFrequency   = "instant"
+	defaultSearchAlertDigestHour  = 8
+	maxSavedSearchesPerFreelancer = 20
+	// maxSearchAlertEmailProjects caps the projects listed in one email; the
+	// rest are still marked as notified and listed in search_alerts.
+	maxSearchAlertEmailProjects = 50
+)
+
+type savedSearchConfig struct {
+	// DigestHour is the UTC hour the daily digests are sent at.
+	DigestHour int
+}
+
+func loadSavedSearchConfig() (savedSearchConfig, error) {
+	cfg := savedSearchConfig{DigestHour: defaultSearchAlertDigestHour}
+
+	if value := strings.TrimSpace(os.Getenv("SEARCH_ALERT_DIGEST_HOUR")); value != "" {
+		hour, err := strconv.Atoi(value)
+		if err != nil || hour < 0 || hour > 23 {
+			return savedSearchConfig{}, errors.New("SEARCH_ALERT_DIGEST_HOUR must be an hour between 0 and 23")
+		}
+		cfg.DigestHour = hour
+	}
+
+	return cfg, nil
+}
+
+// nextDigest returns the first digest time after now.
+func (cfg savedSearchConfig) nextDigest(now time.Time) time.Time {
+	now = now.UTC()
+	next := time.Date(now.Year(), now.Month(), now.Day(), cfg.DigestHour, 0, 0, 0, time.UTC)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// prepareSavedSearch fills in the defaults of a saved search and checks the
+// constraints the collection schema cannot express.
+func prepareSavedSearch(dao *daos.Dao, search *models.Record) error {
+	if search.GetString("frequency") == "" {
+		search.Set("frequency", defaultSavedSearchFrequency)
+	}
+
+	errs := validation.Errors{}
+
+	min, max := search.GetInt("budget_min"), search.GetInt("budget_max")
+	if max > 0 && min > max {
+		errs["budget_max"] = validation.NewError("validation_budget_range", "Must not be lower than budget_min.")
+	}
+
+	if search.IsNew() {
+		var count int
+		err := dao.DB().
+			Select("count(*)").
+			From("saved_searches").
+			Where(dbx.HashExp{"freelancer_id": search.GetString("freelancer_id"), "is_deleted": false}).
+			Row(&count)
+		if err != nil {
+			return err
+		}
+		if count >= maxSavedSearchesPerFreelancer {
+			errs["freelancer_id"] = validation.NewError(
+				"validation_saved_search_limit",
+				fmt.Sprintf("At most %d saved searches are allowed.", maxSavedSearchesPerFreelancer),
+			)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// enqueueSavedSearchMatching queues the matching of a project against the
+// saved searches when it is created open or reopened.
+func enqueueSavedSearchMatching(dao *daos.Dao, project *models.Record) error {
+	if project.GetBool("is_deleted") || project.GetString("status") != "open" {
+		return nil
+	}
+	if !project.IsNew() && project.OriginalCopy().GetString("status") == "open" {
+		return nil
+	}
+
+	return enqueueOutboxJob(dao, outboxJobMatchSavedSearches, project.Id, nil)
+}
+
+// searchWords splits a text into lowercased words.
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// savedSearchMatches reports whether a project meets the criteria of a
+// saved search. As in /projects/search the budget ranges must overlap and
+// one of the skills is enough; every keyword must start a word of the title
+// or description.
+func savedSearchMatches(search *models.Record, project *models.Record) bool {
+	if searchType := search.GetString("type"); searchType != "" && searchType != project.GetString("type") {
+		return false
+	}
+
+	if min := search.GetInt("budget_min"); min > 0 {
+		if max := project.GetInt("budget_max"); max > 0 && max < min {
+			return false
+		}
+	}
+	if max := search.GetInt("budget_max"); max > 0 && project.GetInt("budget_min") > max {
+		return false
+	}
+
+	if skills := search.GetStringSlice("skills"); len(skills) > 0 {
+		required := project.GetStringSlice("skills")
+		if !slices.ContainsFunc(skills, func(skill string) bool { return slices.Contains(required, skill) }) {
+			return false
+		}
+	}
+
+	words := searchWords(project.GetString("title") + " " + project.GetString("description"))
+	for _, keyword := range searchWords(search.GetString("keywords")) {
+		if !slices.ContainsFunc(words, func(word string) bool { return strings.HasPrefix(word, keyword) }) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// handleMatchSavedSearches is the outbox handler that records a search alert
+// for every saved search matching a new or reopened project, once per
+// opening, and schedules the email: right away for instant searches, at the
+// next digest for daily ones. Freelancers are only alerted about projects the
+// projects ListRule lets them see.
+func handleMatchSavedSearches(app core.App, cfg savedSearchConfig, job *models.Record, now time.Time) error {
+	project, err := app.Dao().FindRecordById("projects", job.GetString("reference_id"))
+	if err != nil {
+		return err
+	}
+	if project.GetBool("is_deleted") || project.GetString("status") != "open" {
+		return errOutboxJobCancelled
+	}
+	// the job is queued in the transaction that opens the project, so its
+	// creation time identifies this opening
+	openedAt := job.Created
+
+	searches, err := app.Dao().FindRecordsByFilter(
+		"saved_searches",
+		"is_deleted = false && freelancer_id.is_deleted = false && freelancer_id != {:client} && (type = '' || type = {:type})",
+		"created",
+		0,
+		0,
+		dbx.Params{"client": project.GetString("client_id"), "type": project.GetString("type")},
+	)
+	if err != nil {
+		return err
+	}
+
+	return app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		for _, search := range searches {
+			if !savedSearchMatches(search, project) {
+				continue
+			}
+
+			freelancer, err := txDao.FindRecordById("users", search.GetString("freelancer_id"))
+			if err != nil {
+				return err
+			}
+			visible, err := txDao.CanAccessRecord(project, &models.RequestInfo{AuthRecord: freelancer}, project.Collection().ListRule)
+			if err != nil {
+				return err
+			}
+			if !visible {
+				continue
+			}
+
+			// every time the project is opened counts as a new announcement,
+			// a retried job of the same opening does not
+			_, err = txDao.FindFirstRecordByFilter(
+				"search_alerts",
+				"saved_search_id = {:search} && project_id = {:project} && project_opened_at = {:opened}",
+				dbx.Params{"search": search.Id, "project": project.Id, "opened": openedAt.String()},
+			)
+			if err == nil {
+				continue
+			}
+			if !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+
+			col, err := txDao.FindCollectionByNameOrId("search_alerts")
+			if err != nil {
+				return err
+			}
+			alert := models.NewRecord(col)
+			alert.Set("saved_search_id", search.Id)
+			alert.Set("freelancer_id", freelancer.Id)
+			alert.Set("project_id", project.Id)
+			alert.Set("project_opened_at", openedAt)
+			alert.Set("is_deleted", false)
+			if err := txDao.SaveRecord(alert); err != nil {
+				return err
+			}
+
+			runAt := now
+			if search.GetString("frequency") == "daily" {
+				runAt = cfg.nextDigest(now)
+			}
+			if err := enqueueOutboxJobAt(txDao, outboxJobSendSearchAlerts, search.Id, nil, runAt); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+var searchAlertEmail = template.Must(template.New("search_alert").Parse(
+	`<p>Hello {{.Name}},</p>
+<p>{{if eq (len .Projects) 1}}A new project matches{{else}}{{len .Projects}} new projects match{{end}} your saved search "{{.Search}}":</p>
+<ul>
+{{range .Projects}}<li><strong>{{.Title}}</strong> ({{.Type}}{{if .Budget}}, {{.Budget}}{{end}})</li>
+{{end}}</ul>`,
+))
+
+type searchAlertProject struct {
+	Title  string
+	Type   string
+	Budget string
+}
+
+// searchAlertBudget formats the budget range of a project for an email.
+func searchAlertBudget(project *models.Record) string {
+	currency := strings.ToUpper(project.GetString("currency"))
+	min, max := int64(project.GetInt("budget_min")), int64(project.GetInt("budget_max"))
+
+	switch {
+	case min > 0 && max > 0:
+		return formatMinorUnits(min, currency) + "–" + formatMinorUnits(max, currency) + " " + currency
+	case max > 0:
+		return "up to " + formatMinorUnits(max, currency) + " " + currency
+	case min > 0:
+		return "from " + formatMinorUnits(min, currency) + " " + currency
+	}
+	return ""
+}
+
+// handleSendSearchAlerts is the outbox handler that emails the pending alerts
+// of a saved search, as a single message, and marks them as notified.
+// Projects that were closed or deleted in the meantime are left out.
+func handleSendSearchAlerts(app core.App, job *models.Record, now time.Time) error {
+	search, err := app.Dao().FindRecordById("saved_searches", job.GetString("reference_id"))
+	if err != nil {
+		return err
+	}
+	if search.GetBool("is_deleted") {
+		return errOutboxJobCancelled
+	}
+
+	freelancer, err := app.Dao().FindRecordById("users", search.GetString("freelancer_id"))
+	if err != nil {
+		return err
+	}
+	if freelancer.GetBool("is_deleted") || freelancer.Email() == "" {
+		return errOutboxJobCancelled
+	}
+
+	alerts, err := app.Dao().FindRecordsByFilter(
+		"search_alerts",
+		"saved_search_id = {:search} && notified_at = '' && is_deleted = false",
+		"created",
+		0,
+		0,
+		dbx.Params{"search": search.Id},
+	)
+	if err != nil {
+		return err
+	}
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	projects := []searchAlertProject{}
+	for _, alert := range alerts {
+		project, err := app.Dao().FindRecordById("projects", alert.GetString("project_id"))
+		if err != nil {
+			return err
+		}
+		if project.GetBool("is_deleted") || project.GetString("status") != "open" || len(projects) >= maxSearchAlertEmailProjects {
+			continue
+		}
+		projects = append(projects, searchAlertProject{
+			Title:  project.GetString("title"),
+			Type:   project.GetString("type"),
+			Budget: searchAlertBudget(project),
+		})
+	}
+
+	if len(projects) > 0 {
+		var body bytes.Buffer
+		err := searchAlertEmail.Execute(&body, map[string]any{
+			"Name":     freelancer.GetString("name"),
+			"Search":   search.GetString("name"),
+			"Projects": projects,
+		})
+		if err != nil {
+			return err
+		}
+
+		subject := fmt.Sprintf("New project for %q", search.GetString("name"))
+		if len(projects) > 1 {
+			subject = fmt.Sprintf("%d new projects for %q", len(projects), search.GetString("name"))
+		}
+
+		meta := app.Settings().Meta
+		err = app.NewMailClient().Send(&mailer.Message{
+			From:    mail.Address{Name: meta.SenderName, Address: meta.SenderAddress},
+			To:      []mail.Address{{Name: freelancer.GetString("name"), Address: freelancer.Email()}},
+			Subject: subject,
+			HTML:    body.String(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		for _, alert := range alerts {
+			alert.Set("notified_at", now)
+			if err := txDao.SaveRecord(alert); err != nil {
+				return err
+			}
+		}
+		search.Set("last_notified_at", now)
+		return txDao.SaveRecord(search)
+	})
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+func TestSavedSearchMatches(t *testing.T) {
+	app := newTestApp(t)
+	searches, err := app.Dao().FindCollectionByNameOrId("saved_searches")
+	if err != nil {
+		t.Fatal(err)
+	}
+	projects, err := app.Dao().FindCollectionByNameOrId("projects")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	project := models.NewRecord(projects)
+	project.Set("title", "React Native app")
+	project.Set("description", "Ship the iOS client")
+	project.Set("type", "remote")
+	project.Set("budget_min", 100000)
+	project.Set("budget_max", 300000)
+	project.Set("skills", []string{"skill_mobile", "skill_js"})
+
+	scenarios := []struct {
+		name     string
+		criteria map[string]any
+		expected bool
+	}{
+		{"no criteria", map[string]any{}, true},
+		{"keyword prefixes", map[string]any{"keywords": "reac IOS"}, true},
+		{"missing keyword", map[string]any{"keywords": "react android"}, false},
+		{"type", map[string]any{"type": "onsite"}, false},
+		{"budget overlap", map[string]any{"budget_min": 200000, "budget_max": 900000}, true},
+		{"budget too high", map[string]any{"budget_min": 400000}, false},
+		{"budget too low", map[string]any{"budget_max": 50000}, false},
+		{"any skill", map[string]any{"skills": []string{"skill_go", "skill_js"}}, true},
+		{"other skills", map[string]any{"skills": []string{"skill_go"}}, false},
+	}
+	for _, s := range scenarios {
+		search := models.NewRecord(searches)
+		for key, value := range s.criteria {
+			search.Set(key, value)
+		}
+		if matched := savedSearchMatches(search, project); matched != s.expected {
+			t.Fatalf("%s: expected %v, got %v", s.name, s.expected, matched)
+		}
+	}
+}
+
+func findSearchAlertJobs(t *testing.T, app *tests.TestApp, jobType string) []*models.Record {
+	t.Helper()
+
+	jobs := []*models.Record{}
+	for _, job := range findOutboxJobsByType(t, app, jobType) {
+		if job.GetString("status") == "pending" {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
+
+func TestSearchAlerts(t *testing.T) {
+	app := newTestApp(t)
+
+	cfg := savedSearchConfig{DigestHour: 8}
+	// late enough for the jobs enqueued below to be due
+	now := time.Now().Add(time.Minute)
+	worker := newOutboxWorker(app, map[string]outboxHandler{
+		outboxJobMatchSavedSearches: func(app core.App, job *models.Record) error {
+			return handleMatchSavedSearches(app, cfg, job, now)
+		},
+		outboxJobSendSearchAlerts: func(app core.App, job *models.Record) error {
+			return handleSendSearchAlerts(app, job, now)
+		},
+	})
+
+	client := createTestUser(t, app, "client", nil)
+	instant := createTestRecord(t, app, "saved_searches", map[string]any{
+		"freelancer_id": createTestUser(t, app, "freelancer", nil).Id,
+		"name":          "React",
+		"keywords":      "react",
+	})
+	daily := createTestRecord(t, app, "saved_searches", map[string]any{
+		"freelancer_id": createTestUser(t, app, "freelancer", nil).Id,
+		"name":          "Remote work",
+		"type":          "remote",
+		"frequency":     "daily",
+	})
+	unrelated := createTestRecord(t, app, "saved_searches", map[string]any{
+		"freelancer_id": createTestUser(t, app, "freelancer", nil).Id,
+		"name":          "Design",
+		"keywords":      "logo",
+	})
+	if instant.GetString("frequency") != "instant" {
+		t.Fatalf("expected instant by default, got %q", instant.GetString("frequency"))
+	}
+
+	project := createTestRecord(t, app, "projects", map[string]any{
+		"title":       "React dashboard",
+		"description": "Charts and tables",
+		"type":        "remote",
+		"client_id":   client.Id,
+		"status":      "open",
+		"is_deleted":  false,
+	})
+	// invite-only projects are only announced to invitees
+	createTestRecord(t, app, "projects", map[string]any{
+		"title":       "React private",
+		"description": "Hidden",
+		"type":        "remote",
+		"client_id":   client.Id,
+		"status":      "open",
+		"visibility":  "invite_only",
+		"is_deleted":  false,
+	})
+
+	if jobs := findSearchAlertJobs(t, app, outboxJobMatchSavedSearches); len(jobs) != 2 {
+		t.Fatalf("expected 2 match jobs, got %d", len(jobs))
+	}
+	if err := worker.RunOnce(now); err != nil {
+		t.Fatal(err)
+	}
+
+	alerts, err := app.Dao().FindRecordsByExpr("search_alerts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 2 {
+		t.Fatalf("expected alerts for the instant and daily searches, got %d", len(alerts))
+	}
+	for _, alert := range alerts {
+		if alert.GetString("project_id") != project.Id || alert.GetString("saved_search_id") == unrelated.Id {
+			t.Fatalf("unexpected alert %v", alert.PublicExport())
+		}
+	}
+
+	digest := cfg.nextDigest(now)
+	for _, job := range findSearchAlertJobs(t, app, outboxJobSendSearchAlerts) {
+		if job.GetString("reference_id") == daily.Id && !job.GetDateTime("next_attempt_at").Time().Equal(digest) {
+			t.Fatalf("expected the daily digest at %v, got %v", digest, job.GetDateTime("next_attempt_at"))
+		}
+	}
+
+	if err := worker.RunOnce(now); err != nil {
+		t.Fatal(err)
+	}
+	if app.TestMailer.TotalSend != 1 || !strings.Contains(app.TestMailer.LastMessage.HTML, "React dashboard") {
+		t.Fatalf("expected the instant email, got %d: %q", app.TestMailer.TotalSend, app.TestMailer.LastMessage.HTML)
+	}
+
+	if err := worker.RunOnce(digest); err != nil {
+		t.Fatal(err)
+	}
+	if app.TestMailer.TotalSend != 2 {
+		t.Fatalf("expected the daily digest to be sent, got %d emails", app.TestMailer.TotalSend)
+	}
+
+	pending, err := app.Dao().FindRecordsByFilter("search_alerts", "notified_at = ''", "", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("expected every alert to be notified, got %d pending", len(pending))
+	}
+
+	// every reopening announces the project again, once per search
+	project, err = app.Dao().FindRecordById("projects", project.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	project.Set("status", "in_progress")
+	if err := app.Dao().SaveRecord(project); err != nil {
+		t.Fatal(err)
+	}
+	project, err = app.Dao().FindRecordById("projects", project.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	project.Set("status", "open")
+	if err := app.Dao().SaveRecord(project); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs := findSearchAlertJobs(t, app, outboxJobMatchSavedSearches)
+	if len(jobs) != 1 {
+		t.Fatalf("expected a match job for the reopened project, got %d", len(jobs))
+	}
+	if err := worker.RunOnce(now); err != nil {
+		t.Fatal(err)
+	}
+	if alerts, _ := app.Dao().FindRecordsByExpr("search_alerts"); len(alerts) != 4 {
+		t.Fatalf("expected new alerts for the reopening, got %d", len(alerts))
+	}
+
+	// a retry of the same job belongs to the same opening
+	if err := handleMatchSavedSearches(app, cfg, jobs[0], now); err != nil {
+		t.Fatal(err)
+	}
+	if alerts, _ := app.Dao().FindRecordsByExpr("search_alerts"); len(alerts) != 4 {
+		t.Fatalf("expected no duplicate alerts for a retried job, got %d", len(alerts))
+	}
+
+	if err := worker.RunOnce(now); err != nil {
+		t.Fatal(err)
+	}
+	if app.TestMailer.TotalSend != 3 {
+		t.Fatalf("expected the reopened project to be emailed again, got %d emails", app.TestMailer.TotalSend)
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+const chatPreviewMaxRunes = 120
+
+type streamWebhookUser struct {
+	ID string `json:"id"`
+}
+
+type streamWebhookMessage struct {
+	ID            string             `json:"id"`
+	Text          string             `json:"text"`
+	Type          string             `json:"type"`
+	ParentID      string             `json:"parent_id"`
+	ShowInChannel bool               `json:"show_in_channel"`
+	Attachments   []json.RawMessage  `json:"attachments"`
+	User          *streamWebhookUser `json:"user"`
+	CreatedAt     time.Time          `json:"created_at"`
+}
+
+// streamWebhookEvent is the subset of a Stream webhook payload we use.
+type streamWebhookEvent struct {
+	Type      string                `json:"type"`
+	CID       string                `json:"cid"`
+	ChannelID string                `json:"channel_id"`
+	User      *streamWebhookUser    `json:"user"`
+	Message   *streamWebhookMessage `json:"message"`
+	CreatedAt time.Time             `json:"created_at"`
+}
+
+func (e streamWebhookEvent) channelID() string {
+	if e.ChannelID != "" {
+		return e.ChannelID
+	}
+	if _, id, ok := strings.Cut(e.CID, ":"); ok {
+		return id
+	}
+	return ""
+}
+
+func (e streamWebhookEvent) senderID() string {
+	if e.Message != nil && e.Message.User != nil {
+		return e.Message.User.ID
+	}
+	if e.User != nil {
+		return e.User.ID
+	}
+	return ""
+}
+
+// verifyStreamWebhookSignature checks the X-Signature header, a hex encoded
+// HMAC-SHA256 of the raw body keyed with the Stream API secret.
+func verifyStreamWebhookSignature(secret string, body []byte, signature string) bool {
+	if secret == "" || signature == "" {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(strings.TrimSpace(signature))))
+}
+
+func streamWebhookHandler(app core.App, secret string, inquiryCfg chatInquiryConfig) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		if secret == "" {
+			return apis.NewApiError(http.StatusServiceUnavailable, "stream webhooks are not configured", nil)
+		}
+
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return apis.NewApiError(http.StatusBadRequest, "invalid payload", err)
+		}
+
+		if !verifyStreamWebhookSignature(secret, body, c.Request().Header.Get("X-Signature")) {
+			return apis.NewApiError(http.StatusUnauthorized, "invalid signature", nil)
+		}
+
+		var event streamWebhookEvent
+		payloadErr := json.Unmarshal(body, &event)
+
+		channelID := event.channelID()
+		delivery, err := beginWebhookDelivery(app, webhookDelivery{
+			Provider:        "stream",
+			Body:            body,
+			EventType:       event.Type,
+			ReferenceID:     channelID,
+			SignatureScheme: "hmac_sha256",
+		})
+		if err != nil {
+			if errors.Is(err, errDuplicateWebhookDelivery) {
+				// Stream retries anything but a 2xx, so duplicates are acknowledged.
+				log.Printf("stream webhook duplicate channel=%s type=%s", channelID, event.Type)
+				return c.JSON(http.StatusOK, map[string]string{"message": "Duplicate webhook ignored"})
+			}
+			return apis.NewApiError(http.StatusInternalServerError, "failed to store webhook delivery", err)
+		}
+
+		processed := func(outcome string, cause error) error {
+			if err := finishWebhookDelivery(app, delivery, outcome, cause); err != nil {
+				log.Printf("stream webhook failed to store outcome channel=%s err=%v", channelID, err)
+			}
+			log.Printf("stream webhook processed channel=%s type=%s outcome=%s", channelID, event.Type, outcome)
+			return c.JSON(http.StatusOK, map[string]string{"message": "Webhook processed"})
+		}
+
+		if payloadErr != nil {
+			_ = finishWebhookDelivery(app, delivery, webhookOutcomeInvalidPayload, payloadErr)
+			return apis.NewApiError(http.StatusBadRequest, "invalid payload", payloadErr)
+		}
+
+		var apply func(dao *daos.Dao, conversation *models.Record) (bool, error)
+		switch event.Type {
+		case "message.new":
+			apply = func(dao *daos.Dao, conversation *models.Record) (bool, error) {
+				return applyStreamMessageNew(dao, inquiryCfg, conversation, event)
+			}
+		case "message.read":
+			apply = func(dao *daos.Dao, conversation *models.Record) (bool, error) {
+				return applyStreamMessageRead(conversation, event), nil
+			}
+		case "channel.deleted":
+			apply = func(dao *daos.Dao, conversation *models.Record) (bool, error) {
+				return applyStreamChannelDeleted(conversation, event), nil
+			}
+		default:
+			return processed(webhookOutcomeIgnored, nil)
+		}
+
+		if channelID == "" {
+			return processed(webhookOutcomeIgnored, nil)
+		}
+
+		outcome := webhookOutcomeProcessed
+		err = app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+			conversation, err := txDao.FindFirstRecordByData("conversations", "stream_channel_id", channelID)
+			if err != nil {
+				return err
+			}
+
+			changed, err := apply(txDao, conversation)
+			if err != nil || !changed {
+				outcome = webhookOutcomeIgnored
+				return err
+			}
+
+			return txDao.SaveRecord(conversation)
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return processed(webhookOutcomeConversationNotFound, nil)
+			}
+			// Stream retries on a 5xx, and the retry is not treated as a
+			// duplicate since the delivery is marked as failed
+			if err := finishWebhookDelivery(app, delivery, webhookOutcomeSaveFailed, err); err != nil {
+				log.Printf("stream webhook failed to store outcome channel=%s err=%v", channelID, err)
+			}
+			log.Printf("stream webhook failed channel=%s type=%s err=%v", channelID, event.Type, err)
+			return apis.NewApiError(http.StatusInternalServerError, "failed to apply webhook", err)
+		}
+
+		return processed(outcome, nil)
+	}
+}
+
+// applyStreamMessageNew records the message as the latest conversation
+// activity and bumps the unread count of every member except the sender.
+// Thread replies that are not shown in the channel are ignored.
+func applyStreamMessageNew(dao *daos.Dao, inquiryCfg chatInquiryConfig, conversation *models.Record, event streamWebhookEvent) (bool, error) {
+	message := event.Message
+	if message == nil || (message.ParentID != "" && !message.ShowInChannel) {
+		return false, nil
+	}
+
+	sentAt := message.CreatedAt
+	if sentAt.IsZero() {
+		sentAt = event.CreatedAt
+	}
+	if sentAt.IsZero() {
+		sentAt = time.Now()
+	}
+
+	lastMessageAt := conversation.GetDateTime("last_message_at")
+	if lastMessageAt.IsZero() || sentAt.After(lastMessageAt.Time()) {
+		conversation.Set("last_message_at", sentAt)
+		conversation.Set("last_message_preview", chatMessagePreview(message))
+	}
+
+	senderID := event.senderID()
+
+	members, err := conversationMemberIDs(dao, conversation)
+	if err != nil {
+		return false, err
+	}
+
+	counts := conversationUnreadCounts(conversation)
+	for _, id := range members {
+		if id == senderID {
+			counts[id] = 0
+		} else {
+			counts[id]++
+		}
+	}
+	conversation.Set("unread_counts", counts)
+
+	if err := applyInquiryMessage(dao, inquiryCfg, conversation, senderID, sentAt); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// applyStreamMessageRead resets the unread count of the reading member.
+func applyStreamMessageRead(conversation *models.Record, event streamWebhookEvent) bool {
+	if event.User == nil || event.User.ID == "" {
+		return false
+	}
+
+	counts := conversationUnreadCounts(conversation)
+	if counts[event.User.ID] == 0 {
+		return false
+	}
+	counts[event.User.ID] = 0
+	conversation.Set("unread_counts", counts)
+
+	return true
+}
+
+// applyStreamChannelDeleted archives a conversation whose channel was
+// deleted on the Stream side (e.g. from the dashboard).
+func applyStreamChannelDeleted(conversation *models.Record, event streamWebhookEvent) bool {
+	if conversation.GetString("state") == "archived" {
+		return false
+	}
+
+	deletedAt := event.CreatedAt
+	if deletedAt.IsZero() {
+		deletedAt = time.Now()
+	}
+
+	conversation.Set("state", "archived")
+	conversation.Set("state_reason", "channel_deleted")
+	conversation.Set("archived_at", deletedAt)
+
+	return true
+}
+
+// conversationMemberIDs returns the proposal client and freelancer that are
+// still members of the conversation channel.
+func conversationMemberIDs(dao *daos.Dao, conversation *models.Record) ([]string, error) {
+	proposal, err := dao.FindRecordById("proposals", conversation.GetString("proposal_id"))
+	if err != nil {
+		return nil, err
+	}
+
+	removed := []string{}
+	_ = conversation.UnmarshalJSONField("removed_members", &removed)
+
+	members := []string{}
+	for _, id := range []string{proposal.GetString("client_id"), proposal.GetString("freelancer_id")} {
+		isRemoved := false
+		for _, removedID := range removed {
+			if removedID == id {
+				isRemoved = true
+				break
+			}
+		}
+		if !isRemoved {
+			members = append(members, id)
+		}
+	}
+
+	return members, nil
+}
+
+func conversationUnreadCounts(conversation *models.Record) map[string]int {
+	counts := map[string]int{}
+	_ = conversation.UnmarshalJSONField("unread_counts", &counts)
+	return counts
+}
+
+func chatMessagePreview(message *streamWebhookMessage) string {
+	text := strings.Join(strings.Fields(message.Text), " ")
+	if text == "" && len(message.Attachments) > 0 {
+		return "Sent an attachment"
+	}
+
+	runes := []rune(text)
+	if len(runes) <= chatPreviewMaxRunes {
+		return text
+	}
+
+	return string(runes[:chatPreviewMaxRunes-1]) + "…"
+}
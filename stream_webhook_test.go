@@ -0,0 +1,256 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+const testStreamSecret = "stream_test_secret"
+
+func signStreamWebhook(t *testing.T, event map[string]any) ([]byte, http.Header) {
+	t.Helper()
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mac := hmac.New(sha256.New, []byte(testStreamSecret))
+	mac.Write(body)
+
+	header := http.Header{}
+	header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+
+	return body, header
+}
+
+func createTestConversation(t *testing.T, app *tests.TestApp) (*models.Record, *models.Record) {
+	t.Helper()
+
+	proposal := createTestProposal(t, app)
+	conversation := createTestRecord(t, app, "conversations", map[string]any{
+		"project_id":        proposal.GetString("project_id"),
+		"proposal_id":       proposal.Id,
+		"stream_channel_id": chatChannelID(proposal.Id),
+		"state":             "active",
+		"is_deleted":        false,
+	})
+
+	return proposal, conversation
+}
+
+func streamMessageEvent(channelID string, id string, senderID string, text string, createdAt time.Time) map[string]any {
+	return map[string]any{
+		"type":       "message.new",
+		"cid":        chatChannelType + ":" + channelID,
+		"channel_id": channelID,
+		"user":       map[string]any{"id": senderID},
+		"message": map[string]any{
+			"id":         id,
+			"text":       text,
+			"type":       "regular",
+			"user":       map[string]any{"id": senderID},
+			"created_at": createdAt.Format(time.RFC3339Nano),
+		},
+		"created_at": createdAt.Format(time.RFC3339Nano),
+	}
+}
+
+func TestStreamWebhookRejectsInvalidSignature(t *testing.T) {
+	app := newTestApp(t)
+	handler := streamWebhookHandler(app, testStreamSecret, chatInquiryConfig{})
+
+	body, _ := signStreamWebhook(t, map[string]any{"type": "message.new"})
+	header := http.Header{}
+	header.Set("X-Signature", "deadbeef")
+
+	if code, _ := callHandler(t, handler, http.MethodPost, "/stream/webhook", body, header, nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", code)
+	}
+
+	disabled := streamWebhookHandler(app, "", chatInquiryConfig{})
+	if code, _ := callHandler(t, disabled, http.MethodPost, "/stream/webhook", body, header, nil); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without a secret, got %d", code)
+	}
+}
+
+func TestStreamWebhookTracksActivityAndUnreadCounts(t *testing.T) {
+	app := newTestApp(t)
+	handler := streamWebhookHandler(app, testStreamSecret, chatInquiryConfig{})
+	proposal, conversation := createTestConversation(t, app)
+
+	clientID := proposal.GetString("client_id")
+	freelancerID := proposal.GetString("freelancer_id")
+	channelID := conversation.GetString("stream_channel_id")
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	send := func(event map[string]any) {
+		t.Helper()
+		body, header := signStreamWebhook(t, event)
+		if code, rec := callHandler(t, handler, http.MethodPost, "/stream/webhook", body, header, nil); code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", code, rec.Body.String())
+		}
+	}
+	reload := func() *models.Record {
+		t.Helper()
+		record, err := app.Dao().FindRecordById("conversations", conversation.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return record
+	}
+
+	first := streamMessageEvent(channelID, "m1", clientID, "Hi   there,\nready to start?", now)
+	send(first)
+	send(streamMessageEvent(channelID, "m2", clientID, "Ping", now.Add(time.Minute)))
+	// replayed deliveries are acknowledged but not counted twice
+	send(first)
+
+	if counts := conversationUnreadCounts(reload()); counts[freelancerID] != 2 || counts[clientID] != 0 {
+		t.Fatalf("unexpected unread counts %v", counts)
+	}
+
+	// a delayed older reply must not replace the preview; replying marks
+	// the channel as read for the sender
+	send(streamMessageEvent(channelID, "m0", freelancerID, "Old", now.Add(-time.Minute)))
+
+	record := reload()
+	if record.GetString("last_message_preview") != "Ping" {
+		t.Fatalf("expected latest preview, got %q", record.GetString("last_message_preview"))
+	}
+	if !record.GetDateTime("last_message_at").Time().Equal(now.Add(time.Minute)) {
+		t.Fatalf("expected last_message_at %v, got %v", now.Add(time.Minute), record.GetDateTime("last_message_at"))
+	}
+
+	counts := conversationUnreadCounts(record)
+	if counts[freelancerID] != 0 || counts[clientID] != 1 {
+		t.Fatalf("unexpected unread counts %v", counts)
+	}
+
+	send(map[string]any{
+		"type":       "message.read",
+		"cid":        chatChannelType + ":" + channelID,
+		"channel_id": channelID,
+		"user":       map[string]any{"id": clientID},
+		"created_at": now.Add(2 * time.Minute).Format(time.RFC3339Nano),
+	})
+	if counts := conversationUnreadCounts(reload()); counts[clientID] != 0 {
+		t.Fatalf("expected reset unread count, got %v", counts)
+	}
+
+	send(map[string]any{
+		"type":       "channel.deleted",
+		"cid":        chatChannelType + ":" + channelID,
+		"channel_id": channelID,
+		"created_at": now.Add(3 * time.Minute).Format(time.RFC3339Nano),
+	})
+	if record := reload(); record.GetString("state") != "archived" || record.GetString("state_reason") != "channel_deleted" {
+		t.Fatalf("expected archived conversation, got %v", record.PublicExport())
+	}
+}
+
+func TestStreamWebhookDeliveryOutcome(t *testing.T) {
+	app := newTestApp(t)
+	handler := streamWebhookHandler(app, testStreamSecret, chatInquiryConfig{})
+
+	scenarios := []struct {
+		name            string
+		event           map[string]any
+		expectedOutcome string
+	}{
+		{"unknown channel", streamMessageEvent("proposal_missing", "m1", "u1", "Hi", time.Now()), webhookOutcomeConversationNotFound},
+		{"unhandled type", map[string]any{"type": "user.updated", "user": map[string]any{"id": "u1"}}, webhookOutcomeIgnored},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			body, header := signStreamWebhook(t, s.event)
+			if code, _ := callHandler(t, handler, http.MethodPost, "/stream/webhook", body, header, nil); code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", code)
+			}
+
+			delivery, err := app.Dao().FindFirstRecordByData("webhook_deliveries", "event_type", s.event["type"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if outcome := delivery.GetString("outcome"); outcome != s.expectedOutcome {
+				t.Fatalf("expected outcome %q, got %q", s.expectedOutcome, outcome)
+			}
+		})
+	}
+}
+
+func TestStreamWebhookRetriesFailedSave(t *testing.T) {
+	app := newTestApp(t)
+	handler := streamWebhookHandler(app, testStreamSecret, chatInquiryConfig{})
+	proposal, conversation := createTestConversation(t, app)
+
+	failing := app.OnModelBeforeUpdate("conversations").Add(func(e *core.ModelEvent) error {
+		return errors.New("disk I/O error")
+	})
+
+	body, header := signStreamWebhook(t, streamMessageEvent(conversation.GetString("stream_channel_id"), "m1", proposal.GetString("client_id"), "Hi", time.Now()))
+	if code, _ := callHandler(t, handler, http.MethodPost, "/stream/webhook", body, header, nil); code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 so Stream retries, got %d", code)
+	}
+	delivery, err := app.Dao().FindFirstRecordByData("webhook_deliveries", "reference_id", conversation.GetString("stream_channel_id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivery.GetString("outcome") != webhookOutcomeSaveFailed {
+		t.Fatalf("expected outcome %q, got %q", webhookOutcomeSaveFailed, delivery.GetString("outcome"))
+	}
+
+	// the retry of the same delivery is applied
+	app.OnModelBeforeUpdate("conversations").Remove(failing)
+	if code, rec := callHandler(t, handler, http.MethodPost, "/stream/webhook", body, header, nil); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", code, rec.Body.String())
+	}
+
+	record, err := app.Dao().FindRecordById("conversations", conversation.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts := conversationUnreadCounts(record); counts[proposal.GetString("freelancer_id")] != 1 {
+		t.Fatalf("expected the retry to be counted, got %v", counts)
+	}
+	deliveries, err := app.Dao().FindRecordsByExpr("webhook_deliveries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].GetString("outcome") != webhookOutcomeProcessed {
+		t.Fatalf("expected a single processed delivery, got %d", len(deliveries))
+	}
+}
+
+func TestChatMessagePreview(t *testing.T) {
+	long := ""
+	for i := 0; i < chatPreviewMaxRunes+10; i++ {
+		long += "é"
+	}
+
+	scenarios := []struct {
+		message  streamWebhookMessage
+		expected string
+	}{
+		{streamWebhookMessage{Text: "  hello \n world "}, "hello world"},
+		{streamWebhookMessage{Attachments: []json.RawMessage{json.RawMessage(`{}`)}}, "Sent an attachment"},
+		{streamWebhookMessage{Text: long}, long[:len("é")*(chatPreviewMaxRunes-1)] + "…"},
+	}
+
+	for _, s := range scenarios {
+		if preview := chatMessagePreview(&s.message); preview != s.expected {
+			t.Fatalf("expected %q, got %q", s.expected, preview)
+		}
+	}
+}
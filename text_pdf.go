@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A minimal PDF writer for plain text documents such as chat transcripts.
+// It uses the built-in Courier font, so every character has the same width
+// and lines can be wrapped by counting runes.
+const (
+	textPDFPageWidth    = 612 // US Letter, in points
+	textPDFPageHeight   = 792
+	textPDFMargin       = 50
+	textPDFFontSize     = 9
+	textPDFLeading      = 11
+	textPDFLineWidth    = 94 // (612 - 2*50) / (0.6 * 9)
+	textPDFLinesPerPage = 60
+)
+
+// winAnsiExtra maps the characters outside Latin-1 that the WinAnsi
+// encoding of the standard fonts still covers.
+var winAnsiExtra = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// wrapTextPDFLine splits a line into chunks of at most width runes,
+// breaking at spaces where possible.
+func wrapTextPDFLine(line string, width int) []string {
+	runes := []rune(strings.ReplaceAll(line, "\t", "    "))
+	if len(runes) <= width {
+		return []string{string(runes)}
+	}
+
+	lines := []string{}
+	for len(runes) > width {
+		cut := width
+		for i := width; i > width/2; i-- {
+			if runes[i] == ' ' {
+				cut = i
+				break
+			}
+		}
+		lines = append(lines, strings.TrimRight(string(runes[:cut]), " "))
+		runes = []rune(strings.TrimLeft(string(runes[cut:]), " "))
+	}
+	if len(runes) > 0 {
+		lines = append(lines, string(runes))
+	}
+
+	return lines
+}
+
+// encodeTextPDFString returns s as a PDF literal string in WinAnsi encoding;
+// characters the font cannot show become "?".
+func encodeTextPDFString(s string) string {
+	var buf strings.Builder
+	buf.WriteByte('(')
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			buf.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&buf, "\\%03o", r)
+		default:
+			if b, ok := winAnsiExtra[r]; ok {
+				fmt.Fprintf(&buf, "\\%03o", b)
+			} else {
+				buf.WriteByte('?')
+			}
+		}
+	}
+	buf.WriteByte(')')
+	return buf.String()
+}
+
+// renderTextPDF lays out the lines on as many pages as needed, wrapping
+// long lines, and adds "footer - page n of m" to every page.
+func renderTextPDF(lines []string, footer string) []byte {
+	wrapped := []string{}
+	for _, line := range lines {
+		for _, part := range strings.Split(line, "\n") {
+			wrapped = append(wrapped, wrapTextPDFLine(part, textPDFLineWidth)...)
+		}
+	}
+
+	pages := [][]string{}
+	for len(wrapped) > textPDFLinesPerPage {
+		pages = append(pages, wrapped[:textPDFLinesPerPage])
+		wrapped = wrapped[textPDFLinesPerPage:]
+	}
+	pages = append(pages, wrapped)
+
+	var out bytes.Buffer
+	offsets := []int{}
+	writeObject := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// 1: catalog, 2: page tree, 3: font, then a page and its content per page
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+i*2)
+	}
+	writeObject("<< /Type /Catalog /Pages 2 0 R >>")
+	writeObject(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	writeObject("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range pages {
+		var content strings.Builder
+		fmt.Fprintf(&content, "BT /F1 %d Tf %d TL %d %d Td\n", textPDFFontSize, textPDFLeading, textPDFMargin, textPDFPageHeight-textPDFMargin)
+		for _, line := range page {
+			content.WriteString(encodeTextPDFString(line))
+			content.WriteString(" Tj T*\n")
+		}
+		content.WriteString("ET\n")
+		fmt.Fprintf(&content, "BT /F1 %d Tf %d %d Td %s Tj ET\n", textPDFFontSize-1, textPDFMargin, textPDFMargin/2,
+			encodeTextPDFString(fmt.Sprintf("%s - page %d of %d", footer, i+1, len(pages))))
+
+		writeObject(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			textPDFPageWidth, textPDFPageHeight, len(offsets)+2))
+		writeObject(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes()
+}
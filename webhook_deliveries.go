@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// webhookDeliveryTTL is how long delivery hashes are kept at least. A
+// delivery is never purged while its signed timestamp is still accepted, see
+// webhookDelivery.ReplayableUntil.
+const webhookDeliveryTTL = 24 * time.Hour
+
+const (
+	webhookOutcomeProcessed      = "processed"
+	webhookOutcomeIgnored        = "ignored"
+	webhookOutcomeUserNotFound   = "user_not_found"
+	webhookOutcomeSameStatus     = "same_status"
+	webhookOutcomeUnknownStatus  = "unknown_status"
+	webhookOutcomeSaveFailed     = "save_failed"
+	webhookOutcomeInvalidPayload = "invalid_payload"
+	webhookOutcomeAdminReviewed  = "admin_reviewed"
+
+	webhookOutcomeConversationNotFound = "conversation_not_found"
+)
+
+var errDuplicateWebhookDelivery = errors.New("duplicate webhook delivery")
+
+type webhookDelivery struct {
+	Provider string
+	// Body is the signed content of the delivery, usually the raw body.
+	Body            []byte
+	EventType       string
+	ReferenceID     string
+	SignatureScheme string
+	SecretIndex     int
+	// ReplayableUntil is when the signed timestamp of the delivery stops
+	// being accepted, if the provider signs one.
+	ReplayableUntil time.Time
+}
+
+// beginWebhookDelivery stores a hash of the signed webhook content and
+// returns errDuplicateWebhookDelivery if the same content was already
+// received within webhookDeliveryTTL. The hash covers only what is signed,
+// so resending it with a fresh timestamp header is still detected. A
+// delivery whose changes failed to be saved is not a duplicate, so the
+// provider's retry is processed again.
+func beginWebhookDelivery(app core.App, delivery webhookDelivery) (*models.Record, error) {
+	if err := purgeExpiredWebhookDeliveries(app); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(delivery.Body)
+	hash := hex.EncodeToString(sum[:])
+
+	existing, err := app.Dao().FindFirstRecordByFilter(
+		"webhook_deliveries",
+		"provider = {:provider} && delivery_hash = {:hash}",
+		dbx.Params{"provider": delivery.Provider, "hash": hash},
+	)
+	if err == nil {
+		if existing.GetString("outcome") != webhookOutcomeSaveFailed {
+			return nil, errDuplicateWebhookDelivery
+		}
+		existing.Set("outcome", "")
+		existing.Set("error", "")
+		if err := app.Dao().SaveRecord(existing); err != nil {
+			return nil, err
+		}
+		return existing, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	col, err := app.Dao().FindCollectionByNameOrId("webhook_deliveries")
+	if err != nil {
+		return nil, err
+	}
+
+	record := models.NewRecord(col)
+	record.Set("provider", delivery.Provider)
+	record.Set("delivery_hash", hash)
+	record.Set("event_type", delivery.EventType)
+	record.Set("reference_id", delivery.ReferenceID)
+	record.Set("signature_scheme", delivery.SignatureScheme)
+	record.Set("secret_index", delivery.SecretIndex)
+	expiresAt := time.Now().Add(webhookDeliveryTTL)
+	if delivery.ReplayableUntil.After(expiresAt) {
+		expiresAt = delivery.ReplayableUntil
+	}
+	record.Set("expires_at", expiresAt)
+	record.Set("is_deleted", false)
+
+	if err := app.Dao().SaveRecord(record); err != nil {
+		// a concurrent identical delivery won the unique index
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, errDuplicateWebhookDelivery
+		}
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// finishWebhookDelivery records why a delivery ended the way it did.
+func finishWebhookDelivery(app core.App, record *models.Record, outcome string, cause error) error {
+	if record == nil {
+		return nil
+	}
+
+	record.Set("outcome", outcome)
+	if cause != nil {
+		record.Set("error", cause.Error())
+	}
+
+	return app.Dao().SaveRecord(record)
+}
+
+func purgeExpiredWebhookDeliveries(app core.App) error {
+	_, err := app.Dao().DB().Delete("webhook_deliveries", dbx.NewExp(
+		"expires_at < {:now}",
+		dbx.Params{"now": types.NowDateTime().String()},
+	)).Execute()
+
+	return err
+}